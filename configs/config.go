@@ -11,6 +11,9 @@ import (
 // variables.
 type Config struct {
 	App struct {
+		Admin struct {
+			UserIDs []string `mapstructure:"USER_IDS"`
+		}
 		CORS struct {
 			AllowCredentials bool     `mapstructure:"ALLOW_CREDENTIALS"`
 			AllowedHeaders   []string `mapstructure:"ALLOWED_HEADERS"`
@@ -19,10 +22,61 @@ type Config struct {
 			Enable           bool     `mapstructure:"ENABLE"`
 			MaxAgeSeconds    int      `mapstructure:"MAX_AGE_SECONDS"`
 		}
-		Name     string `mapstructure:"NAME"`
+		JWT struct {
+			AcceptHS256              bool   `mapstructure:"ACCEPT_HS256"`
+			AccessTokenExpirySeconds int64  `mapstructure:"ACCESS_TOKEN_EXPIRY_SECONDS"`
+			Algorithm                string `mapstructure:"ALGORITHM"`
+			Issuer                   string `mapstructure:"ISSUER"`
+			KeyID                    string `mapstructure:"KEY_ID"`
+			KeyRing                  struct {
+				Enabled                bool   `mapstructure:"ENABLED"`
+				EncryptionSecret       string `mapstructure:"ENCRYPTION_SECRET"`
+				PrePublishSeconds      int64  `mapstructure:"PRE_PUBLISH_SECONDS"`
+				RefreshIntervalSeconds int64  `mapstructure:"REFRESH_INTERVAL_SECONDS"`
+				RotationPeriodSeconds  int64  `mapstructure:"ROTATION_PERIOD_SECONDS"`
+			} `mapstructure:"KEY_RING"`
+			PrivateKeyPath            string `mapstructure:"PRIVATE_KEY_PATH"`
+			RefreshTokenExpirySeconds int64  `mapstructure:"REFRESH_TOKEN_EXPIRY_SECONDS"`
+		}
+		Name  string `mapstructure:"NAME"`
+		OAuth struct {
+			AccessTokenExpirySeconds       int64    `mapstructure:"ACCESS_TOKEN_EXPIRY_SECONDS"`
+			AuthorizationCodeExpirySeconds int64    `mapstructure:"AUTHORIZATION_CODE_EXPIRY_SECONDS"`
+			ClientScope                    []string `mapstructure:"CLIENT_SCOPE"`
+			DisabledGrantTypes             []string `mapstructure:"DISABLED_GRANT_TYPES"`
+			DeviceCodeExpirySeconds        int64    `mapstructure:"DEVICE_CODE_EXPIRY_SECONDS"`
+			DevicePollIntervalSeconds      int64    `mapstructure:"DEVICE_POLL_INTERVAL_SECONDS"`
+			DeviceVerificationURI          string   `mapstructure:"DEVICE_VERIFICATION_URI"`
+			TokenExchangeAudiences         []string `mapstructure:"TOKEN_EXCHANGE_AUDIENCES"`
+			RefreshTokenExpirySeconds      int64    `mapstructure:"REFRESH_TOKEN_EXPIRY_SECONDS"`
+		}
 		Revision string `mapstructure:"REVISION"`
 		URL      string `mapstructure:"URL"`
 		Secret   string `mapstructure:"SECRET"`
+		User     struct {
+			DataExport struct {
+				ExpirySeconds int64 `mapstructure:"EXPIRY_SECONDS"`
+			} `mapstructure:"DATA_EXPORT"`
+			Deletion struct {
+				PurgeIntervalSeconds int64 `mapstructure:"PURGE_INTERVAL_SECONDS"`
+				RetentionSeconds     int64 `mapstructure:"RETENTION_SECONDS"`
+			}
+			EmailVerification struct {
+				ExpirySeconds         int64  `mapstructure:"EXPIRY_SECONDS"`
+				ResendIntervalSeconds int64  `mapstructure:"RESEND_INTERVAL_SECONDS"`
+				RestrictUnverified    bool   `mapstructure:"RESTRICT_UNVERIFIED"`
+				UnverifiedScope       string `mapstructure:"UNVERIFIED_SCOPE"`
+				URL                   string `mapstructure:"URL"`
+			} `mapstructure:"EMAIL_VERIFICATION"`
+			Password struct {
+				MinLength int `mapstructure:"MIN_LENGTH"`
+			}
+			PasswordReset struct {
+				ExpirySeconds         int64  `mapstructure:"EXPIRY_SECONDS"`
+				ResendIntervalSeconds int64  `mapstructure:"RESEND_INTERVAL_SECONDS"`
+				URL                   string `mapstructure:"URL"`
+			} `mapstructure:"PASSWORD_RESET"`
+		}
 	}
 
 	Cache struct {
@@ -93,6 +147,24 @@ type Config struct {
 		}
 	}
 
+	Mail struct {
+		DefaultLocale     string `mapstructure:"DEFAULT_LOCALE"`
+		Dir               string `mapstructure:"DIR"`
+		Driver            string `mapstructure:"DRIVER"`
+		From              string `mapstructure:"FROM"`
+		FromName          string `mapstructure:"FROM_NAME"`
+		MaxAttempts       int    `mapstructure:"MAX_ATTEMPTS"`
+		RetryDelaySeconds int64  `mapstructure:"RETRY_DELAY_SECONDS"`
+		SMTP              struct {
+			Host     string `mapstructure:"HOST"`
+			Port     string `mapstructure:"PORT"`
+			Username string `mapstructure:"USER"`
+			Password string `mapstructure:"PASSWORD"`
+		}
+		TemplateDir string `mapstructure:"TEMPLATE_DIR"`
+		Workers     int    `mapstructure:"WORKERS"`
+	}
+
 	Server struct {
 		Env      string `mapstructure:"ENV"`
 		LogLevel string `mapstructure:"LOG_LEVEL"`
@@ -5,6 +5,7 @@ import (
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/go-redis/redis"
+	"github.com/rs/zerolog/log"
 )
 
 //RedisNewClient create new instance of redis
@@ -22,3 +23,30 @@ func RedisNewClient(config configs.Config) *redis.Client {
 
 	return client
 }
+
+// ProvideRedisClient is the provider for the primary Redis client. Unlike
+// RedisNewClient, it does not panic when Redis is unreachable, so that
+// callers with a fallback can keep serving.
+func ProvideRedisClient(config *configs.Config) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", config.Cache.Redis.Primary.Host, config.Cache.Redis.Primary.Port),
+		Password: config.Cache.Redis.Primary.Password,
+	})
+
+	if err := client.Ping().Err(); err != nil {
+		log.
+			Warn().
+			Err(err).
+			Str("host", config.Cache.Redis.Primary.Host).
+			Str("port", config.Cache.Redis.Primary.Port).
+			Msg("Failed connecting to Redis")
+	} else {
+		log.
+			Info().
+			Str("host", config.Cache.Redis.Primary.Host).
+			Str("port", config.Cache.Redis.Primary.Port).
+			Msg("Connected to Redis")
+	}
+
+	return client
+}
@@ -0,0 +1,290 @@
+package oauthclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/guregu/null"
+)
+
+// Client is an OAuth client registered with this service. Its secret is
+// stored hashed, and is empty for public clients.
+type Client struct {
+	ClientID     string      `db:"client_id" validate:"required"`
+	Name         null.String `db:"name"`
+	ClientSecret string      `db:"client_secret"`
+	RedirectURI  null.String `db:"redirect_uri"`
+	GrantTypes   string      `db:"grant_types" validate:"required"`
+	Scope        null.String `db:"scope"`
+	CreatedAt    time.Time   `db:"created_at" validate:"required"`
+	UpdatedAt    null.Time   `db:"updated_at"`
+	DisabledAt   null.Time   `db:"disabled_at"`
+}
+
+// IsPublic checks whether the client has no secret.
+func (c *Client) IsPublic() bool {
+	return c.ClientSecret == ""
+}
+
+// IsDisabled checks whether the client can no longer obtain tokens.
+func (c *Client) IsDisabled() bool {
+	return c.DisabledAt.Valid
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (c Client) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.ToResponseFormat())
+}
+
+// NewFromRequestFormat creates a new Client from its request format. The
+// generated secret is returned in plain text, and is empty for public clients.
+func (c Client) NewFromRequestFormat(req ClientRequestFormat) (newClient Client, secret string, err error) {
+	clientID, err := generateClientID()
+	if err != nil {
+		return
+	}
+
+	newClient = Client{
+		ClientID:  clientID,
+		CreatedAt: time.Now(),
+	}
+	newClient.apply(req)
+
+	if !req.Public {
+		secret, err = oauth.GenerateClientSecret()
+		if err != nil {
+			return
+		}
+		newClient.ClientSecret, err = oauth.HashClientSecret(secret)
+		if err != nil {
+			return
+		}
+	}
+
+	err = newClient.Validate()
+	return
+}
+
+// Update updates a Client. Whether the client is public can't be changed.
+func (c *Client) Update(req ClientRequestFormat) (err error) {
+	if req.Public != c.IsPublic() {
+		return failure.BadRequestFromString("a client can't be changed between public and confidential")
+	}
+
+	c.apply(req)
+	c.UpdatedAt = null.TimeFrom(time.Now())
+
+	return c.Validate()
+}
+
+// RotateSecret replaces the secret of a confidential client, and returns the
+// new one in plain text.
+func (c *Client) RotateSecret() (secret string, err error) {
+	if c.IsPublic() {
+		err = failure.BadRequestFromString("a public client has no secret")
+		return
+	}
+
+	secret, err = oauth.GenerateClientSecret()
+	if err != nil {
+		return
+	}
+
+	c.ClientSecret, err = oauth.HashClientSecret(secret)
+	if err != nil {
+		return
+	}
+	c.UpdatedAt = null.TimeFrom(time.Now())
+
+	return
+}
+
+// StretchLegacySecret stretches the secret hash of a client that still has
+// an unsalted SHA-256 hash, and tells whether it did.
+func (c *Client) StretchLegacySecret() (stretched bool, err error) {
+	if !oauth.IsLegacyClientSecretHash(c.ClientSecret) {
+		return
+	}
+
+	c.ClientSecret, err = oauth.StretchLegacyClientSecretHash(c.ClientSecret)
+	if err != nil {
+		return
+	}
+	c.UpdatedAt = null.TimeFrom(time.Now())
+
+	return true, nil
+}
+
+// Disable prevents the client from obtaining tokens.
+func (c *Client) Disable() (err error) {
+	if c.IsDisabled() {
+		return failure.Conflict("disable", "oauthClient", "already disabled")
+	}
+
+	c.DisabledAt = null.TimeFrom(time.Now())
+	c.UpdatedAt = c.DisabledAt
+
+	return
+}
+
+// Enable allows a disabled client to obtain tokens again.
+func (c *Client) Enable() (err error) {
+	if !c.IsDisabled() {
+		return failure.Conflict("enable", "oauthClient", "not disabled")
+	}
+
+	c.DisabledAt = null.Time{}
+	c.UpdatedAt = null.TimeFrom(time.Now())
+
+	return
+}
+
+// Validate validates the entity.
+func (c *Client) Validate() (err error) {
+	validator := shared.GetValidator()
+	if err = validator.Struct(c); err != nil {
+		return failure.BadRequest(err)
+	}
+
+	for _, redirectURI := range strings.Fields(c.RedirectURI.String) {
+		if err = validateRedirectURI(redirectURI); err != nil {
+			return failure.BadRequest(err)
+		}
+	}
+
+	if c.hasGrantType(oauth.AuthorizationCode) && !c.RedirectURI.Valid {
+		return failure.BadRequestFromString("the authorization_code grant requires a redirect URI")
+	}
+
+	// A public client can't keep a secret, so it can't act on its own behalf.
+	if c.IsPublic() && c.hasGrantType(oauth.ClientCredentials) {
+		return failure.BadRequestFromString("a public client can't use the client_credentials grant")
+	}
+
+	if c.IsPublic() && c.hasGrantType(oauth.TokenExchange) {
+		return failure.BadRequestFromString("a public client can't use the token exchange grant")
+	}
+
+	return
+}
+
+// ToResponseFormat converts this Client to its response format.
+func (c Client) ToResponseFormat() ClientResponseFormat {
+	return ClientResponseFormat{
+		ClientID:     c.ClientID,
+		Name:         c.Name.String,
+		RedirectURIs: fields(c.RedirectURI.String),
+		GrantTypes:   fields(c.GrantTypes),
+		Scopes:       fields(c.Scope.String),
+		Public:       c.IsPublic(),
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		DisabledAt:   c.DisabledAt,
+	}
+}
+
+// ToSecretResponseFormat converts this Client to its response format,
+// including the secret in plain text. It is only used right after the secret
+// is generated, since the secret can't be recovered later.
+func (c Client) ToSecretResponseFormat(secret string) ClientSecretResponseFormat {
+	return ClientSecretResponseFormat{
+		ClientResponseFormat: c.ToResponseFormat(),
+		ClientSecret:         secret,
+	}
+}
+
+func (c *Client) apply(req ClientRequestFormat) {
+	c.Name = null.NewString(req.Name, req.Name != "")
+	c.GrantTypes = strings.Join(req.GrantTypes, " ")
+
+	redirectURIs := strings.Join(req.RedirectURIs, " ")
+	c.RedirectURI = null.NewString(redirectURIs, redirectURIs != "")
+
+	scope := strings.Join(req.Scopes, " ")
+	c.Scope = null.NewString(scope, scope != "")
+}
+
+func (c *Client) hasGrantType(grantType oauth.GrantType) bool {
+	for _, g := range strings.Fields(c.GrantTypes) {
+		if g == string(grantType) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateClientID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// validateRedirectURI checks that a redirect URI is absolute and has no
+// fragment, see RFC 6749, section 3.1.2.
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return err
+	}
+
+	if !u.IsAbs() || u.Host == "" && u.Opaque == "" {
+		return fmt.Errorf("redirect URI %s must be absolute", redirectURI)
+	}
+
+	if u.Fragment != "" {
+		return errors.New("redirect URI must not contain a fragment")
+	}
+
+	return nil
+}
+
+func fields(s string) []string {
+	f := strings.Fields(s)
+	if f == nil {
+		return []string{}
+	}
+	return f
+}
+
+// ClientRequestFormat represents a Client's standard formatting for JSON
+// deserializing. Redirect URIs and scopes must not contain spaces.
+type ClientRequestFormat struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirectUris" validate:"dive,required,excludesall= "`
+	GrantTypes   []string `json:"grantTypes" validate:"required,min=1,dive,required"`
+	Scopes       []string `json:"scopes" validate:"dive,required,excludesall= "`
+	Public       bool     `json:"public"`
+}
+
+// ClientResponseFormat represents a Client's standard formatting for JSON
+// serializing. It never contains the secret.
+type ClientResponseFormat struct {
+	ClientID     string    `json:"clientId"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	GrantTypes   []string  `json:"grantTypes"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    null.Time `json:"updatedAt"`
+	DisabledAt   null.Time `json:"disabledAt"`
+}
+
+// ClientSecretResponseFormat is the ClientResponseFormat including the plain
+// text secret, which is only shown once.
+type ClientSecretResponseFormat struct {
+	ClientResponseFormat
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
@@ -0,0 +1,255 @@
+package oauthclient
+
+import (
+	"database/sql"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	clientQueries = struct {
+		selectClient         string
+		insertClient         string
+		updateClient         string
+		deleteClient         string
+		deleteAccessTokens   string
+		revokeRefreshTokens  string
+		deleteAuthorizations string
+	}{
+		selectClient: `
+			SELECT
+				client_id,
+				name,
+				client_secret,
+				redirect_uri,
+				grant_types,
+				scope,
+				created_at,
+				updated_at,
+				disabled_at
+			FROM oauth_clients `,
+
+		insertClient: `
+			INSERT INTO oauth_clients (
+				client_id,
+				name,
+				client_secret,
+				redirect_uri,
+				grant_types,
+				scope,
+				created_at,
+				updated_at,
+				disabled_at
+			) VALUES (
+				:client_id,
+				:name,
+				:client_secret,
+				:redirect_uri,
+				:grant_types,
+				:scope,
+				:created_at,
+				:updated_at,
+				:disabled_at)`,
+
+		updateClient: `
+			UPDATE oauth_clients
+			SET
+				name = :name,
+				client_secret = :client_secret,
+				redirect_uri = :redirect_uri,
+				grant_types = :grant_types,
+				scope = :scope,
+				updated_at = :updated_at,
+				disabled_at = :disabled_at
+			WHERE client_id = :client_id `,
+
+		deleteClient: `
+			DELETE FROM oauth_clients WHERE client_id = ?`,
+
+		deleteAccessTokens: `
+			DELETE FROM oauth_access_tokens WHERE client_id = ?`,
+
+		revokeRefreshTokens: `
+			UPDATE oauth_refresh_tokens
+			SET revoked_at = NOW()
+			WHERE client_id = ? AND revoked_at IS NULL`,
+
+		deleteAuthorizations: `
+			DELETE FROM oauth_authorization_codes WHERE client_id = ?`,
+	}
+)
+
+// ClientRepository is the repository for OAuth client data.
+type ClientRepository interface {
+	Create(client Client) (err error)
+	Delete(clientID string) (err error)
+	ExistsByID(clientID string) (exists bool, err error)
+	ResolveAll() (clients []Client, err error)
+	ResolveByID(clientID string) (client Client, err error)
+	RevokeTokens(clientID string) (err error)
+	Update(client Client) (err error)
+}
+
+// ClientRepositoryMySQL is the MySQL-backed implementation of ClientRepository.
+type ClientRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+// ProvideClientRepositoryMySQL is the provider for this repository.
+func ProvideClientRepositoryMySQL(db *infras.MySQLConn) *ClientRepositoryMySQL {
+	s := new(ClientRepositoryMySQL)
+	s.DB = db
+	return s
+}
+
+// Create creates a new Client.
+func (r *ClientRepositoryMySQL) Create(client Client) (err error) {
+	exists, err := r.ExistsByID(client.ClientID)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if exists {
+		err = failure.Conflict("create", "oauthClient", "already exists")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	stmt, err := r.DB.Write.PrepareNamed(clientQueries.insertClient)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(client)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// Delete deletes a Client together with the tokens and authorization codes
+// issued to it.
+func (r *ClientRepositoryMySQL) Delete(clientID string) (err error) {
+	exists, err := r.ExistsByID(clientID)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("oauthClient")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		for _, query := range []string{
+			clientQueries.deleteAuthorizations,
+			clientQueries.revokeRefreshTokens,
+			clientQueries.deleteAccessTokens,
+			clientQueries.deleteClient,
+		} {
+			if _, err := tx.Exec(query, clientID); err != nil {
+				logger.ErrorWithStack(err)
+				e <- err
+				return
+			}
+		}
+
+		e <- nil
+	})
+}
+
+// ExistsByID checks the existence of a Client by its ID.
+func (r *ClientRepositoryMySQL) ExistsByID(clientID string) (exists bool, err error) {
+	err = r.DB.Read.Get(
+		&exists,
+		"SELECT COUNT(client_id) FROM oauth_clients WHERE client_id = ?",
+		clientID)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveAll resolves all Clients.
+func (r *ClientRepositoryMySQL) ResolveAll() (clients []Client, err error) {
+	clients = make([]Client, 0)
+	err = r.DB.Read.Select(&clients, clientQueries.selectClient+" ORDER BY created_at")
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveByID resolves a Client by its ID.
+func (r *ClientRepositoryMySQL) ResolveByID(clientID string) (client Client, err error) {
+	err = r.DB.Read.Get(
+		&client,
+		clientQueries.selectClient+" WHERE client_id = ?",
+		clientID)
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("oauthClient")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return
+}
+
+// RevokeTokens revokes the tokens and authorization codes issued to a Client.
+func (r *ClientRepositoryMySQL) RevokeTokens(clientID string) (err error) {
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		for _, query := range []string{
+			clientQueries.deleteAuthorizations,
+			clientQueries.revokeRefreshTokens,
+			clientQueries.deleteAccessTokens,
+		} {
+			if _, err := tx.Exec(query, clientID); err != nil {
+				logger.ErrorWithStack(err)
+				e <- err
+				return
+			}
+		}
+
+		e <- nil
+	})
+}
+
+// Update updates a Client.
+func (r *ClientRepositoryMySQL) Update(client Client) (err error) {
+	exists, err := r.ExistsByID(client.ClientID)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("oauthClient")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	stmt, err := r.DB.Write.PrepareNamed(clientQueries.updateClient)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(client)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
@@ -0,0 +1,183 @@
+package oauthclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+)
+
+// ClientService is the service interface for OAuth clients.
+type ClientService interface {
+	Create(requestFormat ClientRequestFormat) (client Client, secret string, err error)
+	Delete(clientID string) (err error)
+	Disable(clientID string) (client Client, err error)
+	Enable(clientID string) (client Client, err error)
+	ResolveAll() (clients []Client, err error)
+	ResolveByID(clientID string) (client Client, err error)
+	RotateSecret(clientID string) (client Client, secret string, err error)
+	Update(clientID string, requestFormat ClientRequestFormat) (client Client, err error)
+}
+
+// ClientServiceImpl is the service implementation for OAuth clients.
+type ClientServiceImpl struct {
+	ClientRepository ClientRepository
+	GrantRegistry    *oauth.GrantRegistry
+}
+
+// ProvideClientServiceImpl is the provider for this service.
+func ProvideClientServiceImpl(clientRepository ClientRepository, grantRegistry *oauth.GrantRegistry) *ClientServiceImpl {
+	s := new(ClientServiceImpl)
+	s.ClientRepository = clientRepository
+	s.GrantRegistry = grantRegistry
+
+	return s
+}
+
+// Create registers a new Client. The plain text secret is only returned here.
+func (s *ClientServiceImpl) Create(requestFormat ClientRequestFormat) (client Client, secret string, err error) {
+	client, secret, err = client.NewFromRequestFormat(requestFormat)
+	if err != nil {
+		return
+	}
+
+	err = s.validateGrantTypes(client)
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.Create(client)
+	return
+}
+
+// Delete deletes a Client and revokes everything issued to it.
+func (s *ClientServiceImpl) Delete(clientID string) (err error) {
+	return s.ClientRepository.Delete(clientID)
+}
+
+// Disable prevents a Client from obtaining tokens, and revokes the tokens it
+// currently holds.
+func (s *ClientServiceImpl) Disable(clientID string) (client Client, err error) {
+	client, err = s.ClientRepository.ResolveByID(clientID)
+	if err != nil {
+		return
+	}
+
+	err = client.Disable()
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.Update(client)
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.RevokeTokens(clientID)
+	return
+}
+
+// Enable allows a disabled Client to obtain tokens again.
+func (s *ClientServiceImpl) Enable(clientID string) (client Client, err error) {
+	client, err = s.ClientRepository.ResolveByID(clientID)
+	if err != nil {
+		return
+	}
+
+	err = client.Enable()
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.Update(client)
+	return
+}
+
+// ResolveAll resolves all Clients.
+func (s *ClientServiceImpl) ResolveAll() (clients []Client, err error) {
+	return s.ClientRepository.ResolveAll()
+}
+
+// ResolveByID resolves a Client by its ID.
+func (s *ClientServiceImpl) ResolveByID(clientID string) (client Client, err error) {
+	return s.ClientRepository.ResolveByID(clientID)
+}
+
+// RotateSecret replaces the secret of a confidential Client. The previous
+// secret stops working immediately, while issued tokens stay valid.
+func (s *ClientServiceImpl) RotateSecret(clientID string) (client Client, secret string, err error) {
+	client, err = s.ClientRepository.ResolveByID(clientID)
+	if err != nil {
+		return
+	}
+
+	secret, err = client.RotateSecret()
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.Update(client)
+	return
+}
+
+// Update updates a Client.
+func (s *ClientServiceImpl) Update(clientID string, requestFormat ClientRequestFormat) (client Client, err error) {
+	client, err = s.ClientRepository.ResolveByID(clientID)
+	if err != nil {
+		return
+	}
+
+	err = client.Update(requestFormat)
+	if err != nil {
+		return
+	}
+
+	err = s.validateGrantTypes(client)
+	if err != nil {
+		return
+	}
+
+	err = s.ClientRepository.Update(client)
+	return
+}
+
+// validateGrantTypes checks that the client is only registered for grant types
+// the token endpoint implements. Disabled grant types are accepted, so that
+// they can be enabled again without updating the clients.
+func (s *ClientServiceImpl) validateGrantTypes(client Client) error {
+	for _, grantType := range strings.Fields(client.GrantTypes) {
+		if !s.GrantRegistry.IsRegistered(oauth.GrantType(grantType)) {
+			return failure.BadRequestFromString(fmt.Sprintf("unsupported grant type %s", grantType))
+		}
+	}
+
+	return nil
+}
+
+// StretchLegacySecrets stretches the unsalted SHA-256 secret hashes left by
+// the client management migration, which legacy clients can't authenticate
+// with until then. It returns the number of clients updated.
+func StretchLegacySecrets(clientRepository ClientRepository) (stretched int, err error) {
+	clients, err := clientRepository.ResolveAll()
+	if err != nil {
+		return
+	}
+
+	for _, client := range clients {
+		ok, err := client.StretchLegacySecret()
+		if err != nil {
+			return stretched, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := clientRepository.Update(client); err != nil {
+			return stretched, err
+		}
+		stretched++
+	}
+
+	return
+}
@@ -0,0 +1,83 @@
+package rbac
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/guregu/null"
+)
+
+// PermissionManageRBAC is the permission to manage roles, permissions and
+// role assignments.
+const PermissionManageRBAC = "rbac:manage"
+
+// permissionNameRegex matches names such as foo:write, made of a resource and
+// the actions on it separated by colons.
+var permissionNameRegex = regexp.MustCompile(`^[a-z0-9_.-]+(:[a-z0-9_.-]+)*$`)
+
+// Permission is an action that roles grant to their users, such as
+// foo:write. Permissions are identified by their name.
+type Permission struct {
+	Name        string      `db:"name" validate:"required,max=100"`
+	Description null.String `db:"description"`
+	CreatedAt   time.Time   `db:"created_at" validate:"required"`
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.ToResponseFormat())
+}
+
+// NewPermissionFromRequestFormat creates a new Permission from its request
+// format.
+func NewPermissionFromRequestFormat(req PermissionRequestFormat) (permission Permission, err error) {
+	permission = Permission{
+		Name:        req.Name,
+		Description: null.NewString(req.Description, req.Description != ""),
+		CreatedAt:   time.Now(),
+	}
+
+	err = permission.Validate()
+	return
+}
+
+// Validate validates the entity.
+func (p *Permission) Validate() (err error) {
+	validator := shared.GetValidator()
+	if err = validator.Struct(p); err != nil {
+		return failure.BadRequest(err)
+	}
+
+	if !permissionNameRegex.MatchString(p.Name) {
+		return failure.BadRequestFromString("permission name must be lowercase, such as foo:write")
+	}
+
+	return
+}
+
+// ToResponseFormat converts this Permission to its response format.
+func (p Permission) ToResponseFormat() PermissionResponseFormat {
+	return PermissionResponseFormat{
+		Name:        p.Name,
+		Description: p.Description.String,
+		CreatedAt:   p.CreatedAt,
+	}
+}
+
+// PermissionRequestFormat represents a Permission's standard formatting for
+// JSON deserializing.
+type PermissionRequestFormat struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// PermissionResponseFormat represents a Permission's standard formatting for
+// JSON serializing.
+type PermissionResponseFormat struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,181 @@
+package rbac
+
+import (
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	permissionQueries = struct {
+		selectPermission       string
+		insertPermission       string
+		deletePermission       string
+		deleteRolePermissions  string
+		selectPermissionsExist string
+	}{
+		selectPermission: `
+			SELECT
+				name,
+				description,
+				created_at
+			FROM permission `,
+
+		insertPermission: `
+			INSERT INTO permission (
+				name,
+				description,
+				created_at
+			) VALUES (
+				:name,
+				:description,
+				:created_at)`,
+
+		deletePermission: `
+			DELETE FROM permission WHERE name = ?`,
+
+		deleteRolePermissions: `
+			DELETE FROM role_permission WHERE permission_name = ?`,
+
+		selectPermissionsExist: `
+			SELECT name FROM permission WHERE name IN (?)`,
+	}
+)
+
+// PermissionRepository is the repository for permission data.
+type PermissionRepository interface {
+	CreatePermission(permission Permission) (err error)
+	DeletePermission(name string) (err error)
+	ExistsPermissionByName(name string) (exists bool, err error)
+	ResolveMissingPermissions(names []string) (missing []string, err error)
+	ResolvePermissions() (permissions []Permission, err error)
+}
+
+// PermissionRepositoryMySQL is the MySQL-backed implementation of
+// PermissionRepository.
+type PermissionRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+// ProvidePermissionRepositoryMySQL is the provider for this repository.
+func ProvidePermissionRepositoryMySQL(db *infras.MySQLConn) *PermissionRepositoryMySQL {
+	s := new(PermissionRepositoryMySQL)
+	s.DB = db
+	return s
+}
+
+// CreatePermission creates a new Permission.
+func (r *PermissionRepositoryMySQL) CreatePermission(permission Permission) (err error) {
+	exists, err := r.ExistsPermissionByName(permission.Name)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = failure.Conflict("create", "permission", "already exists")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	stmt, err := r.DB.Write.PrepareNamed(permissionQueries.insertPermission)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(permission)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// DeletePermission deletes a Permission and takes it away from the roles that
+// granted it.
+func (r *PermissionRepositoryMySQL) DeletePermission(name string) (err error) {
+	exists, err := r.ExistsPermissionByName(name)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("permission")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		for _, query := range []string{
+			permissionQueries.deleteRolePermissions,
+			permissionQueries.deletePermission,
+		} {
+			if _, err := tx.Exec(query, name); err != nil {
+				logger.ErrorWithStack(err)
+				e <- err
+				return
+			}
+		}
+
+		e <- nil
+	})
+}
+
+// ExistsPermissionByName checks the existence of a Permission by its name.
+func (r *PermissionRepositoryMySQL) ExistsPermissionByName(name string) (exists bool, err error) {
+	err = r.DB.Read.Get(
+		&exists,
+		"SELECT COUNT(name) FROM permission WHERE name = ?",
+		name)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveMissingPermissions resolves the names that no Permission exists for.
+func (r *PermissionRepositoryMySQL) ResolveMissingPermissions(names []string) (missing []string, err error) {
+	if len(names) == 0 {
+		return
+	}
+
+	query, args, err := sqlx.In(permissionQueries.selectPermissionsExist, names)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	var existing []string
+	err = r.DB.Read.Select(&existing, r.DB.Read.Rebind(query), args...)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	return
+}
+
+// ResolvePermissions resolves all Permissions.
+func (r *PermissionRepositoryMySQL) ResolvePermissions() (permissions []Permission, err error) {
+	permissions = make([]Permission, 0)
+	err = r.DB.Read.Select(&permissions, permissionQueries.selectPermission+" ORDER BY name")
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
@@ -0,0 +1,239 @@
+package rbac
+
+import (
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+)
+
+// RBACService is the service interface for role-based access control.
+//
+// The roles of a user and their permissions are embedded in the JWTs issued to
+// the user, so changes take effect once the user obtains a new access token.
+type RBACService interface {
+	CreatePermission(requestFormat PermissionRequestFormat) (permission Permission, err error)
+	DeletePermission(name string) (err error)
+	ResolvePermissions() (permissions []Permission, err error)
+	CreateRole(requestFormat RoleRequestFormat) (role Role, err error)
+	DeleteRole(name string) (err error)
+	ResolveRoleByName(name string) (role Role, err error)
+	ResolveRoles() (roles []Role, err error)
+	UpdateRole(name string, requestFormat RoleRequestFormat) (role Role, err error)
+	AssignRole(name string, userID uuid.UUID, assignedBy uuid.UUID) (err error)
+	ResolveRoleAssignments(name string) (assignments []RoleAssignment, err error)
+	UnassignRole(name string, userID uuid.UUID) (err error)
+	ResolveAuthorities(userID uuid.UUID) (authorities shared.Authorities, err error)
+	BootstrapAdmin(userID uuid.UUID) (err error)
+}
+
+// RBACServiceImpl is the service implementation for role-based access
+// control.
+type RBACServiceImpl struct {
+	PermissionRepository PermissionRepository
+	RoleRepository       RoleRepository
+}
+
+// ProvideRBACServiceImpl is the provider for this service.
+func ProvideRBACServiceImpl(permissionRepository PermissionRepository, roleRepository RoleRepository) *RBACServiceImpl {
+	s := new(RBACServiceImpl)
+	s.PermissionRepository = permissionRepository
+	s.RoleRepository = roleRepository
+
+	return s
+}
+
+// CreatePermission creates a new Permission.
+func (s *RBACServiceImpl) CreatePermission(requestFormat PermissionRequestFormat) (permission Permission, err error) {
+	permission, err = NewPermissionFromRequestFormat(requestFormat)
+	if err != nil {
+		return
+	}
+
+	err = s.PermissionRepository.CreatePermission(permission)
+	return
+}
+
+// DeletePermission deletes a Permission. The roles that granted it no longer
+// do.
+func (s *RBACServiceImpl) DeletePermission(name string) (err error) {
+	return s.PermissionRepository.DeletePermission(name)
+}
+
+// ResolvePermissions resolves all Permissions.
+func (s *RBACServiceImpl) ResolvePermissions() (permissions []Permission, err error) {
+	return s.PermissionRepository.ResolvePermissions()
+}
+
+// CreateRole creates a new Role that grants existing permissions.
+func (s *RBACServiceImpl) CreateRole(requestFormat RoleRequestFormat) (role Role, err error) {
+	role, err = NewRoleFromRequestFormat(requestFormat)
+	if err != nil {
+		return
+	}
+
+	err = s.validatePermissions(role)
+	if err != nil {
+		return
+	}
+
+	err = s.RoleRepository.CreateRole(role)
+	return
+}
+
+// DeleteRole deletes a Role and takes it away from its users.
+func (s *RBACServiceImpl) DeleteRole(name string) (err error) {
+	return s.RoleRepository.DeleteRole(name)
+}
+
+// ResolveRoleByName resolves a Role by its name.
+func (s *RBACServiceImpl) ResolveRoleByName(name string) (role Role, err error) {
+	return s.RoleRepository.ResolveRoleByName(name)
+}
+
+// ResolveRoles resolves all Roles.
+func (s *RBACServiceImpl) ResolveRoles() (roles []Role, err error) {
+	return s.RoleRepository.ResolveRoles()
+}
+
+// UpdateRole updates the description of a Role and replaces the permissions
+// it grants.
+func (s *RBACServiceImpl) UpdateRole(name string, requestFormat RoleRequestFormat) (role Role, err error) {
+	role, err = s.RoleRepository.ResolveRoleByName(name)
+	if err != nil {
+		return
+	}
+
+	err = role.Update(requestFormat)
+	if err != nil {
+		return
+	}
+
+	err = s.validatePermissions(role)
+	if err != nil {
+		return
+	}
+
+	err = s.RoleRepository.UpdateRole(role)
+	return
+}
+
+// AssignRole assigns a Role to a user. Assigning a role the user already has
+// does nothing.
+func (s *RBACServiceImpl) AssignRole(name string, userID uuid.UUID, assignedBy uuid.UUID) (err error) {
+	exists, err := s.RoleRepository.ExistsRoleByName(name)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return failure.NotFound("role")
+	}
+
+	return s.RoleRepository.AssignRole(NewRoleAssignment(name, userID, assignedBy))
+}
+
+// ResolveRoleAssignments resolves the users a Role is assigned to.
+func (s *RBACServiceImpl) ResolveRoleAssignments(name string) (assignments []RoleAssignment, err error) {
+	exists, err := s.RoleRepository.ExistsRoleByName(name)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return assignments, failure.NotFound("role")
+	}
+
+	return s.RoleRepository.ResolveRoleAssignments(name)
+}
+
+// UnassignRole takes a Role away from a user.
+func (s *RBACServiceImpl) UnassignRole(name string, userID uuid.UUID) (err error) {
+	return s.RoleRepository.UnassignRole(name, userID)
+}
+
+// ResolveAuthorities resolves the roles of a user and the permissions they
+// grant, to be embedded in the JWTs of the user.
+func (s *RBACServiceImpl) ResolveAuthorities(userID uuid.UUID) (authorities shared.Authorities, err error) {
+	return s.RoleRepository.ResolveAuthoritiesByUserID(userID)
+}
+
+// BootstrapAdmin assigns the admin role to a user, so that the first admin
+// can be appointed before anyone may manage roles. The rbac:manage permission
+// and the admin role are created when they don't exist, and the role is made
+// to grant the permission again when it no longer does.
+func (s *RBACServiceImpl) BootstrapAdmin(userID uuid.UUID) (err error) {
+	exists, err := s.PermissionRepository.ExistsPermissionByName(PermissionManageRBAC)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		_, err = s.CreatePermission(PermissionRequestFormat{
+			Name:        PermissionManageRBAC,
+			Description: "Manage roles, permissions and role assignments",
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	exists, err = s.RoleRepository.ExistsRoleByName(RoleAdmin)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		_, err = s.CreateRole(RoleRequestFormat{
+			Name:        RoleAdmin,
+			Description: "Administrators",
+			Permissions: []string{PermissionManageRBAC},
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	role, err := s.RoleRepository.ResolveRoleByName(RoleAdmin)
+	if err != nil {
+		return
+	}
+
+	if !contains(role.Permissions, PermissionManageRBAC) {
+		_, err = s.UpdateRole(RoleAdmin, RoleRequestFormat{
+			Name:        RoleAdmin,
+			Description: role.Description.String,
+			Permissions: append(role.Permissions, PermissionManageRBAC),
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return s.RoleRepository.AssignRole(NewRoleAssignment(RoleAdmin, userID, userID))
+}
+
+// validatePermissions checks that every permission granted by the role
+// exists.
+func (s *RBACServiceImpl) validatePermissions(role Role) error {
+	missing, err := s.PermissionRepository.ResolveMissingPermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return failure.BadRequestFromString("unknown permissions " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package rbac
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+// RoleAdmin is the role that is assigned by BootstrapAdmin. It grants
+// PermissionManageRBAC.
+const RoleAdmin = "admin"
+
+var roleNameRegex = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+
+// Role is a named set of permissions that is assigned to users. Roles are
+// identified by their name, which can't be changed.
+type Role struct {
+	Name        string      `db:"name" validate:"required,max=100"`
+	Description null.String `db:"description"`
+	Permissions []string    `db:"-"`
+	CreatedAt   time.Time   `db:"created_at" validate:"required"`
+	UpdatedAt   null.Time   `db:"updated_at"`
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.ToResponseFormat())
+}
+
+// NewRoleFromRequestFormat creates a new Role from its request format.
+func NewRoleFromRequestFormat(req RoleRequestFormat) (role Role, err error) {
+	role = Role{
+		Name:      req.Name,
+		CreatedAt: time.Now(),
+	}
+	role.apply(req)
+
+	err = role.Validate()
+	return
+}
+
+// Update updates the description and the permissions of a Role.
+func (r *Role) Update(req RoleRequestFormat) (err error) {
+	if req.Name != r.Name {
+		return failure.BadRequestFromString("a role can't be renamed")
+	}
+
+	r.apply(req)
+	r.UpdatedAt = null.TimeFrom(time.Now())
+
+	return r.Validate()
+}
+
+// Validate validates the entity.
+func (r *Role) Validate() (err error) {
+	validator := shared.GetValidator()
+	if err = validator.Struct(r); err != nil {
+		return failure.BadRequest(err)
+	}
+
+	if !roleNameRegex.MatchString(r.Name) {
+		return failure.BadRequestFromString("role name must be lowercase, such as editor")
+	}
+
+	return
+}
+
+// ToResponseFormat converts this Role to its response format.
+func (r Role) ToResponseFormat() RoleResponseFormat {
+	permissions := r.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return RoleResponseFormat{
+		Name:        r.Name,
+		Description: r.Description.String,
+		Permissions: permissions,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func (r *Role) apply(req RoleRequestFormat) {
+	r.Description = null.NewString(req.Description, req.Description != "")
+
+	seen := make(map[string]bool)
+	r.Permissions = []string{}
+	for _, permission := range req.Permissions {
+		if !seen[permission] {
+			seen[permission] = true
+			r.Permissions = append(r.Permissions, permission)
+		}
+	}
+	sort.Strings(r.Permissions)
+}
+
+// RoleRequestFormat represents a Role's standard formatting for JSON
+// deserializing. Permissions are referred to by their name.
+type RoleRequestFormat struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+// RoleResponseFormat represents a Role's standard formatting for JSON
+// serializing.
+type RoleResponseFormat struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   null.Time `json:"updatedAt"`
+}
+
+// RoleAssignment is a Role assigned to a user.
+type RoleAssignment struct {
+	UserID    uuid.UUID `db:"user_id"`
+	Username  string    `db:"username"`
+	RoleName  string    `db:"role_name"`
+	CreatedAt time.Time `db:"created_at"`
+	CreatedBy uuid.UUID `db:"created_by"`
+}
+
+// NewRoleAssignment creates the assignment of a Role to a user.
+func NewRoleAssignment(roleName string, userID uuid.UUID, assignedBy uuid.UUID) RoleAssignment {
+	return RoleAssignment{
+		UserID:    userID,
+		RoleName:  roleName,
+		CreatedAt: time.Now(),
+		CreatedBy: assignedBy,
+	}
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (a RoleAssignment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.ToResponseFormat())
+}
+
+// ToResponseFormat converts this RoleAssignment to its response format.
+func (a RoleAssignment) ToResponseFormat() RoleAssignmentResponseFormat {
+	return RoleAssignmentResponseFormat{
+		UserID:    a.UserID,
+		Username:  a.Username,
+		Role:      a.RoleName,
+		CreatedAt: a.CreatedAt,
+		CreatedBy: a.CreatedBy,
+	}
+}
+
+// RoleAssignmentResponseFormat represents a RoleAssignment's standard
+// formatting for JSON serializing.
+type RoleAssignmentResponseFormat struct {
+	UserID    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy uuid.UUID `json:"createdBy"`
+}
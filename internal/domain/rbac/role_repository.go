@@ -0,0 +1,409 @@
+package rbac
+
+import (
+	"database/sql"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	roleQueries = struct {
+		selectRole            string
+		selectRolePermissions string
+		insertRole            string
+		updateRole            string
+		deleteRole            string
+		insertRolePermission  string
+		deleteRolePermissions string
+
+		selectRoleAssignments string
+		insertRoleAssignment  string
+		deleteRoleAssignment  string
+		deleteRoleAssignments string
+
+		selectUserRoles       string
+		selectUserPermissions string
+	}{
+		selectRole: `
+			SELECT
+				name,
+				description,
+				created_at,
+				updated_at
+			FROM role `,
+
+		selectRolePermissions: `
+			SELECT
+				role_name,
+				permission_name
+			FROM role_permission `,
+
+		insertRole: `
+			INSERT INTO role (
+				name,
+				description,
+				created_at,
+				updated_at
+			) VALUES (
+				:name,
+				:description,
+				:created_at,
+				:updated_at)`,
+
+		updateRole: `
+			UPDATE role
+			SET
+				description = :description,
+				updated_at = :updated_at
+			WHERE name = :name `,
+
+		deleteRole: `
+			DELETE FROM role WHERE name = ?`,
+
+		insertRolePermission: `
+			INSERT INTO role_permission (role_name, permission_name) VALUES (?, ?)`,
+
+		deleteRolePermissions: `
+			DELETE FROM role_permission WHERE role_name = ?`,
+
+		selectRoleAssignments: `
+			SELECT
+				ur.user_id,
+				u.username,
+				ur.role_name,
+				ur.created_at,
+				ur.created_by
+			FROM user_role ur
+			JOIN user u ON u.id = ur.user_id
+			WHERE ur.role_name = ?
+			ORDER BY ur.created_at`,
+
+		// Assigning a role twice keeps the first assignment.
+		insertRoleAssignment: `
+			INSERT IGNORE INTO user_role (
+				user_id,
+				role_name,
+				created_at,
+				created_by
+			) VALUES (
+				:user_id,
+				:role_name,
+				:created_at,
+				:created_by)`,
+
+		deleteRoleAssignment: `
+			DELETE FROM user_role WHERE role_name = ? AND user_id = ?`,
+
+		deleteRoleAssignments: `
+			DELETE FROM user_role WHERE role_name = ?`,
+
+		selectUserRoles: `
+			SELECT role_name
+			FROM user_role
+			WHERE user_id = ?
+			ORDER BY role_name`,
+
+		selectUserPermissions: `
+			SELECT DISTINCT rp.permission_name
+			FROM user_role ur
+			JOIN role_permission rp ON rp.role_name = ur.role_name
+			WHERE ur.user_id = ?
+			ORDER BY rp.permission_name`,
+	}
+)
+
+// RoleRepository is the repository for roles, the permissions they grant and
+// the users they are assigned to.
+type RoleRepository interface {
+	CreateRole(role Role) (err error)
+	DeleteRole(name string) (err error)
+	ExistsRoleByName(name string) (exists bool, err error)
+	ResolveRoleByName(name string) (role Role, err error)
+	ResolveRoles() (roles []Role, err error)
+	UpdateRole(role Role) (err error)
+	AssignRole(assignment RoleAssignment) (err error)
+	ResolveRoleAssignments(name string) (assignments []RoleAssignment, err error)
+	UnassignRole(name string, userID uuid.UUID) (err error)
+	ResolveAuthoritiesByUserID(userID uuid.UUID) (authorities shared.Authorities, err error)
+}
+
+// RoleRepositoryMySQL is the MySQL-backed implementation of RoleRepository.
+type RoleRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+// ProvideRoleRepositoryMySQL is the provider for this repository.
+func ProvideRoleRepositoryMySQL(db *infras.MySQLConn) *RoleRepositoryMySQL {
+	s := new(RoleRepositoryMySQL)
+	s.DB = db
+	return s
+}
+
+// CreateRole creates a new Role together with the permissions it grants.
+func (r *RoleRepositoryMySQL) CreateRole(role Role) (err error) {
+	exists, err := r.ExistsRoleByName(role.Name)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = failure.Conflict("create", "role", "already exists")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if err := r.txWriteRole(tx, roleQueries.insertRole, role); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// DeleteRole deletes a Role and takes it away from its users.
+func (r *RoleRepositoryMySQL) DeleteRole(name string) (err error) {
+	exists, err := r.ExistsRoleByName(name)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("role")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		for _, query := range []string{
+			roleQueries.deleteRoleAssignments,
+			roleQueries.deleteRolePermissions,
+			roleQueries.deleteRole,
+		} {
+			if _, err := tx.Exec(query, name); err != nil {
+				logger.ErrorWithStack(err)
+				e <- err
+				return
+			}
+		}
+
+		e <- nil
+	})
+}
+
+// ExistsRoleByName checks the existence of a Role by its name.
+func (r *RoleRepositoryMySQL) ExistsRoleByName(name string) (exists bool, err error) {
+	err = r.DB.Read.Get(
+		&exists,
+		"SELECT COUNT(name) FROM role WHERE name = ?",
+		name)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveRoleByName resolves a Role with its permissions by its name.
+func (r *RoleRepositoryMySQL) ResolveRoleByName(name string) (role Role, err error) {
+	err = r.DB.Read.Get(
+		&role,
+		roleQueries.selectRole+" WHERE name = ?",
+		name)
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("role")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	role.Permissions = make([]string, 0)
+	err = r.DB.Read.Select(
+		&role.Permissions,
+		"SELECT permission_name FROM role_permission WHERE role_name = ? ORDER BY permission_name",
+		name)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveRoles resolves all Roles with their permissions.
+func (r *RoleRepositoryMySQL) ResolveRoles() (roles []Role, err error) {
+	roles = make([]Role, 0)
+	err = r.DB.Read.Select(&roles, roleQueries.selectRole+" ORDER BY name")
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	var rolePermissions []struct {
+		RoleName       string `db:"role_name"`
+		PermissionName string `db:"permission_name"`
+	}
+	err = r.DB.Read.Select(&rolePermissions, roleQueries.selectRolePermissions+" ORDER BY permission_name")
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	permissions := make(map[string][]string)
+	for _, rp := range rolePermissions {
+		permissions[rp.RoleName] = append(permissions[rp.RoleName], rp.PermissionName)
+	}
+
+	for i := range roles {
+		roles[i].Permissions = permissions[roles[i].Name]
+	}
+
+	return
+}
+
+// UpdateRole updates a Role and replaces the permissions it grants.
+func (r *RoleRepositoryMySQL) UpdateRole(role Role) (err error) {
+	exists, err := r.ExistsRoleByName(role.Name)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("role")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if _, err := tx.Exec(roleQueries.deleteRolePermissions, role.Name); err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		if err := r.txWriteRole(tx, roleQueries.updateRole, role); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// AssignRole assigns a Role to a user that is not deleted.
+func (r *RoleRepositoryMySQL) AssignRole(assignment RoleAssignment) (err error) {
+	var exists bool
+	err = r.DB.Read.Get(
+		&exists,
+		"SELECT COUNT(id) FROM user WHERE id = ? AND deleted_at IS NULL",
+		assignment.UserID.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if !exists {
+		err = failure.NotFound("user")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	stmt, err := r.DB.Write.PrepareNamed(roleQueries.insertRoleAssignment)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(assignment)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveRoleAssignments resolves the users a Role is assigned to.
+func (r *RoleRepositoryMySQL) ResolveRoleAssignments(name string) (assignments []RoleAssignment, err error) {
+	assignments = make([]RoleAssignment, 0)
+	err = r.DB.Read.Select(&assignments, roleQueries.selectRoleAssignments, name)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// UnassignRole takes a Role away from a user.
+func (r *RoleRepositoryMySQL) UnassignRole(name string, userID uuid.UUID) (err error) {
+	result, err := r.DB.Write.Exec(roleQueries.deleteRoleAssignment, name, userID.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if affected == 0 {
+		err = failure.NotFound("role assignment")
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveAuthoritiesByUserID resolves the roles of a user and the permissions
+// they grant.
+func (r *RoleRepositoryMySQL) ResolveAuthoritiesByUserID(userID uuid.UUID) (authorities shared.Authorities, err error) {
+	err = r.DB.Read.Select(&authorities.Roles, roleQueries.selectUserRoles, userID.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	err = r.DB.Read.Select(&authorities.Permissions, roleQueries.selectUserPermissions, userID.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// txWriteRole inserts or updates a Role with the given query, and inserts the
+// permissions it grants.
+func (r *RoleRepositoryMySQL) txWriteRole(tx *sqlx.Tx, query string, role Role) (err error) {
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(role)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	for _, permission := range role.Permissions {
+		if _, err = tx.Exec(roleQueries.insertRolePermission, role.Name, permission); err != nil {
+			logger.ErrorWithStack(err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultDeletionRetention is how long the personal data of a deleted
+	// account is kept before it is anonymized, when none is configured.
+	DefaultDeletionRetention = 30 * 24 * time.Hour
+	// DefaultPurgeInterval is how often deleted accounts are checked for
+	// anonymization when no interval is configured.
+	DefaultPurgeInterval = time.Hour
+)
+
+// AccountPurger anonymizes deleted accounts once their retention period is
+// over.
+type AccountPurger struct {
+	UserRepository UserRepository
+	retention      time.Duration
+	interval       time.Duration
+}
+
+// ProvideAccountPurger is the provider for AccountPurger.
+func ProvideAccountPurger(userRepository UserRepository, config *configs.Config) *AccountPurger {
+	deletionConfig := config.App.User.Deletion
+
+	p := &AccountPurger{
+		UserRepository: userRepository,
+		retention:      time.Duration(deletionConfig.RetentionSeconds) * time.Second,
+		interval:       time.Duration(deletionConfig.PurgeIntervalSeconds) * time.Second,
+	}
+
+	if p.retention <= 0 {
+		p.retention = DefaultDeletionRetention
+	}
+	if p.interval <= 0 {
+		p.interval = DefaultPurgeInterval
+	}
+
+	return p
+}
+
+// Start purges deleted accounts in the background, every purge interval.
+func (p *AccountPurger) Start() {
+	go p.run()
+
+	log.Info().
+		Dur("retention", p.retention).
+		Dur("interval", p.interval).
+		Msg("Account purger started.")
+}
+
+// Purge anonymizes the accounts that were deleted longer than the retention
+// period ago.
+func (p *AccountPurger) Purge() (anonymized int64, err error) {
+	anonymized, err = p.UserRepository.AnonymizeDeletedUsers(time.Now().Add(-p.retention))
+	if err != nil {
+		return
+	}
+
+	if anonymized > 0 {
+		log.Info().Int64("count", anonymized).Msg("Deleted accounts anonymized.")
+	}
+
+	return
+}
+
+func (p *AccountPurger) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(); err != nil {
+			logger.ErrorWithStack(err)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,297 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// DefaultDataExportExpiration is how long a generated archive can be
+	// downloaded when no expiry is configured.
+	DefaultDataExportExpiration = 24 * time.Hour
+	// DefaultDataExportTimeout is how long an export may stay pending before a
+	// new request starts another one.
+	DefaultDataExportTimeout = 15 * time.Minute
+)
+
+// DataExportStatus is the state of a data export.
+type DataExportStatus string
+
+const (
+	// DataExportStatusPending indicates an export that waits for a worker.
+	DataExportStatusPending DataExportStatus = "pending"
+	// DataExportStatusProcessing indicates an export that is being generated.
+	DataExportStatusProcessing DataExportStatus = "processing"
+	// DataExportStatusReady indicates an export whose archive can be
+	// downloaded.
+	DataExportStatusReady DataExportStatus = "ready"
+	// DataExportStatusFailed indicates an export that could not be generated.
+	DataExportStatusFailed DataExportStatus = "failed"
+)
+
+// DataExportFormat is the format of a data export archive.
+type DataExportFormat string
+
+const (
+	// DataExportFormatJSON is a single JSON document.
+	DataExportFormatJSON DataExportFormat = "json"
+	// DataExportFormatZIP is a ZIP archive with a JSON document per section.
+	DataExportFormatZIP DataExportFormat = "zip"
+)
+
+// IsValid checks whether the format is supported.
+func (f DataExportFormat) IsValid() bool {
+	return f == DataExportFormatJSON || f == DataExportFormatZIP
+}
+
+// DataExport is a request of a user for a copy of their personal data. The
+// archive is generated in the background and kept until the export expires.
+type DataExport struct {
+	ID          uuid.UUID        `db:"id"`
+	UserID      uuid.UUID        `db:"user_id"`
+	Format      DataExportFormat `db:"format"`
+	Status      DataExportStatus `db:"status"`
+	CreatedAt   time.Time        `db:"created_at"`
+	CompletedAt null.Time        `db:"completed_at"`
+	ExpiresAt   null.Time        `db:"expires_at"`
+}
+
+// NewDataExport creates a pending export of the data of a user.
+func NewDataExport(userID uuid.UUID, format DataExportFormat) (export DataExport, err error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	export = DataExport{
+		ID:        id,
+		UserID:    userID,
+		Format:    format,
+		Status:    DataExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	return
+}
+
+// IsInProgress checks whether the export has not finished yet.
+func (e *DataExport) IsInProgress() bool {
+	return e.Status == DataExportStatusPending || e.Status == DataExportStatusProcessing
+}
+
+// IsExpired checks whether the archive of the export is past its expiry time.
+func (e *DataExport) IsExpired() bool {
+	return e.ExpiresAt.Valid && time.Now().After(e.ExpiresAt.Time)
+}
+
+func (e DataExport) ToResponseFormat() DataExportResponseFormat {
+	return DataExportResponseFormat{
+		ID:          e.ID,
+		Format:      e.Format,
+		Status:      e.Status,
+		CreatedAt:   e.CreatedAt,
+		CompletedAt: e.CompletedAt,
+		ExpiresAt:   e.ExpiresAt,
+	}
+}
+
+// DataExportResponseFormat is the status of a data export.
+type DataExportResponseFormat struct {
+	ID          uuid.UUID        `json:"id"`
+	Format      DataExportFormat `json:"format"`
+	Status      DataExportStatus `json:"status"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	CompletedAt null.Time        `json:"completedAt"`
+	ExpiresAt   null.Time        `json:"expiresAt"`
+}
+
+// DataExportArchive is the generated archive of a data export.
+type DataExportArchive struct {
+	Filename    string `db:"filename"`
+	ContentType string `db:"content_type"`
+	Content     []byte `db:"archive"`
+}
+
+// DataExportContent is everything the service holds about a user. Secrets,
+// such as password hashes and token values, are left out.
+//
+// There is no separate login history or audit log: sessions and logins are
+// derived from the refresh tokens issued at login, and audit entries from the
+// change stamps of the account, its email verifications and password resets.
+type DataExportContent struct {
+	ExportedAt          time.Time                     `json:"exportedAt"`
+	Profile             UserResponseFormat            `json:"profile"`
+	Sessions            []DataExportSession           `json:"sessions"`
+	LoginHistory        []DataExportLogin             `json:"loginHistory"`
+	OAuthAccessTokens   []DataExportOAuthToken        `json:"oauthAccessTokens"`
+	OAuthRefreshTokens  []DataExportOAuthToken        `json:"oauthRefreshTokens"`
+	OAuthAuthorizations []DataExportOAuthGrant        `json:"oauthAuthorizations"`
+	OAuthClients        []DataExportOAuthClient       `json:"oauthClients"`
+	Audit               []DataExportAuditEntry        `json:"audit"`
+	EmailVerifications  []DataExportEmailVerification `json:"emailVerifications"`
+	PasswordResets      []DataExportPasswordReset     `json:"passwordResets"`
+}
+
+// DataExportSession is a login session, i.e. a refresh token family.
+type DataExportSession struct {
+	ID              uuid.UUID `db:"family_id" json:"id"`
+	StartedAt       time.Time `db:"started_at" json:"startedAt"`
+	LastRefreshedAt time.Time `db:"last_refreshed_at" json:"lastRefreshedAt"`
+	ExpiresAt       time.Time `db:"expires_at" json:"expiresAt"`
+	Active          bool      `db:"active" json:"active"`
+}
+
+// DataExportLogin is a successful login.
+type DataExportLogin struct {
+	At       time.Time   `db:"at" json:"at"`
+	Method   string      `db:"method" json:"method"`
+	ClientID null.String `db:"client_id" json:"clientId"`
+}
+
+// DataExportOAuthToken is an OAuth token issued on behalf of the user.
+type DataExportOAuthToken struct {
+	ClientID  string      `db:"client_id" json:"clientId"`
+	Scope     null.String `db:"scope" json:"scope"`
+	CreatedAt null.Time   `db:"created_at" json:"createdAt"`
+	ExpiresAt time.Time   `db:"expires" json:"expiresAt"`
+	RevokedAt null.Time   `db:"revoked_at" json:"revokedAt"`
+}
+
+// DataExportOAuthGrant is an authorization the user gave to an OAuth client.
+type DataExportOAuthGrant struct {
+	ClientID    string      `db:"client_id" json:"clientId"`
+	RedirectURI string      `db:"redirect_uri" json:"redirectUri"`
+	Scope       null.String `db:"scope" json:"scope"`
+	AuthTime    null.Time   `db:"auth_time" json:"authTime"`
+	CreatedAt   time.Time   `db:"created_at" json:"createdAt"`
+	UsedAt      null.Time   `db:"used_at" json:"usedAt"`
+}
+
+// DataExportOAuthClient is an OAuth client owned by the user.
+type DataExportOAuthClient struct {
+	ClientID    string      `db:"client_id" json:"clientId"`
+	Name        null.String `db:"name" json:"name"`
+	RedirectURI null.String `db:"redirect_uri" json:"redirectUri"`
+	GrantTypes  string      `db:"grant_types" json:"grantTypes"`
+	Scope       null.String `db:"scope" json:"scope"`
+	CreatedAt   time.Time   `db:"created_at" json:"createdAt"`
+	UpdatedAt   null.Time   `db:"updated_at" json:"updatedAt"`
+	DisabledAt  null.Time   `db:"disabled_at" json:"disabledAt"`
+}
+
+// DataExportEmailVerification is a verification email sent to the user.
+type DataExportEmailVerification struct {
+	Email     string    `db:"email" json:"email"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ExpiresAt time.Time `db:"expires_at" json:"expiresAt"`
+	UsedAt    null.Time `db:"used_at" json:"usedAt"`
+}
+
+// DataExportPasswordReset is a password reset email sent to the user.
+type DataExportPasswordReset struct {
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ExpiresAt time.Time `db:"expires_at" json:"expiresAt"`
+	UsedAt    null.Time `db:"used_at" json:"usedAt"`
+}
+
+// DataExportAuditEntry is a change made to the account.
+type DataExportAuditEntry struct {
+	Action string     `json:"action"`
+	At     time.Time  `json:"at"`
+	By     *uuid.UUID `json:"by,omitempty"`
+}
+
+// BuildAudit derives the audit entries from the change stamps of the profile,
+// the email verifications and the password resets.
+func (c *DataExportContent) BuildAudit() {
+	profile := c.Profile
+	audit := []DataExportAuditEntry{{Action: "account.created", At: profile.CreatedAt, By: &profile.CreatedBy}}
+	if profile.UpdatedAt.Valid {
+		audit = append(audit, DataExportAuditEntry{Action: "account.updated", At: profile.UpdatedAt.Time, By: profile.UpdatedBy})
+	}
+	if profile.EmailVerifiedAt.Valid {
+		audit = append(audit, DataExportAuditEntry{Action: "email.verified", At: profile.EmailVerifiedAt.Time, By: &profile.ID})
+	}
+	if profile.DeletedAt.Valid {
+		audit = append(audit, DataExportAuditEntry{Action: "account.deleted", At: profile.DeletedAt.Time, By: profile.DeletedBy})
+	}
+
+	for _, verification := range c.EmailVerifications {
+		audit = append(audit, DataExportAuditEntry{Action: "email_verification.requested", At: verification.CreatedAt})
+	}
+
+	for _, reset := range c.PasswordResets {
+		audit = append(audit, DataExportAuditEntry{Action: "password_reset.requested", At: reset.CreatedAt})
+		if reset.UsedAt.Valid {
+			audit = append(audit, DataExportAuditEntry{Action: "password_reset.completed", At: reset.UsedAt.Time, By: &profile.ID})
+		}
+	}
+
+	c.Audit = audit
+}
+
+// Archive encodes the content in the given format.
+func (c DataExportContent) Archive(format DataExportFormat) (archive DataExportArchive, err error) {
+	basename := fmt.Sprintf("data-export-%s", c.ExportedAt.Format("20060102T150405"))
+
+	if format == DataExportFormatJSON {
+		archive.Filename, archive.ContentType = basename+".json", "application/json"
+		archive.Content, err = json.MarshalIndent(c, "", "  ")
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+
+	for _, section := range []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", c.Profile},
+		{"sessions.json", c.Sessions},
+		{"login_history.json", c.LoginHistory},
+		{"oauth_access_tokens.json", c.OAuthAccessTokens},
+		{"oauth_refresh_tokens.json", c.OAuthRefreshTokens},
+		{"oauth_authorizations.json", c.OAuthAuthorizations},
+		{"oauth_clients.json", c.OAuthClients},
+		{"email_verifications.json", c.EmailVerifications},
+		{"password_resets.json", c.PasswordResets},
+		{"audit.json", c.Audit},
+	} {
+		b, err := json.MarshalIndent(section.content, "", "  ")
+		if err != nil {
+			return archive, err
+		}
+
+		w, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     basename + "/" + section.name,
+			Method:   zip.Deflate,
+			Modified: c.ExportedAt,
+		})
+		if err != nil {
+			return archive, err
+		}
+
+		if _, err := w.Write(b); err != nil {
+			return archive, err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return
+	}
+
+	archive = DataExportArchive{
+		Filename:    basename + ".zip",
+		ContentType: "application/zip",
+		Content:     buf.Bytes(),
+	}
+
+	return
+}
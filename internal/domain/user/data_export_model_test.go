@@ -0,0 +1,56 @@
+package user_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataExportContent(t *testing.T) {
+	userID, _ := uuid.NewV4()
+	now := time.Now()
+	content := user.DataExportContent{
+		ExportedAt: now,
+		Profile:    user.UserResponseFormat{ID: userID, CreatedAt: now, CreatedBy: userID},
+		PasswordResets: []user.DataExportPasswordReset{
+			{CreatedAt: now, ExpiresAt: now.Add(time.Hour), UsedAt: null.TimeFrom(now)},
+		},
+	}
+	content.BuildAudit()
+
+	t.Run("Audit", func(t *testing.T) {
+		actions := []string{}
+		for _, entry := range content.Audit {
+			actions = append(actions, entry.Action)
+		}
+		assert.Equal(t, []string{"account.created", "password_reset.requested", "password_reset.completed"}, actions)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		archive, err := content.Archive(user.DataExportFormatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", archive.ContentType)
+
+		var decoded map[string]interface{}
+		assert.NoError(t, json.Unmarshal(archive.Content, &decoded))
+		assert.Contains(t, decoded, "profile")
+		assert.Contains(t, decoded, "audit")
+	})
+
+	t.Run("ZIP", func(t *testing.T) {
+		archive, err := content.Archive(user.DataExportFormatZIP)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/zip", archive.ContentType)
+
+		reader, err := zip.NewReader(bytes.NewReader(archive.Content), int64(len(archive.Content)))
+		assert.NoError(t, err)
+		assert.Len(t, reader.File, 10)
+	})
+}
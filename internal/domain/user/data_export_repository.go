@@ -0,0 +1,395 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	dataExportQueries = struct {
+		selectDataExport         string
+		selectDataExportArchive  string
+		insertDataExport         string
+		updateDataExportStatus   string
+		completeDataExport       string
+		deleteExpiredDataExports string
+
+		selectSessions            string
+		selectLoginHistory        string
+		selectOAuthAccessTokens   string
+		selectOAuthRefreshTokens  string
+		selectOAuthAuthorizations string
+		selectOAuthClients        string
+		selectEmailVerifications  string
+		selectPasswordResets      string
+	}{
+		selectDataExport: `
+			SELECT
+				id,
+				user_id,
+				format,
+				status,
+				created_at,
+				completed_at,
+				expires_at
+			FROM user_data_export
+		`,
+
+		selectDataExportArchive: `
+			SELECT
+				filename,
+				content_type,
+				archive
+			FROM user_data_export
+			WHERE
+				id = ? AND status = 'ready'
+		`,
+
+		insertDataExport: `
+			INSERT INTO user_data_export (
+				id,
+				user_id,
+				format,
+				status,
+				created_at,
+				completed_at,
+				expires_at
+			) VALUES (
+				:id,
+				:user_id,
+				:format,
+				:status,
+				:created_at,
+				:completed_at,
+				:expires_at
+			)
+		`,
+
+		updateDataExportStatus: `
+			UPDATE user_data_export
+			SET
+				status = ?,
+				completed_at = ?
+			WHERE
+				id = ?
+		`,
+
+		completeDataExport: `
+			UPDATE user_data_export
+			SET
+				status = 'ready',
+				filename = ?,
+				content_type = ?,
+				archive = ?,
+				completed_at = ?,
+				expires_at = ?
+			WHERE
+				id = ?
+		`,
+
+		deleteExpiredDataExports: `
+			DELETE FROM user_data_export
+			WHERE
+				expires_at < ?
+		`,
+
+		selectSessions: `
+			SELECT
+				family_id,
+				MIN(created_at) AS started_at,
+				MAX(created_at) AS last_refreshed_at,
+				MAX(expires_at) AS expires_at,
+				SUM(revoked_at IS NULL AND expires_at > ?) > 0 AS active
+			FROM user_refresh_token
+			WHERE
+				user_id = ?
+			GROUP BY family_id
+			ORDER BY started_at
+		`,
+
+		// A refresh token family starts at login or registration, an
+		// authorization code is issued after the user logged in to authorize
+		// an OAuth client.
+		selectLoginHistory: `
+			SELECT
+				MIN(created_at) AS at,
+				'password' AS method,
+				NULL AS client_id
+			FROM user_refresh_token
+			WHERE
+				user_id = ?
+			GROUP BY family_id
+			UNION ALL
+			SELECT
+				auth_time AS at,
+				'authorization_code' AS method,
+				client_id
+			FROM oauth_authorization_codes
+			WHERE
+				user_id = ? AND auth_time IS NOT NULL
+			ORDER BY at
+		`,
+
+		selectOAuthAccessTokens: `
+			SELECT
+				client_id,
+				scope,
+				NULL AS created_at,
+				expires,
+				NULL AS revoked_at
+			FROM oauth_access_tokens
+			WHERE
+				user_id = ?
+			ORDER BY expires
+		`,
+
+		selectOAuthRefreshTokens: `
+			SELECT
+				client_id,
+				scope,
+				created_at,
+				expires,
+				revoked_at
+			FROM oauth_refresh_tokens
+			WHERE
+				user_id = ?
+			ORDER BY created_at
+		`,
+
+		selectOAuthAuthorizations: `
+			SELECT
+				client_id,
+				redirect_uri,
+				scope,
+				auth_time,
+				created_at,
+				used_at
+			FROM oauth_authorization_codes
+			WHERE
+				user_id = ?
+			ORDER BY created_at
+		`,
+
+		selectOAuthClients: `
+			SELECT
+				client_id,
+				name,
+				redirect_uri,
+				grant_types,
+				scope,
+				created_at,
+				updated_at,
+				disabled_at
+			FROM oauth_clients
+			WHERE
+				user_id = ?
+			ORDER BY created_at
+		`,
+
+		selectEmailVerifications: `
+			SELECT
+				email,
+				created_at,
+				expires_at,
+				used_at
+			FROM user_email_verification
+			WHERE
+				user_id = ?
+			ORDER BY created_at
+		`,
+
+		selectPasswordResets: `
+			SELECT
+				created_at,
+				expires_at,
+				used_at
+			FROM user_password_reset
+			WHERE
+				user_id = ?
+			ORDER BY created_at
+		`,
+	}
+)
+
+type DataExportRepository interface {
+	CreateDataExport(export DataExport) (err error)
+	ResolveDataExportByID(id uuid.UUID) (export DataExport, err error)
+	ResolveLatestDataExportByUserID(userID uuid.UUID, format DataExportFormat) (export DataExport, err error)
+	ResolveDataExportArchive(id uuid.UUID) (archive DataExportArchive, err error)
+	UpdateDataExportStatus(id uuid.UUID, status DataExportStatus) (err error)
+	CompleteDataExport(id uuid.UUID, archive DataExportArchive, expiresAt time.Time) (err error)
+	DeleteExpiredDataExports(now time.Time) (err error)
+	ResolveDataExportContent(userID uuid.UUID) (content DataExportContent, err error)
+}
+
+type DataExportRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvideDataExportRepositoryMySQL(db *infras.MySQLConn) *DataExportRepositoryMySQL {
+	s := new(DataExportRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *DataExportRepositoryMySQL) CreateDataExport(export DataExport) (err error) {
+	stmt, err := r.DB.Write.PrepareNamed(dataExportQueries.insertDataExport)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(export)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *DataExportRepositoryMySQL) ResolveDataExportByID(id uuid.UUID) (export DataExport, err error) {
+	err = r.DB.Write.Get(
+		&export,
+		dataExportQueries.selectDataExport+" WHERE id = ?",
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("data export")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveLatestDataExportByUserID resolves the export in the given format
+// that was requested last by a user.
+func (r *DataExportRepositoryMySQL) ResolveLatestDataExportByUserID(userID uuid.UUID, format DataExportFormat) (export DataExport, err error) {
+	err = r.DB.Write.Get(
+		&export,
+		dataExportQueries.selectDataExport+" WHERE user_id = ? AND format = ? ORDER BY created_at DESC LIMIT 1",
+		userID.String(),
+		format)
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("data export")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *DataExportRepositoryMySQL) ResolveDataExportArchive(id uuid.UUID) (archive DataExportArchive, err error) {
+	err = r.DB.Write.Get(
+		&archive,
+		dataExportQueries.selectDataExportArchive,
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("data export archive")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// UpdateDataExportStatus moves an export to another status. An export that
+// failed is completed as well.
+func (r *DataExportRepositoryMySQL) UpdateDataExportStatus(id uuid.UUID, status DataExportStatus) (err error) {
+	var completedAt *time.Time
+	if status == DataExportStatusFailed {
+		now := time.Now()
+		completedAt = &now
+	}
+
+	_, err = r.DB.Write.Exec(dataExportQueries.updateDataExportStatus, status, completedAt, id.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// CompleteDataExport stores the generated archive and marks the export as
+// ready for download until expiresAt.
+func (r *DataExportRepositoryMySQL) CompleteDataExport(id uuid.UUID, archive DataExportArchive, expiresAt time.Time) (err error) {
+	_, err = r.DB.Write.Exec(
+		dataExportQueries.completeDataExport,
+		archive.Filename,
+		archive.ContentType,
+		archive.Content,
+		time.Now(),
+		expiresAt,
+		id.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *DataExportRepositoryMySQL) DeleteExpiredDataExports(now time.Time) (err error) {
+	_, err = r.DB.Write.Exec(dataExportQueries.deleteExpiredDataExports, now)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveDataExportContent collects the data of a user held outside the user
+// table. The profile is left to the caller.
+func (r *DataExportRepositoryMySQL) ResolveDataExportContent(userID uuid.UUID) (content DataExportContent, err error) {
+	id := userID.String()
+	content = DataExportContent{
+		Sessions:            []DataExportSession{},
+		LoginHistory:        []DataExportLogin{},
+		OAuthAccessTokens:   []DataExportOAuthToken{},
+		OAuthRefreshTokens:  []DataExportOAuthToken{},
+		OAuthAuthorizations: []DataExportOAuthGrant{},
+		OAuthClients:        []DataExportOAuthClient{},
+		EmailVerifications:  []DataExportEmailVerification{},
+		PasswordResets:      []DataExportPasswordReset{},
+	}
+
+	for _, section := range []struct {
+		dest  interface{}
+		query string
+		args  []interface{}
+	}{
+		{&content.Sessions, dataExportQueries.selectSessions, []interface{}{time.Now(), id}},
+		{&content.LoginHistory, dataExportQueries.selectLoginHistory, []interface{}{id, id}},
+		{&content.OAuthAccessTokens, dataExportQueries.selectOAuthAccessTokens, []interface{}{id}},
+		{&content.OAuthRefreshTokens, dataExportQueries.selectOAuthRefreshTokens, []interface{}{id}},
+		{&content.OAuthAuthorizations, dataExportQueries.selectOAuthAuthorizations, []interface{}{id}},
+		{&content.OAuthClients, dataExportQueries.selectOAuthClients, []interface{}{id}},
+		{&content.EmailVerifications, dataExportQueries.selectEmailVerifications, []interface{}{id}},
+		{&content.PasswordResets, dataExportQueries.selectPasswordResets, []interface{}{id}},
+	} {
+		if err = sqlx.Select(r.DB.Read, section.dest, section.query, section.args...); err != nil {
+			logger.ErrorWithStack(err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,185 @@
+package user
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dataExportTopic is the PubSub topic exports are generated from.
+	dataExportTopic = "user.data_export"
+	// dataExportWorkers is the number of exports generated at the same time.
+	dataExportWorkers = 1
+	// dataExportBuffer is how many exports may wait for a worker before
+	// requesting another one blocks.
+	dataExportBuffer = 100
+)
+
+// DataExportService generates copies of the personal data of users.
+type DataExportService interface {
+	RequestDataExport(userID uuid.UUID, format DataExportFormat) (export DataExport, err error)
+	ResolveDataExport(userID uuid.UUID, id uuid.UUID) (export DataExport, err error)
+	ResolveDataExportArchive(userID uuid.UUID, id uuid.UUID) (archive DataExportArchive, err error)
+}
+
+// DataExportServiceImpl generates exports in the background on a
+// shared.PubSub worker pool.
+type DataExportServiceImpl struct {
+	DataExportRepository DataExportRepository
+	UserRepository       UserRepository
+	Config               *configs.Config
+	pubsub               shared.PubSub
+}
+
+// ProvideDataExportServiceImpl is the provider for DataExportServiceImpl. It
+// starts the export workers.
+func ProvideDataExportServiceImpl(dataExportRepository DataExportRepository, userRepository UserRepository, config *configs.Config) *DataExportServiceImpl {
+	s := new(DataExportServiceImpl)
+	s.DataExportRepository = dataExportRepository
+	s.UserRepository = userRepository
+	s.Config = config
+	s.pubsub = shared.New(dataExportWorkers, shared.SetMessageBuffer(dataExportBuffer))
+
+	s.pubsub.SubscriberRegistry(dataExportTopic, s.process)
+	s.pubsub.Start()
+
+	return s
+}
+
+// RequestDataExport starts an export of the data of a user. While an export in
+// the same format is still being generated, that export is returned instead
+// of starting another one.
+func (s *DataExportServiceImpl) RequestDataExport(userID uuid.UUID, format DataExportFormat) (export DataExport, err error) {
+	if !format.IsValid() {
+		return export, failure.BadRequestFromString("unsupported export format")
+	}
+
+	latest, err := s.DataExportRepository.ResolveLatestDataExportByUserID(userID, format)
+	if err != nil && failure.GetCode(err) != http.StatusNotFound {
+		return
+	}
+
+	if err == nil && latest.IsInProgress() && time.Since(latest.CreatedAt) < DefaultDataExportTimeout {
+		return latest, nil
+	}
+
+	export, err = NewDataExport(userID, format)
+	if err != nil {
+		return export, failure.InternalError(err)
+	}
+
+	err = s.DataExportRepository.CreateDataExport(export)
+	if err != nil {
+		return
+	}
+
+	s.pubsub.Publish(dataExportTopic, export.ID.Bytes())
+
+	return
+}
+
+// ResolveDataExport resolves an export of the data of the given user.
+func (s *DataExportServiceImpl) ResolveDataExport(userID uuid.UUID, id uuid.UUID) (export DataExport, err error) {
+	export, err = s.DataExportRepository.ResolveDataExportByID(id)
+	if err != nil {
+		return
+	}
+
+	if export.UserID != userID || export.IsExpired() {
+		return export, failure.NotFound("data export")
+	}
+
+	return
+}
+
+// ResolveDataExportArchive resolves the archive of a finished export of the
+// data of the given user.
+func (s *DataExportServiceImpl) ResolveDataExportArchive(userID uuid.UUID, id uuid.UUID) (archive DataExportArchive, err error) {
+	export, err := s.ResolveDataExport(userID, id)
+	if err != nil {
+		return
+	}
+
+	if export.Status != DataExportStatusReady {
+		return archive, failure.Conflict("download", "data export", "export is "+string(export.Status))
+	}
+
+	return s.DataExportRepository.ResolveDataExportArchive(id)
+}
+
+// process generates the archive of the export with the given ID. Failures are
+// recorded on the export rather than retried.
+func (s *DataExportServiceImpl) process(message []byte) error {
+	id, err := uuid.FromBytes(message)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return nil
+	}
+
+	if err := s.DataExportRepository.DeleteExpiredDataExports(time.Now()); err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	export, err := s.DataExportRepository.ResolveDataExportByID(id)
+	if err != nil {
+		return nil
+	}
+
+	if err := s.DataExportRepository.UpdateDataExportStatus(id, DataExportStatusProcessing); err != nil {
+		return nil
+	}
+
+	archive, err := s.generate(export)
+	if err == nil {
+		err = s.DataExportRepository.CompleteDataExport(id, archive, time.Now().Add(s.dataExportExpiration()))
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+		if err := s.DataExportRepository.UpdateDataExportStatus(id, DataExportStatusFailed); err != nil {
+			logger.ErrorWithStack(err)
+		}
+		return nil
+	}
+
+	log.Info().
+		Str("userId", export.UserID.String()).
+		Str("exportId", export.ID.String()).
+		Int("bytes", len(archive.Content)).
+		Msg("Data export generated.")
+
+	return nil
+}
+
+func (s *DataExportServiceImpl) generate(export DataExport) (archive DataExportArchive, err error) {
+	user, err := s.UserRepository.ResolveByID(export.UserID)
+	if err != nil {
+		return
+	}
+
+	content, err := s.DataExportRepository.ResolveDataExportContent(export.UserID)
+	if err != nil {
+		return
+	}
+
+	content.ExportedAt = time.Now()
+	content.Profile = user.ToResponseFormat()
+	content.BuildAudit()
+
+	return content.Archive(export.Format)
+}
+
+func (s *DataExportServiceImpl) dataExportExpiration() time.Duration {
+	if s.Config.App.User.DataExport.ExpirySeconds <= 0 {
+		return DefaultDataExportExpiration
+	}
+
+	return time.Duration(s.Config.App.User.DataExport.ExpirySeconds) * time.Second
+}
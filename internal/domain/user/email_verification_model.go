@@ -0,0 +1,100 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// DefaultEmailVerificationExpiration is used when no verification token
+	// expiry is configured.
+	DefaultEmailVerificationExpiration = 24 * time.Hour
+	// DefaultEmailVerificationResendInterval is the minimum time between two
+	// verification emails to the same user when none is configured.
+	DefaultEmailVerificationResendInterval = time.Minute
+)
+
+// EmailVerification is a pending verification of the email address of a user.
+//
+// The token sent to the user is the ID of the verification together with an
+// HMAC signature over the ID and the address, so that a token can neither be
+// guessed nor be used for an address the user changed to afterwards. A token
+// can be used only once.
+type EmailVerification struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+	UsedAt    null.Time `db:"used_at"`
+}
+
+// NewEmailVerification creates a verification of the email address of a user
+// and returns it together with the token that is sent to the user.
+func NewEmailVerification(userID uuid.UUID, email string, ttl time.Duration, secret string) (verification EmailVerification, token string, err error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	verification = EmailVerification{
+		ID:        id,
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	token = id.String() + "." + verification.signature(secret)
+	return
+}
+
+// ParseEmailVerificationToken returns the ID of the verification a token was
+// issued for. The signature is checked with VerifySignature once the
+// verification has been resolved.
+func ParseEmailVerificationToken(token string) (id uuid.UUID, signature string, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return
+	}
+
+	id, err := uuid.FromString(parts[0])
+	if err != nil {
+		return
+	}
+
+	return id, parts[1], true
+}
+
+// VerifySignature checks the signature of a token issued for this verification.
+func (v *EmailVerification) VerifySignature(signature string, secret string) bool {
+	return hmac.Equal([]byte(signature), []byte(v.signature(secret)))
+}
+
+// IsUsed checks whether the verification has already been used.
+func (v *EmailVerification) IsUsed() bool {
+	return v.UsedAt.Valid
+}
+
+// IsExpired checks whether the verification is past its expiry time.
+func (v *EmailVerification) IsExpired() bool {
+	return time.Now().After(v.ExpiresAt)
+}
+
+func (v *EmailVerification) signature(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(v.ID.String() + ":" + v.UserID.String() + ":" + v.Email))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyEmailRequestFormat is the request to verify an email address.
+type VerifyEmailRequestFormat struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,40 @@
+package user_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailVerification(t *testing.T) {
+	userID, _ := uuid.NewV4()
+	verification, token, err := user.NewEmailVerification(userID, "john@example.com", time.Hour, "secret")
+	assert.NoError(t, err)
+
+	t.Run("Valid token", func(t *testing.T) {
+		id, signature, ok := user.ParseEmailVerificationToken(token)
+		assert.True(t, ok)
+		assert.Equal(t, verification.ID, id)
+		assert.True(t, verification.VerifySignature(signature, "secret"))
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		_, signature, _ := user.ParseEmailVerificationToken(token)
+		assert.False(t, verification.VerifySignature(signature, "other"))
+	})
+
+	t.Run("Changed email", func(t *testing.T) {
+		_, signature, _ := user.ParseEmailVerificationToken(token)
+		changed := verification
+		changed.Email = "jane@example.com"
+		assert.False(t, changed.VerifySignature(signature, "secret"))
+	})
+
+	t.Run("Malformed token", func(t *testing.T) {
+		_, _, ok := user.ParseEmailVerificationToken("not-a-token")
+		assert.False(t, ok)
+	})
+}
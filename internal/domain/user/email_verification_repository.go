@@ -0,0 +1,203 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	emailVerificationQueries = struct {
+		selectEmailVerification string
+		insertEmailVerification string
+		useEmailVerification    string
+		verifyUserEmail         string
+	}{
+		selectEmailVerification: `
+			SELECT
+				id,
+				user_id,
+				email,
+				expires_at,
+				created_at,
+				used_at
+			FROM user_email_verification
+		`,
+
+		insertEmailVerification: `
+			INSERT INTO user_email_verification (
+				id,
+				user_id,
+				email,
+				expires_at,
+				created_at,
+				used_at
+			) VALUES (
+				:id,
+				:user_id,
+				:email,
+				:expires_at,
+				:created_at,
+				:used_at
+			)
+		`,
+
+		useEmailVerification: `
+			UPDATE user_email_verification
+			SET
+				used_at = ?
+			WHERE
+				id = ? AND used_at IS NULL
+		`,
+
+		verifyUserEmail: `
+			UPDATE user
+			SET
+				email_verified_at = ?
+			WHERE
+				id = ? AND email = ?
+		`,
+	}
+)
+
+// errEmailVerificationAlreadyUsed is returned inside the verification
+// transaction when another request used the same token first.
+var errEmailVerificationAlreadyUsed = errors.New("email verification already used")
+
+type EmailVerificationRepository interface {
+	CreateEmailVerification(verification EmailVerification) (err error)
+	ResolveEmailVerificationByID(id uuid.UUID) (verification EmailVerification, err error)
+	ResolveLatestEmailVerificationByUserID(userID uuid.UUID) (verification EmailVerification, err error)
+	UseEmailVerification(verification EmailVerification) (verified bool, err error)
+}
+
+type EmailVerificationRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvideEmailVerificationRepositoryMySQL(db *infras.MySQLConn) *EmailVerificationRepositoryMySQL {
+	s := new(EmailVerificationRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *EmailVerificationRepositoryMySQL) CreateEmailVerification(verification EmailVerification) (err error) {
+	stmt, err := r.DB.Write.PrepareNamed(emailVerificationQueries.insertEmailVerification)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(verification)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *EmailVerificationRepositoryMySQL) ResolveEmailVerificationByID(id uuid.UUID) (verification EmailVerification, err error) {
+	err = r.DB.Write.Get(
+		&verification,
+		emailVerificationQueries.selectEmailVerification+" WHERE id = ?",
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("email verification")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveLatestEmailVerificationByUserID resolves the verification that was
+// created last for a user, which is used to throttle resending.
+func (r *EmailVerificationRepositoryMySQL) ResolveLatestEmailVerificationByUserID(userID uuid.UUID) (verification EmailVerification, err error) {
+	err = r.DB.Write.Get(
+		&verification,
+		emailVerificationQueries.selectEmailVerification+" WHERE user_id = ? ORDER BY created_at DESC LIMIT 1",
+		userID.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("email verification")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// UseEmailVerification marks the verification as used and the address as
+// verified in a single transaction. It reports verified as false, without
+// error, when the verification was already used, or when the user has changed
+// to another address since.
+func (r *EmailVerificationRepositoryMySQL) UseEmailVerification(verification EmailVerification) (verified bool, err error) {
+	err = r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		now := time.Now()
+		result, err := tx.Exec(
+			emailVerificationQueries.useEmailVerification,
+			now,
+			verification.ID.String())
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		if affected == 0 {
+			e <- errEmailVerificationAlreadyUsed
+			return
+		}
+
+		result, err = tx.Exec(
+			emailVerificationQueries.verifyUserEmail,
+			now,
+			verification.UserID.String(),
+			verification.Email)
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		affected, err = result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		if affected == 0 {
+			e <- errEmailVerificationAlreadyUsed
+			return
+		}
+
+		e <- nil
+	})
+
+	if err == errEmailVerificationAlreadyUsed {
+		return false, nil
+	}
+
+	return err == nil, err
+}
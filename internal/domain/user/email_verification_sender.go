@@ -0,0 +1,48 @@
+package user
+
+import (
+	"net/mail"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/mailer"
+)
+
+// EmailVerificationTemplate is the mail template verification links are sent
+// with.
+const EmailVerificationTemplate = "email_verification"
+
+// EmailVerificationSender delivers the link that verifies the email address of
+// a user.
+type EmailVerificationSender interface {
+	SendEmailVerification(name string, email string, link string) (err error)
+}
+
+// MailEmailVerificationSender mails verification links with the
+// email_verification template.
+type MailEmailVerificationSender struct {
+	TemplateMailer *mailer.TemplateMailer
+	Config         *configs.Config
+}
+
+// ProvideMailEmailVerificationSender is the provider for MailEmailVerificationSender.
+func ProvideMailEmailVerificationSender(templateMailer *mailer.TemplateMailer, config *configs.Config) *MailEmailVerificationSender {
+	return &MailEmailVerificationSender{
+		TemplateMailer: templateMailer,
+		Config:         config,
+	}
+}
+
+func (s *MailEmailVerificationSender) SendEmailVerification(name string, email string, link string) (err error) {
+	data := struct {
+		AppName string
+		Name    string
+		Link    string
+	}{
+		AppName: s.Config.App.Name,
+		Name:    name,
+		Link:    link,
+	}
+
+	// Users have no preferred locale, so the default one is used.
+	return s.TemplateMailer.SendTemplate(mail.Address{Name: name, Address: email}, EmailVerificationTemplate, "", data)
+}
@@ -0,0 +1,52 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/failure"
+)
+
+const (
+	// DefaultPasswordMinLength is the minimum number of characters of a
+	// password when none is configured.
+	DefaultPasswordMinLength = 8
+
+	// passwordMaxBytes is the longest password bcrypt hashes in full.
+	passwordMaxBytes = 72
+)
+
+// PasswordPolicy is the set of rules a new password must satisfy.
+type PasswordPolicy struct {
+	MinLength int
+}
+
+// NewPasswordPolicy creates the policy configured in App.User.Password.
+func NewPasswordPolicy(config *configs.Config) PasswordPolicy {
+	policy := PasswordPolicy{MinLength: config.App.User.Password.MinLength}
+	if policy.MinLength <= 0 {
+		policy.MinLength = DefaultPasswordMinLength
+	}
+
+	return policy
+}
+
+// Validate checks a new password of the given user against the policy. A
+// password may not be the username or email address of the user.
+func (p PasswordPolicy) Validate(password string, username string, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return failure.BadRequestFromString(fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+
+	if len(password) > passwordMaxBytes {
+		return failure.BadRequestFromString(fmt.Sprintf("password must be at most %d bytes long", passwordMaxBytes))
+	}
+
+	if strings.EqualFold(password, username) || strings.EqualFold(password, email) {
+		return failure.BadRequestFromString("password must not be the username or email address")
+	}
+
+	return nil
+}
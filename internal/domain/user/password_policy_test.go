@@ -0,0 +1,47 @@
+package user_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := user.NewPasswordPolicy(&configs.Config{})
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, policy.Validate("correct horse battery", "john", "john@example.com"))
+	})
+
+	t.Run("Too short", func(t *testing.T) {
+		err := policy.Validate("short", "john", "john@example.com")
+		assert.Equal(t, http.StatusBadRequest, failure.GetCode(err))
+	})
+
+	t.Run("Too long", func(t *testing.T) {
+		err := policy.Validate(strings.Repeat("a", 73), "john", "john@example.com")
+		assert.Equal(t, http.StatusBadRequest, failure.GetCode(err))
+	})
+
+	t.Run("Username", func(t *testing.T) {
+		err := policy.Validate("JohnSmith", "johnsmith", "john@example.com")
+		assert.Equal(t, http.StatusBadRequest, failure.GetCode(err))
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	userID, _ := uuid.NewV4()
+	reset, token, err := user.NewPasswordReset(userID, user.DefaultPasswordResetExpiration)
+	assert.NoError(t, err)
+
+	assert.Equal(t, user.HashPasswordResetToken(token), reset.TokenHash)
+	assert.NotContains(t, reset.TokenHash, token)
+	assert.False(t, reset.IsUsed())
+	assert.False(t, reset.IsExpired())
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// DefaultPasswordResetExpiration is used when no reset token expiry is
+	// configured.
+	DefaultPasswordResetExpiration = time.Hour
+	// DefaultPasswordResetResendInterval is the minimum time between two
+	// reset emails to the same user when none is configured.
+	DefaultPasswordResetResendInterval = time.Minute
+
+	passwordResetTokenBytes = 32
+)
+
+// PasswordReset is a pending reset of the password of a user. Only the
+// SHA-256 hash of the token sent to the user is stored. A token can be used
+// only once, and using it invalidates every other pending reset of the user.
+type PasswordReset struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+	UsedAt    null.Time `db:"used_at"`
+}
+
+// NewPasswordReset creates a password reset for a user and returns it together
+// with its plaintext token, which is never stored.
+func NewPasswordReset(userID uuid.UUID, ttl time.Duration) (reset PasswordReset, plain string, err error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	b := make([]byte, passwordResetTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	plain = base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	reset = PasswordReset{
+		ID:        id,
+		UserID:    userID,
+		TokenHash: HashPasswordResetToken(plain),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	return
+}
+
+// HashPasswordResetToken returns the hex-encoded SHA-256 hash of a plaintext
+// reset token.
+func HashPasswordResetToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsUsed checks whether the reset has already been used.
+func (r *PasswordReset) IsUsed() bool {
+	return r.UsedAt.Valid
+}
+
+// IsExpired checks whether the reset is past its expiry time.
+func (r *PasswordReset) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// ForgotPasswordRequestFormat is the request to send a password reset email.
+type ForgotPasswordRequestFormat struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// ResetPasswordRequestFormat is the request to set a new password with a
+// reset token.
+type ResetPasswordRequestFormat struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,225 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	passwordResetQueries = struct {
+		selectPasswordReset     string
+		insertPasswordReset     string
+		usePasswordReset        string
+		invalidatePasswordReset string
+		updateUserPassword      string
+	}{
+		selectPasswordReset: `
+			SELECT
+				id,
+				user_id,
+				token_hash,
+				expires_at,
+				created_at,
+				used_at
+			FROM user_password_reset
+		`,
+
+		insertPasswordReset: `
+			INSERT INTO user_password_reset (
+				id,
+				user_id,
+				token_hash,
+				expires_at,
+				created_at,
+				used_at
+			) VALUES (
+				:id,
+				:user_id,
+				:token_hash,
+				:expires_at,
+				:created_at,
+				:used_at
+			)
+		`,
+
+		usePasswordReset: `
+			UPDATE user_password_reset
+			SET
+				used_at = ?
+			WHERE
+				id = ? AND used_at IS NULL
+		`,
+
+		invalidatePasswordReset: `
+			UPDATE user_password_reset
+			SET
+				used_at = ?
+			WHERE
+				user_id = ? AND used_at IS NULL
+		`,
+
+		updateUserPassword: `
+			UPDATE user
+			SET
+				password = ?,
+				updated_at = ?,
+				updated_by = ?
+			WHERE
+				id = ? AND deleted_at IS NULL
+		`,
+	}
+)
+
+// errPasswordResetAlreadyUsed is returned inside the reset transaction when
+// another request used the same token first.
+var errPasswordResetAlreadyUsed = errors.New("password reset already used")
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(reset PasswordReset) (err error)
+	ResolvePasswordResetByTokenHash(tokenHash string) (reset PasswordReset, err error)
+	ResolveLatestPasswordResetByUserID(userID uuid.UUID) (reset PasswordReset, err error)
+	UsePasswordReset(reset PasswordReset, passwordHash string) (used bool, err error)
+}
+
+type PasswordResetRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvidePasswordResetRepositoryMySQL(db *infras.MySQLConn) *PasswordResetRepositoryMySQL {
+	s := new(PasswordResetRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *PasswordResetRepositoryMySQL) CreatePasswordReset(reset PasswordReset) (err error) {
+	stmt, err := r.DB.Write.PrepareNamed(passwordResetQueries.insertPasswordReset)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(reset)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *PasswordResetRepositoryMySQL) ResolvePasswordResetByTokenHash(tokenHash string) (reset PasswordReset, err error) {
+	err = r.DB.Write.Get(
+		&reset,
+		passwordResetQueries.selectPasswordReset+" WHERE token_hash = ?",
+		tokenHash)
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("password reset")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// ResolveLatestPasswordResetByUserID resolves the reset that was created last
+// for a user, which is used to throttle reset emails.
+func (r *PasswordResetRepositoryMySQL) ResolveLatestPasswordResetByUserID(userID uuid.UUID) (reset PasswordReset, err error) {
+	err = r.DB.Write.Get(
+		&reset,
+		passwordResetQueries.selectPasswordReset+" WHERE user_id = ? ORDER BY created_at DESC LIMIT 1",
+		userID.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("password reset")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// UsePasswordReset marks the reset as used, invalidates the other pending
+// resets of the user and sets the new password hash in a single transaction.
+// It reports used as false, without error, when the reset was already used or
+// the user has been deleted since.
+func (r *PasswordResetRepositoryMySQL) UsePasswordReset(reset PasswordReset, passwordHash string) (used bool, err error) {
+	err = r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		now := time.Now()
+		result, err := tx.Exec(
+			passwordResetQueries.usePasswordReset,
+			now,
+			reset.ID.String())
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		if affected == 0 {
+			e <- errPasswordResetAlreadyUsed
+			return
+		}
+
+		_, err = tx.Exec(
+			passwordResetQueries.invalidatePasswordReset,
+			now,
+			reset.UserID.String())
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		result, err = tx.Exec(
+			passwordResetQueries.updateUserPassword,
+			passwordHash,
+			now,
+			reset.UserID.String(),
+			reset.UserID.String())
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		affected, err = result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		if affected == 0 {
+			e <- errPasswordResetAlreadyUsed
+			return
+		}
+
+		e <- nil
+	})
+
+	if err == errPasswordResetAlreadyUsed {
+		return false, nil
+	}
+
+	return err == nil, err
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"net/mail"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/mailer"
+)
+
+// PasswordResetTemplate is the mail template password reset links are sent
+// with.
+const PasswordResetTemplate = "password_reset"
+
+// PasswordResetSender delivers the link that resets the password of a user.
+type PasswordResetSender interface {
+	SendPasswordReset(name string, email string, link string) (err error)
+}
+
+// MailPasswordResetSender mails password reset links with the password_reset
+// template.
+type MailPasswordResetSender struct {
+	TemplateMailer *mailer.TemplateMailer
+	Config         *configs.Config
+}
+
+// ProvideMailPasswordResetSender is the provider for MailPasswordResetSender.
+func ProvideMailPasswordResetSender(templateMailer *mailer.TemplateMailer, config *configs.Config) *MailPasswordResetSender {
+	return &MailPasswordResetSender{
+		TemplateMailer: templateMailer,
+		Config:         config,
+	}
+}
+
+func (s *MailPasswordResetSender) SendPasswordReset(name string, email string, link string) (err error) {
+	data := struct {
+		AppName string
+		Name    string
+		Link    string
+	}{
+		AppName: s.Config.App.Name,
+		Name:    name,
+		Link:    link,
+	}
+
+	// Users have no preferred locale, so the default one is used.
+	return s.TemplateMailer.SendTemplate(mail.Address{Name: name, Address: email}, PasswordResetTemplate, "", data)
+}
@@ -0,0 +1,112 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/nuuid"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const refreshTokenBytes = 32
+
+// RefreshToken is a long-lived, server-side credential used to obtain new
+// access tokens. Only the SHA-256 hash of the token is stored.
+//
+// Every refresh token belongs to a family that starts at login or
+// registration. Refreshing revokes the presented token and issues a new one
+// in the same family; presenting an already revoked token is treated as a
+// replay and revokes the whole family.
+type RefreshToken struct {
+	ID         uuid.UUID   `db:"id"`
+	UserID     uuid.UUID   `db:"user_id"`
+	FamilyID   uuid.UUID   `db:"family_id"`
+	TokenHash  string      `db:"token_hash"`
+	ExpiresAt  time.Time   `db:"expires_at"`
+	CreatedAt  time.Time   `db:"created_at"`
+	RevokedAt  null.Time   `db:"revoked_at"`
+	ReplacedBy nuuid.NUUID `db:"replaced_by"`
+}
+
+// NewRefreshToken creates a refresh token for a user in the given family and
+// returns it together with its plaintext value, which is never stored.
+func NewRefreshToken(userID uuid.UUID, familyID uuid.UUID, ttl time.Duration) (token RefreshToken, plain string, err error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	b := make([]byte, refreshTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	plain = base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	token = RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashRefreshToken(plain),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	return
+}
+
+// HashRefreshToken returns the hex-encoded SHA-256 hash of a plaintext refresh token.
+func HashRefreshToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsRevoked checks whether the refresh token has been rotated or revoked.
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt.Valid
+}
+
+// IsExpired checks whether the refresh token is past its expiry time.
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// TokenPair is the set of credentials issued on login, registration and refresh.
+type TokenPair struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+	IDToken               string
+}
+
+func (tp TokenPair) ToResponseFormat() TokenResponseFormat {
+	return TokenResponseFormat{
+		AccessToken:           tp.AccessToken,
+		AccessTokenExpiresAt:  tp.AccessTokenExpiresAt,
+		RefreshToken:          tp.RefreshToken,
+		RefreshTokenExpiresAt: tp.RefreshTokenExpiresAt,
+		IDToken:               tp.IDToken,
+	}
+}
+
+type TokenResponseFormat struct {
+	AccessToken           string    `json:"accessToken"`
+	AccessTokenExpiresAt  time.Time `json:"accessTokenExpiresAt"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+	IDToken               string    `json:"idToken,omitempty"`
+}
+
+type RefreshTokenRequestFormat struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequestFormat struct {
+	// RefreshToken is optional; when given, its whole family is revoked as well.
+	RefreshToken string `json:"refreshToken"`
+}
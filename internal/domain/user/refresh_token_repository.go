@@ -0,0 +1,220 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	refreshTokenQueries = struct {
+		selectRefreshToken string
+		insertRefreshToken string
+		rotateRefreshToken string
+		revokeFamily       string
+		revokeByUserID     string
+	}{
+		selectRefreshToken: `
+			SELECT
+				id,
+				user_id,
+				family_id,
+				token_hash,
+				expires_at,
+				created_at,
+				revoked_at,
+				replaced_by
+			FROM user_refresh_token
+		`,
+
+		insertRefreshToken: `
+			INSERT INTO user_refresh_token (
+				id,
+				user_id,
+				family_id,
+				token_hash,
+				expires_at,
+				created_at,
+				revoked_at,
+				replaced_by
+			) VALUES (
+				:id,
+				:user_id,
+				:family_id,
+				:token_hash,
+				:expires_at,
+				:created_at,
+				:revoked_at,
+				:replaced_by
+			)
+		`,
+
+		rotateRefreshToken: `
+			UPDATE user_refresh_token
+			SET
+				revoked_at = ?,
+				replaced_by = ?
+			WHERE
+				id = ? AND revoked_at IS NULL
+		`,
+
+		revokeFamily: `
+			UPDATE user_refresh_token
+			SET
+				revoked_at = ?
+			WHERE
+				family_id = ? AND revoked_at IS NULL
+		`,
+
+		revokeByUserID: `
+			UPDATE user_refresh_token
+			SET
+				revoked_at = ?
+			WHERE
+				user_id = ? AND revoked_at IS NULL
+		`,
+	}
+)
+
+// errRefreshTokenAlreadyRotated is returned inside the rotation transaction
+// when another request rotated the same token first.
+var errRefreshTokenAlreadyRotated = errors.New("refresh token already rotated")
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token RefreshToken) (err error)
+	ResolveRefreshTokenByHash(tokenHash string) (token RefreshToken, err error)
+	RotateRefreshToken(current RefreshToken, next RefreshToken) (rotated bool, err error)
+	RevokeRefreshTokenFamily(familyID uuid.UUID) (err error)
+	RevokeRefreshTokensByUserID(userID uuid.UUID) (err error)
+}
+
+type RefreshTokenRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvideRefreshTokenRepositoryMySQL(db *infras.MySQLConn) *RefreshTokenRepositoryMySQL {
+	s := new(RefreshTokenRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *RefreshTokenRepositoryMySQL) CreateRefreshToken(token RefreshToken) (err error) {
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if err := r.txCreate(tx, token); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+func (r *RefreshTokenRepositoryMySQL) ResolveRefreshTokenByHash(tokenHash string) (token RefreshToken, err error) {
+	err = r.DB.Write.Get(
+		&token,
+		refreshTokenQueries.selectRefreshToken+" WHERE token_hash = ?",
+		tokenHash)
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("refresh token")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// RotateRefreshToken revokes the current token and stores the next one in a
+// single transaction. It reports rotated as false, without error, when the
+// current token was already revoked by the time the update ran.
+func (r *RefreshTokenRepositoryMySQL) RotateRefreshToken(current RefreshToken, next RefreshToken) (rotated bool, err error) {
+	err = r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		result, err := tx.Exec(
+			refreshTokenQueries.rotateRefreshToken,
+			next.CreatedAt,
+			next.ID.String(),
+			current.ID.String())
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		if affected == 0 {
+			e <- errRefreshTokenAlreadyRotated
+			return
+		}
+
+		if err := r.txCreate(tx, next); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+
+	if err == errRefreshTokenAlreadyRotated {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *RefreshTokenRepositoryMySQL) RevokeRefreshTokenFamily(familyID uuid.UUID) (err error) {
+	_, err = r.DB.Write.Exec(
+		refreshTokenQueries.revokeFamily,
+		time.Now(),
+		familyID.String())
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+func (r *RefreshTokenRepositoryMySQL) RevokeRefreshTokensByUserID(userID uuid.UUID) (err error) {
+	_, err = r.DB.Write.Exec(
+		refreshTokenQueries.revokeByUserID,
+		time.Now(),
+		userID.String())
+
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// Transactions
+func (r *RefreshTokenRepositoryMySQL) txCreate(tx *sqlx.Tx, token RefreshToken) (err error) {
+	stmt, err := tx.PrepareNamed(refreshTokenQueries.insertRefreshToken)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(token)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
@@ -14,26 +14,34 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
 // User: For Update and Get
 
 type User struct {
-	ID        uuid.UUID   `db:"id" validate:"required"`
-	Name      string      `db:"name" validate:"required"`
-	Username  string      `db:"username" validate:"required"`
-	Password  string      `db:"password" validate:"required"`
-	Email     string      `db:"email" validate:"required"`
-	CreatedAt time.Time   `db:"created_at" validate:"required"`
-	CreatedBy uuid.UUID   `db:"created_by" validate:"required"`
-	UpdatedAt null.Time   `db:"updated_at"`
-	UpdatedBy nuuid.NUUID `db:"updated_by"`
-	DeletedAt null.Time   `db:"deleted_at"`
-	DeletedBy nuuid.NUUID `db:"deleted_by"`
+	ID              uuid.UUID   `db:"id" validate:"required"`
+	Name            string      `db:"name" validate:"required"`
+	Username        string      `db:"username" validate:"required"`
+	Password        string      `db:"password" validate:"required"`
+	Email           string      `db:"email" validate:"required"`
+	EmailVerifiedAt null.Time   `db:"email_verified_at"`
+	CreatedAt       time.Time   `db:"created_at" validate:"required"`
+	CreatedBy       uuid.UUID   `db:"created_by" validate:"required"`
+	UpdatedAt       null.Time   `db:"updated_at"`
+	UpdatedBy       nuuid.NUUID `db:"updated_by"`
+	DeletedAt       null.Time   `db:"deleted_at"`
+	DeletedBy       nuuid.NUUID `db:"deleted_by"`
 }
 
 func (u *User) IsDeleted() (deleted bool) {
 	return u.DeletedAt.Valid && u.DeletedBy.Valid
 }
 
+// IsEmailVerified checks whether the user has verified their email address.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt.Valid
+}
+
 func (u User) MarshalJSON() ([]byte, error) {
 	return json.Marshal(u.ToResponseFormat())
 }
@@ -45,53 +53,105 @@ func (u *User) Validate() (err error) {
 
 func (u User) ToResponseFormat() UserResponseFormat {
 	resp := UserResponseFormat{
-		ID:        u.ID,
-		Username:  u.Username,
-		Name:      u.Name,
-		CreatedBy: u.CreatedBy,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		UpdatedBy: u.UpdatedBy.Ptr(),
-		DeletedAt: u.DeletedAt,
-		DeletedBy: u.DeletedBy.Ptr(),
+		ID:              u.ID,
+		Username:        u.Username,
+		Name:            u.Name,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		CreatedBy:       u.CreatedBy,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		UpdatedBy:       u.UpdatedBy.Ptr(),
+		DeletedAt:       u.DeletedAt,
+		DeletedBy:       u.DeletedBy.Ptr(),
 	}
 
 	return resp
 }
 
+func (u User) ToUserInfoFormat() UserInfoResponseFormat {
+	return UserInfoResponseFormat{
+		Subject:           u.ID.String(),
+		Name:              u.Name,
+		PreferredUsername: u.Username,
+		Email:             u.Email,
+		EmailVerified:     u.IsEmailVerified(),
+	}
+}
+
+// UserRequestFormat is a partial update of the profile of a user. Omitted
+// fields are left unchanged.
 type UserRequestFormat struct {
-	// validate still not sure, it's not mandatory in GET
-	Name string `json:"name" validate:"required"`
+	Name     *string `json:"name" validate:"omitempty,min=1,max=255"`
+	Username *string `json:"username" validate:"omitempty,min=1,max=255"`
+	Email    *string `json:"email" validate:"omitempty,max=255"`
+	// CurrentPassword is required to change the email address, which the
+	// password can be reset through.
+	CurrentPassword string `json:"currentPassword"`
+}
+
+// UpdateProfileResponseFormat is the updated profile. When the username or
+// email address changed, the tokens of the user were revoked, and Token holds
+// a new token pair carrying the new claims.
+type UpdateProfileResponseFormat struct {
+	UserResponseFormat
+	Token *TokenResponseFormat `json:"token,omitempty"`
 }
 
 type UserResponseFormat struct {
-	ID        uuid.UUID  `json:"id"`
-	Username  string     `json:"username"`
-	Name      string     `json:"name"`
-	Email     string     `json:"email"`
-	CreatedAt time.Time  `json:"createdAt"`
-	CreatedBy uuid.UUID  `json:"createdBy"`
-	UpdatedAt null.Time  `json:"updatedAt"`
-	UpdatedBy *uuid.UUID `json:"updatedBy"`
-	DeletedAt null.Time  `json:"deletedAt,omitempty"`
-	DeletedBy *uuid.UUID `json:"deletedBy,omitempty"`
+	ID              uuid.UUID  `json:"id"`
+	Username        string     `json:"username"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt null.Time  `json:"emailVerifiedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	CreatedBy       uuid.UUID  `json:"createdBy"`
+	UpdatedAt       null.Time  `json:"updatedAt"`
+	UpdatedBy       *uuid.UUID `json:"updatedBy"`
+	DeletedAt       null.Time  `json:"deletedAt,omitempty"`
+	DeletedBy       *uuid.UUID `json:"deletedBy,omitempty"`
+}
+
+// ChangePasswordRequestFormat is the request to change the password of the
+// caller.
+type ChangePasswordRequestFormat struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+	// SignOutOtherSessions revokes every other session of the caller. The
+	// caller then continues with the token pair in the response.
+	SignOutOtherSessions bool `json:"signOutOtherSessions"`
+}
+
+// DeleteAccountRequestFormat is the request to delete the account of the
+// caller.
+type DeleteAccountRequestFormat struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// UserInfoResponseFormat is the OpenID Connect UserInfo response.
+type UserInfoResponseFormat struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
 }
 
 // Register
 
 type UserRegister struct {
-	ID          uuid.UUID   `db:"id" validate:"required"`
-	Name        string      `db:"name" validate:"required"`
-	Username    string      `db:"username" validate:"required"`
-	Password    string      `db:"password" validate:"required"`
-	Email       string      `db:"email" validate:"required"`
-	AccessToken string      `db:"-"`
-	CreatedAt   time.Time   `db:"created_at"`
-	CreatedBy   uuid.UUID   `db:"created_by"`
-	UpdatedAt   null.Time   `db:"updated_at"`
-	UpdatedBy   nuuid.NUUID `db:"updated_by"`
-	DeletedAt   null.Time   `db:"deleted_at"`
-	DeletedBy   nuuid.NUUID `db:"deleted_by"`
+	ID        uuid.UUID   `db:"id" validate:"required"`
+	Name      string      `db:"name" validate:"required"`
+	Username  string      `db:"username" validate:"required"`
+	Password  string      `db:"password" validate:"required"`
+	Email     string      `db:"email" validate:"required"`
+	Token     TokenPair   `db:"-"`
+	CreatedAt time.Time   `db:"created_at"`
+	CreatedBy uuid.UUID   `db:"created_by"`
+	UpdatedAt null.Time   `db:"updated_at"`
+	UpdatedBy nuuid.NUUID `db:"updated_by"`
+	DeletedAt null.Time   `db:"deleted_at"`
+	DeletedBy nuuid.NUUID `db:"deleted_by"`
 }
 
 func (ur *UserRegister) IsDeleted() (deleted bool) {
@@ -109,7 +169,6 @@ func (ur UserRegister) NewUserFromRequestFormat(req RegisterRequestFormat) (newU
 		return
 	}
 
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(req.Email) {
 		err = errors.New("invalid email format")
 		return
@@ -144,11 +203,11 @@ func (ur *UserRegister) Validate() (err error) {
 
 func (ur UserRegister) ToResponseFormat() RegisterResponseFormat {
 	resp := RegisterResponseFormat{
-		ID:          ur.ID,
-		Name:        ur.Name,
-		Username:    ur.Username,
-		Email:       ur.Email,
-		AccessToken: ur.AccessToken,
+		ID:                  ur.ID,
+		Name:                ur.Name,
+		Username:            ur.Username,
+		Email:               ur.Email,
+		TokenResponseFormat: ur.Token.ToResponseFormat(),
 	}
 
 	return resp
@@ -162,27 +221,28 @@ type RegisterRequestFormat struct {
 }
 
 type RegisterResponseFormat struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	AccessToken string    `json:"accessToken"`
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	TokenResponseFormat
 }
 
 // Login
 type UserLogin struct {
-	ID          uuid.UUID   `db:"id"`
-	Name        string      `db:"name"`
-	Username    string      `db:"username"`
-	Email       string      `db:"email"`
-	Password    string      `db:"password" validate:"required"`
-	CreatedAt   time.Time   `db:"created_at"`
-	CreatedBy   uuid.UUID   `db:"created_by"`
-	UpdatedAt   null.Time   `db:"updated_at"`
-	UpdatedBy   nuuid.NUUID `db:"updated_by"`
-	DeletedAt   null.Time   `db:"deleted_at"`
-	DeletedBy   nuuid.NUUID `db:"deleted_by"`
-	AccessToken string      `db:"-"`
+	ID              uuid.UUID   `db:"id"`
+	Name            string      `db:"name"`
+	Username        string      `db:"username"`
+	Email           string      `db:"email"`
+	EmailVerifiedAt null.Time   `db:"email_verified_at"`
+	Password        string      `db:"password" validate:"required"`
+	CreatedAt       time.Time   `db:"created_at"`
+	CreatedBy       uuid.UUID   `db:"created_by"`
+	UpdatedAt       null.Time   `db:"updated_at"`
+	UpdatedBy       nuuid.NUUID `db:"updated_by"`
+	DeletedAt       null.Time   `db:"deleted_at"`
+	DeletedBy       nuuid.NUUID `db:"deleted_by"`
+	Token           TokenPair   `db:"-"`
 }
 
 func (ul UserLogin) MarshalJSON() ([]byte, error) {
@@ -217,7 +277,7 @@ func (ul *UserLogin) Validate() (err error) {
 
 func (ul *UserLogin) ToResponseFormat() LoginResponseFormat {
 	resp := LoginResponseFormat{
-		AccessToken: ul.AccessToken,
+		TokenResponseFormat: ul.Token.ToResponseFormat(),
 	}
 
 	return resp
@@ -227,8 +287,13 @@ type LoginRequestFormat struct {
 	Email    string `json:"email"`
 	Username string `json:"username"`
 	Password string `json:"password" validate:"required"`
+	// ClientID is the OpenID Connect client requesting an ID token. When
+	// set, an ID token with this audience is issued alongside the tokens. It
+	// must be a registered client allowed the openid scope.
+	ClientID string `json:"clientId"`
+	Nonce    string `json:"nonce"`
 }
 
 type LoginResponseFormat struct {
-	AccessToken string `json:"accessToken"`
+	TokenResponseFormat
 }
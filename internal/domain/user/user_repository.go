@@ -2,19 +2,37 @@ package user
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+// mysqlErrDuplicateEntry is the MySQL error number of a unique index
+// violation.
+const mysqlErrDuplicateEntry = 1062
+
 var (
 	userQueries = struct {
-		selectUser string
-		insertUser string
-		// updateUser string
+		selectUser         string
+		insertUser         string
+		updateUserProfile  string
+		updateUserPassword string
+		deleteUser         string
+
+		deleteUserDataExports string
+
+		anonymizeDeletedUsers          string
+		deleteAnonymizedVerifications  string
+		deleteAnonymizedPasswordResets string
+		deleteAnonymizedRoles          string
+		deleteAnonymizedDataExports    string
 	}{
 		selectUser: `
 			SELECT
@@ -23,6 +41,7 @@ var (
 				username,
 				password,
 				email,
+				email_verified_at,
 				created_at,
 				created_by,
 				updated_at,
@@ -60,28 +79,94 @@ var (
 			)
 		`,
 
-		// updateUser: `
-		// 	UPDATE users
-		// 	SET
-		// 		username = :username,
-		// 		name = :name,
-		// 		role = :role,
-		// 		created_at = :created_at,
-		// 		created_by = :created_by,
-		// 		updated_at = :updated_at,
-		// 		updated_by = :updated_by,
-		// 		deleted_at = :deleted_at,
-		// 		deleted_by = :deleted_by
-		// 	WHERE
-		// 		id = :id
-		// `,
+		updateUserProfile: `
+			UPDATE user
+			SET
+				name = :name,
+				username = :username,
+				email = :email,
+				email_verified_at = :email_verified_at,
+				updated_at = :updated_at,
+				updated_by = :updated_by
+			WHERE
+				id = :id AND deleted_at IS NULL
+		`,
+
+		updateUserPassword: `
+			UPDATE user
+			SET
+				password = :password,
+				updated_at = :updated_at,
+				updated_by = :updated_by
+			WHERE
+				id = :id AND deleted_at IS NULL
+		`,
+
+		deleteUser: `
+			UPDATE user
+			SET
+				deleted_at = :deleted_at,
+				deleted_by = :deleted_by
+			WHERE
+				id = :id AND deleted_at IS NULL
+		`,
+
+		deleteUserDataExports: `
+			DELETE FROM user_data_export
+			WHERE
+				user_id = ?
+		`,
+
+		anonymizeDeletedUsers: `
+			UPDATE user
+			SET
+				name = 'Deleted user',
+				username = CONCAT('deleted-', id),
+				password = '',
+				email = CONCAT(id, '@deleted.invalid'),
+				email_verified_at = NULL,
+				anonymized_at = ?
+			WHERE
+				deleted_at < ? AND anonymized_at IS NULL
+		`,
+
+		deleteAnonymizedVerifications: `
+			DELETE FROM user_email_verification
+			WHERE
+				user_id IN (SELECT id FROM user WHERE anonymized_at IS NOT NULL)
+		`,
+
+		deleteAnonymizedPasswordResets: `
+			DELETE FROM user_password_reset
+			WHERE
+				user_id IN (SELECT id FROM user WHERE anonymized_at IS NOT NULL)
+		`,
+
+		deleteAnonymizedRoles: `
+			DELETE FROM user_role
+			WHERE
+				user_id IN (SELECT id FROM user WHERE anonymized_at IS NOT NULL)
+		`,
+
+		deleteAnonymizedDataExports: `
+			DELETE FROM user_data_export
+			WHERE
+				user_id IN (SELECT id FROM user WHERE anonymized_at IS NOT NULL)
+		`,
 	}
 )
 
 type UserRepository interface {
 	CreateUser(ur UserRegister) (err error)
+	ResolveByID(id uuid.UUID) (user User, err error)
 	ResolveLoginByEmail(email string) (user UserLogin, err error)
 	ResolveLoginByUsername(username string) (user UserLogin, err error)
+	UpdateProfile(user User) (err error)
+	UpdatePassword(user User) (err error)
+	DeleteUser(user User) (err error)
+	ExistByEmail(email string) (exists bool, err error)
+	ExistByUsername(username string) (exists bool, err error)
+	AnonymizeDeletedUsers(deletedBefore time.Time) (anonymized int64, err error)
 }
 
 type UserRepositoryMySQL struct {
@@ -142,6 +227,21 @@ func (r *UserRepositoryMySQL) CreateUser(userRegister UserRegister) (err error)
 	})
 }
 
+func (r *UserRepositoryMySQL) ResolveByID(id uuid.UUID) (user User, err error) {
+	err = r.DB.Read.Get(
+		&user,
+		userQueries.selectUser+" WHERE id = ?",
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("user")
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return
+}
+
 func (r *UserRepositoryMySQL) ResolveLoginByEmail(email string) (user UserLogin, err error) {
 	err = r.DB.Read.Get(
 		&user,
@@ -172,6 +272,94 @@ func (r *UserRepositoryMySQL) ResolveLoginByUsername(username string) (user User
 	return
 }
 
+// UpdateProfile stores the profile of a user that has not been deleted,
+// leaving the password and deletion columns as they are. A username or email
+// address taken by another user in the meantime is reported as a conflict.
+func (r *UserRepositoryMySQL) UpdateProfile(user User) (err error) {
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if err := r.txUpdate(tx, userQueries.updateUserProfile, user); err != nil {
+			e <- conflictFromDuplicateKey(err)
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// UpdatePassword stores the password of a user that has not been deleted,
+// leaving the other columns as they are.
+func (r *UserRepositoryMySQL) UpdatePassword(user User) (err error) {
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if err := r.txUpdate(tx, userQueries.updateUserPassword, user); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// DeleteUser soft-deletes a user, and removes the data exports of the user,
+// which hold a copy of their personal data. Only the deletion columns are
+// written, so the user cannot be deleted twice.
+func (r *UserRepositoryMySQL) DeleteUser(user User) (err error) {
+	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		if err := r.txUpdate(tx, userQueries.deleteUser, user); err != nil {
+			e <- err
+			return
+		}
+
+		if _, err := tx.Exec(userQueries.deleteUserDataExports, user.ID.String()); err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// AnonymizeDeletedUsers replaces the personal data of the users deleted
+// before the given time with placeholders, and removes the email verifications,
+// password resets, roles and data exports of anonymized users. The user IDs are kept, so that
+// records referring to the users stay intact.
+func (r *UserRepositoryMySQL) AnonymizeDeletedUsers(deletedBefore time.Time) (anonymized int64, err error) {
+	err = r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		result, err := tx.Exec(
+			userQueries.anonymizeDeletedUsers,
+			time.Now(),
+			deletedBefore)
+		if err != nil {
+			logger.ErrorWithStack(err)
+			e <- err
+			return
+		}
+
+		anonymized, err = result.RowsAffected()
+		if err != nil {
+			e <- err
+			return
+		}
+
+		for _, query := range []string{
+			userQueries.deleteAnonymizedVerifications,
+			userQueries.deleteAnonymizedPasswordResets,
+			userQueries.deleteAnonymizedRoles,
+			userQueries.deleteAnonymizedDataExports,
+		} {
+			if _, err := tx.Exec(query); err != nil {
+				logger.ErrorWithStack(err)
+				e <- err
+				return
+			}
+		}
+
+		e <- nil
+	})
+
+	return
+}
+
 // Exists
 func (r *UserRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
 	err = r.DB.Read.Get(
@@ -212,6 +400,24 @@ func (r *UserRepositoryMySQL) ExistByUsername(username string) (exists bool, err
 	return
 }
 
+// conflictFromDuplicateKey maps a violation of the unique username or email
+// index to a conflict, and returns any other error as is.
+func conflictFromDuplicateKey(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDuplicateEntry {
+		return err
+	}
+
+	switch {
+	case strings.HasSuffix(mysqlErr.Message, "username'"):
+		return failure.Conflict("update", "username", "already exists")
+	case strings.HasSuffix(mysqlErr.Message, "email'"):
+		return failure.Conflict("update", "email", "already exists")
+	}
+
+	return err
+}
+
 // Transactions
 func (r *UserRepositoryMySQL) txCreate(tx *sqlx.Tx, userRegister UserRegister) (err error) {
 	stmt, err := tx.PrepareNamed(userQueries.insertUser)
@@ -228,3 +434,34 @@ func (r *UserRepositoryMySQL) txCreate(tx *sqlx.Tx, userRegister UserRegister) (
 
 	return
 }
+
+// txUpdate runs one of the update queries of a user. The queries only match
+// users that have not been deleted, so a user that was deleted concurrently
+// is reported as not found rather than written back.
+func (r *UserRepositoryMySQL) txUpdate(tx *sqlx.Tx, query string, user User) (err error) {
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(user)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if affected == 0 {
+		err = failure.NotFound("user")
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
@@ -1,26 +1,70 @@
 package user
 
 import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/internal/domain/rbac"
 	"github.com/evermos/boilerplate-go/shared"
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/nuuid"
+	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+	"github.com/guregu/null"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultRefreshTokenExpiration is used when no refresh token expiry is configured.
+const DefaultRefreshTokenExpiration = 30 * 24 * time.Hour
+
 type UserService interface {
 	RegisterUser(registerRequestFormat RegisterRequestFormat) (ur UserRegister, err error)
 	Login(loginRequestFormat LoginRequestFormat) (userLogin UserLogin, err error)
+	ResolveByID(id uuid.UUID) (user User, err error)
+	RefreshToken(refreshTokenRequestFormat RefreshTokenRequestFormat) (tokens TokenPair, err error)
+	Logout(claims *shared.Claims, logoutRequestFormat LogoutRequestFormat) (err error)
+	LogoutAll(claims *shared.Claims) (err error)
+	VerifyEmail(verifyEmailRequestFormat VerifyEmailRequestFormat) (err error)
+	ResendEmailVerification(userID uuid.UUID) (err error)
+	ForgotPassword(forgotPasswordRequestFormat ForgotPasswordRequestFormat) (err error)
+	ResetPassword(resetPasswordRequestFormat ResetPasswordRequestFormat) (err error)
+	ChangePassword(claims *shared.Claims, changePasswordRequestFormat ChangePasswordRequestFormat) (tokens TokenPair, err error)
+	UpdateProfile(claims *shared.Claims, userRequestFormat UserRequestFormat) (user User, tokens *TokenPair, err error)
+	DeleteAccount(claims *shared.Claims, deleteAccountRequestFormat DeleteAccountRequestFormat) (err error)
 }
 
 type UserServiceImpl struct {
-	UserRepository UserRepository
-	Config         *configs.Config
+	UserRepository              UserRepository
+	RefreshTokenRepository      RefreshTokenRepository
+	EmailVerificationRepository EmailVerificationRepository
+	EmailVerificationSender     EmailVerificationSender
+	PasswordResetRepository     PasswordResetRepository
+	PasswordResetSender         PasswordResetSender
+	OAuthTokenStore             oauth.TokenStore
+	RBACService                 rbac.RBACService
+	JWTService                  *shared.JWTService
+	TokenDenylist               shared.TokenDenylist
+	Config                      *configs.Config
 }
 
-func ProvideUserServiceImpl(userRepository UserRepository, config *configs.Config) *UserServiceImpl {
+func ProvideUserServiceImpl(userRepository UserRepository, refreshTokenRepository RefreshTokenRepository, emailVerificationRepository EmailVerificationRepository, emailVerificationSender EmailVerificationSender, passwordResetRepository PasswordResetRepository, passwordResetSender PasswordResetSender, oauthTokenStore oauth.TokenStore, rbacService rbac.RBACService, jwtService *shared.JWTService, tokenDenylist shared.TokenDenylist, config *configs.Config) *UserServiceImpl {
 	s := new(UserServiceImpl)
 	s.UserRepository = userRepository
+	s.RefreshTokenRepository = refreshTokenRepository
+	s.EmailVerificationRepository = emailVerificationRepository
+	s.EmailVerificationSender = emailVerificationSender
+	s.PasswordResetRepository = passwordResetRepository
+	s.PasswordResetSender = passwordResetSender
+	s.OAuthTokenStore = oauthTokenStore
+	s.RBACService = rbacService
+	s.JWTService = jwtService
+	s.TokenDenylist = tokenDenylist
 	s.Config = config
 
 	return s
@@ -41,12 +85,13 @@ func (s *UserServiceImpl) RegisterUser(registerRequestFormat RegisterRequestForm
 		return
 	}
 
-	accessToken, err := s.createToken(userRegister.ID, userRegister.Username, userRegister.Email)
-	if err != nil {
-		return
+	// The account exists at this point, so a failed delivery is only logged.
+	// The user can ask for the verification email to be sent again.
+	if err := s.sendEmailVerification(userRegister.ID, userRegister.Name, userRegister.Email); err != nil {
+		logger.ErrorWithStack(err)
 	}
 
-	userRegister.AccessToken = accessToken
+	userRegister.Token, err = s.createTokenPair(userRegister.ID, userRegister.Username, userRegister.Email, false)
 
 	return
 }
@@ -70,31 +115,672 @@ func (s *UserServiceImpl) Login(loginRequestFormat LoginRequestFormat) (userLogi
 	}
 
 	isValidPassword := checkPasswordHash(loginRequest.Password, userLogin.Password)
-	if !isValidPassword {
-		return userLogin, failure.BadRequest(err)
+	if !isValidPassword || userLogin.DeletedAt.Valid {
+		return userLogin, failure.Unauthorized("invalid credentials")
+	}
+
+	// The ID token is only issued to registered clients, which are trusted
+	// with its audience.
+	if loginRequestFormat.ClientID != "" {
+		if err = s.verifyOpenIDClient(loginRequestFormat.ClientID); err != nil {
+			return
+		}
+	}
+
+	userLogin.Token, err = s.createTokenPair(userLogin.ID, userLogin.Username, userLogin.Email, userLogin.EmailVerifiedAt.Valid)
+	if err != nil {
+		return
+	}
+
+	if loginRequestFormat.ClientID != "" {
+		userLogin.Token.IDToken, err = s.JWTService.GenerateIDToken(shared.IDTokenClaims{
+			Nonce:             loginRequestFormat.Nonce,
+			AuthTime:          time.Now().Unix(),
+			Name:              userLogin.Name,
+			PreferredUsername: userLogin.Username,
+			Email:             userLogin.Email,
+			StandardClaims: jwt.StandardClaims{
+				Subject:  userLogin.ID.String(),
+				Audience: loginRequestFormat.ClientID,
+			},
+		})
+	}
+
+	return
+}
+
+// AuthenticateUser verifies the password of the user with the given username
+// or email, and returns the ID of the user. It authenticates the resource
+// owner of the OAuth password grant.
+func (s *UserServiceImpl) AuthenticateUser(usernameOrEmail string, password string) (userID string, err error) {
+	userLogin, err := s.UserRepository.ResolveLoginByUsername(usernameOrEmail)
+	if err != nil {
+		userLogin, err = s.UserRepository.ResolveLoginByEmail(usernameOrEmail)
+		if err != nil {
+			return
+		}
+	}
+
+	if userLogin.DeletedAt.Valid {
+		return "", failure.NotFound("user")
+	}
+
+	if !checkPasswordHash(password, userLogin.Password) {
+		return "", failure.Unauthorized("invalid credentials")
+	}
+
+	return userLogin.ID.String(), nil
+}
+
+// ResolveByID resolves a user that has not been deleted by its ID.
+func (s *UserServiceImpl) ResolveByID(id uuid.UUID) (user User, err error) {
+	user, err = s.UserRepository.ResolveByID(id)
+	if err != nil {
+		return
+	}
+
+	if user.IsDeleted() {
+		return user, failure.NotFound("user")
+	}
+
+	return
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair. The
+// presented refresh token is rotated; replaying an already rotated token
+// revokes every token in its family.
+func (s *UserServiceImpl) RefreshToken(refreshTokenRequestFormat RefreshTokenRequestFormat) (tokens TokenPair, err error) {
+	current, err := s.RefreshTokenRepository.ResolveRefreshTokenByHash(HashRefreshToken(refreshTokenRequestFormat.RefreshToken))
+	if err != nil {
+		if failure.GetCode(err) == http.StatusNotFound {
+			err = failure.Unauthorized("invalid refresh token")
+		}
+		return
+	}
+
+	if current.IsRevoked() {
+		s.revokeReusedFamily(current)
+		return tokens, failure.Unauthorized("invalid refresh token")
+	}
+
+	if current.IsExpired() {
+		return tokens, failure.Unauthorized("refresh token expired")
+	}
+
+	user, err := s.UserRepository.ResolveByID(current.UserID)
+	if err != nil {
+		return
+	}
+
+	if user.IsDeleted() {
+		return tokens, failure.Unauthorized("invalid refresh token")
+	}
+
+	next, plain, err := NewRefreshToken(current.UserID, current.FamilyID, s.refreshTokenExpiration())
+	if err != nil {
+		return tokens, failure.InternalError(err)
+	}
+
+	rotated, err := s.RefreshTokenRepository.RotateRefreshToken(current, next)
+	if err != nil {
+		return
+	}
+
+	if !rotated {
+		// Lost the race against a concurrent refresh with the same token.
+		s.revokeReusedFamily(current)
+		return tokens, failure.Unauthorized("invalid refresh token")
+	}
+
+	tokens, err = s.createAccessToken(user.ID, user.Username, user.Email, user.IsEmailVerified())
+	if err != nil {
+		return
+	}
+
+	tokens.RefreshToken = plain
+	tokens.RefreshTokenExpiresAt = next.ExpiresAt
+
+	return
+}
+
+// Logout revokes the access token the request was made with and, if given,
+// the refresh token family issued alongside it.
+func (s *UserServiceImpl) Logout(claims *shared.Claims, logoutRequestFormat LogoutRequestFormat) (err error) {
+	err = s.TokenDenylist.Revoke(claims)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	if logoutRequestFormat.RefreshToken == "" {
+		return
+	}
+
+	refreshToken, err := s.RefreshTokenRepository.ResolveRefreshTokenByHash(HashRefreshToken(logoutRequestFormat.RefreshToken))
+	if err != nil {
+		if failure.GetCode(err) == http.StatusNotFound {
+			err = nil
+		}
+		return
+	}
+
+	if refreshToken.UserID != claims.UserID {
+		return
+	}
+
+	return s.RefreshTokenRepository.RevokeRefreshTokenFamily(refreshToken.FamilyID)
+}
+
+// LogoutAll signs the user out of every session by revoking all access
+// tokens issued so far and all refresh tokens.
+func (s *UserServiceImpl) LogoutAll(claims *shared.Claims) (err error) {
+	return s.revokeAllTokens(claims.UserID)
+}
+
+// VerifyEmail marks the email address a verification token was sent to as
+// verified. A token can be used only once, and only while the user still has
+// that address.
+func (s *UserServiceImpl) VerifyEmail(verifyEmailRequestFormat VerifyEmailRequestFormat) (err error) {
+	id, signature, ok := ParseEmailVerificationToken(verifyEmailRequestFormat.Token)
+	if !ok {
+		return failure.BadRequestFromString("invalid verification token")
+	}
+
+	verification, err := s.EmailVerificationRepository.ResolveEmailVerificationByID(id)
+	if err != nil {
+		if failure.GetCode(err) == http.StatusNotFound {
+			err = failure.BadRequestFromString("invalid verification token")
+		}
+		return
+	}
+
+	if !verification.VerifySignature(signature, s.Config.App.Secret) || verification.IsUsed() {
+		return failure.BadRequestFromString("invalid verification token")
+	}
+
+	if verification.IsExpired() {
+		return failure.BadRequestFromString("verification token expired")
+	}
+
+	verified, err := s.EmailVerificationRepository.UseEmailVerification(verification)
+	if err != nil {
+		return
+	}
+
+	if !verified {
+		return failure.BadRequestFromString("invalid verification token")
+	}
+
+	return
+}
+
+// ResendEmailVerification sends a new verification email to a user whose
+// address is not verified yet. Consecutive emails are at least
+// App.User.EmailVerification.ResendIntervalSeconds apart.
+func (s *UserServiceImpl) ResendEmailVerification(userID uuid.UUID) (err error) {
+	user, err := s.ResolveByID(userID)
+	if err != nil {
+		return
+	}
+
+	if user.IsEmailVerified() {
+		return failure.Conflict("resend", "email verification", "email already verified")
+	}
+
+	latest, err := s.EmailVerificationRepository.ResolveLatestEmailVerificationByUserID(userID)
+	if err != nil && failure.GetCode(err) != http.StatusNotFound {
+		return
+	}
+
+	if err == nil && time.Since(latest.CreatedAt) < s.emailVerificationResendInterval() {
+		return failure.TooManyRequests("a verification email was sent recently, please try again later")
+	}
+
+	return s.sendEmailVerification(user.ID, user.Name, user.Email)
+}
+
+// ForgotPassword emails a password reset link to the user with the given
+// email address. To not disclose which addresses have an account, it succeeds
+// whether or not there is such a user, and failures are only logged. Reset
+// emails to the same user are at least
+// App.User.PasswordReset.ResendIntervalSeconds apart.
+func (s *UserServiceImpl) ForgotPassword(forgotPasswordRequestFormat ForgotPasswordRequestFormat) (err error) {
+	userLogin, err := s.UserRepository.ResolveLoginByEmail(forgotPasswordRequestFormat.Email)
+	if err != nil {
+		if failure.GetCode(err) != http.StatusNotFound {
+			logger.ErrorWithStack(err)
+		}
+		return nil
+	}
+
+	if userLogin.DeletedAt.Valid {
+		return nil
+	}
+
+	latest, err := s.PasswordResetRepository.ResolveLatestPasswordResetByUserID(userLogin.ID)
+	if err == nil && time.Since(latest.CreatedAt) < s.passwordResetResendInterval() {
+		return nil
+	}
+
+	if err := s.sendPasswordReset(userLogin.ID, userLogin.Name, userLogin.Email); err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset email. Every
+// access and refresh token issued to the user so far is revoked.
+func (s *UserServiceImpl) ResetPassword(resetPasswordRequestFormat ResetPasswordRequestFormat) (err error) {
+	reset, err := s.PasswordResetRepository.ResolvePasswordResetByTokenHash(HashPasswordResetToken(resetPasswordRequestFormat.Token))
+	if err != nil {
+		if failure.GetCode(err) == http.StatusNotFound {
+			err = failure.BadRequestFromString("invalid reset token")
+		}
+		return
+	}
+
+	if reset.IsUsed() {
+		return failure.BadRequestFromString("invalid reset token")
+	}
+
+	if reset.IsExpired() {
+		return failure.BadRequestFromString("reset token expired")
+	}
+
+	user, err := s.UserRepository.ResolveByID(reset.UserID)
+	if err != nil {
+		return
+	}
+
+	if user.IsDeleted() {
+		return failure.BadRequestFromString("invalid reset token")
+	}
+
+	err = NewPasswordPolicy(s.Config).Validate(resetPasswordRequestFormat.Password, user.Username, user.Email)
+	if err != nil {
+		return
+	}
+
+	passwordHash, err := hashPassword(resetPasswordRequestFormat.Password)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	used, err := s.PasswordResetRepository.UsePasswordReset(reset, passwordHash)
+	if err != nil {
+		return
+	}
+
+	if !used {
+		return failure.BadRequestFromString("invalid reset token")
+	}
+
+	return s.revokeAllTokens(user.ID)
+}
+
+// ChangePassword changes the password of the caller, who must know the
+// current one. When asked to sign out other sessions, every token issued to
+// the caller so far is revoked, and a new token pair is returned for the
+// current session.
+func (s *UserServiceImpl) ChangePassword(claims *shared.Claims, changePasswordRequestFormat ChangePasswordRequestFormat) (tokens TokenPair, err error) {
+	user, err := s.ResolveByID(claims.UserID)
+	if err != nil {
+		return
+	}
+
+	if !checkPasswordHash(changePasswordRequestFormat.CurrentPassword, user.Password) {
+		return tokens, failure.BadRequestFromString("invalid current password")
+	}
+
+	if changePasswordRequestFormat.NewPassword == changePasswordRequestFormat.CurrentPassword {
+		return tokens, failure.BadRequestFromString("new password must differ from the current password")
+	}
+
+	err = NewPasswordPolicy(s.Config).Validate(changePasswordRequestFormat.NewPassword, user.Username, user.Email)
+	if err != nil {
+		return
+	}
+
+	user.Password, err = hashPassword(changePasswordRequestFormat.NewPassword)
+	if err != nil {
+		return tokens, failure.InternalError(err)
+	}
+
+	user.UpdatedAt = null.TimeFrom(time.Now())
+	user.UpdatedBy = nuuid.From(claims.UserID)
+
+	err = s.UserRepository.UpdatePassword(user)
+	if err != nil {
+		return
+	}
+
+	if !changePasswordRequestFormat.SignOutOtherSessions {
+		return
 	}
 
-	accessToken, err := s.createToken(userLogin.ID, userLogin.Username, userLogin.Email)
+	err = s.revokeAllTokens(user.ID)
 	if err != nil {
 		return
 	}
 
-	userLogin.AccessToken = accessToken
+	return s.createTokenPair(user.ID, user.Username, user.Email, user.IsEmailVerified())
+}
+
+// UpdateProfile applies a partial update to the profile of the caller. A new
+// username or email address must not be taken by another user. Changing the
+// email address requires the current password, marks the address as
+// unverified and sends a verification email to the new address. As JWTs carry
+// the username and email address, changing either revokes every token of the
+// user and returns a new token pair for the caller.
+func (s *UserServiceImpl) UpdateProfile(claims *shared.Claims, userRequestFormat UserRequestFormat) (user User, tokens *TokenPair, err error) {
+	user, err = s.ResolveByID(claims.UserID)
+	if err != nil {
+		return
+	}
+
+	changed := false
+	claimsChanged := false
+	if userRequestFormat.Name != nil && *userRequestFormat.Name != user.Name {
+		user.Name = *userRequestFormat.Name
+		changed = true
+	}
+
+	if userRequestFormat.Username != nil && *userRequestFormat.Username != user.Username {
+		exists, err := s.UserRepository.ExistByUsername(*userRequestFormat.Username)
+		if err != nil {
+			return user, nil, err
+		}
+
+		if exists {
+			return user, nil, failure.Conflict("update", "username", "already exists")
+		}
+
+		user.Username = *userRequestFormat.Username
+		changed = true
+		claimsChanged = true
+	}
+
+	emailChanged := false
+	if userRequestFormat.Email != nil && *userRequestFormat.Email != user.Email {
+		if !checkPasswordHash(userRequestFormat.CurrentPassword, user.Password) {
+			return user, nil, failure.BadRequestFromString("invalid current password")
+		}
+
+		if !emailRegex.MatchString(*userRequestFormat.Email) {
+			return user, nil, failure.BadRequestFromString("invalid email format")
+		}
+
+		exists, err := s.UserRepository.ExistByEmail(*userRequestFormat.Email)
+		if err != nil {
+			return user, nil, err
+		}
+
+		if exists {
+			return user, nil, failure.Conflict("update", "email", "already exists")
+		}
+
+		user.Email = *userRequestFormat.Email
+		user.EmailVerifiedAt = null.Time{}
+		changed = true
+		claimsChanged = true
+		emailChanged = true
+	}
+
+	if !changed {
+		return
+	}
+
+	user.UpdatedAt = null.TimeFrom(time.Now())
+	user.UpdatedBy = nuuid.From(claims.UserID)
+
+	err = s.UserRepository.UpdateProfile(user)
+	if err != nil {
+		return
+	}
+
+	if claimsChanged {
+		err = s.revokeAllTokens(user.ID)
+		if err != nil {
+			return
+		}
+
+		pair, err := s.createTokenPair(user.ID, user.Username, user.Email, user.IsEmailVerified())
+		if err != nil {
+			return user, nil, err
+		}
+		tokens = &pair
+	}
+
+	// The profile is updated at this point, so a failed delivery is only
+	// logged. The user can ask for the verification email to be sent again.
+	if emailChanged {
+		if err := s.sendEmailVerification(user.ID, user.Name, user.Email); err != nil {
+			logger.ErrorWithStack(err)
+		}
+	}
 
 	return
 }
 
+// DeleteAccount soft-deletes the account of the caller, who must confirm it
+// with their password. Every token issued to the user is revoked and their
+// data exports are removed. The personal data of the account is anonymized by
+// the AccountPurger once the retention period is over.
+func (s *UserServiceImpl) DeleteAccount(claims *shared.Claims, deleteAccountRequestFormat DeleteAccountRequestFormat) (err error) {
+	user, err := s.ResolveByID(claims.UserID)
+	if err != nil {
+		return
+	}
+
+	if !checkPasswordHash(deleteAccountRequestFormat.Password, user.Password) {
+		return failure.BadRequestFromString("invalid password")
+	}
+
+	user.DeletedAt = null.TimeFrom(time.Now())
+	user.DeletedBy = nuuid.From(claims.UserID)
+
+	err = s.UserRepository.DeleteUser(user)
+	if err != nil {
+		return
+	}
+
+	return s.revokeAllTokens(user.ID)
+}
+
 // Internal Functions
-func (s *UserServiceImpl) createToken(ID uuid.UUID, username string, email string) (accessToken string, err error) {
-	jwtService := shared.ProvideJWTService(s.Config.App.Secret)
-	accessToken, err = jwtService.GenerateJWT(ID, username, email)
+func (s *UserServiceImpl) verifyOpenIDClient(clientID string) (err error) {
+	client, err := s.OAuthTokenStore.ResolveClient(clientID)
+	if err != nil {
+		if err.Error() == oauth.ErrorClientNotFound {
+			return failure.BadRequestFromString("unknown client")
+		}
+		logger.ErrorWithStack(err)
+		return failure.InternalError(err)
+	}
+
+	if !client.AllowsOpenID() {
+		return failure.BadRequestFromString("client is not allowed the openid scope")
+	}
+
+	return
+}
+
+func (s *UserServiceImpl) sendEmailVerification(userID uuid.UUID, name string, email string) (err error) {
+	verification, token, err := NewEmailVerification(userID, email, s.emailVerificationExpiration(), s.Config.App.Secret)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	err = s.EmailVerificationRepository.CreateEmailVerification(verification)
 	if err != nil {
 		return
 	}
 
+	return s.EmailVerificationSender.SendEmailVerification(name, email, s.emailVerificationLink(token))
+}
+
+func (s *UserServiceImpl) sendPasswordReset(userID uuid.UUID, name string, email string) (err error) {
+	reset, token, err := NewPasswordReset(userID, s.passwordResetExpiration())
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	err = s.PasswordResetRepository.CreatePasswordReset(reset)
+	if err != nil {
+		return
+	}
+
+	return s.PasswordResetSender.SendPasswordReset(name, email, s.passwordResetLink(token))
+}
+
+func (s *UserServiceImpl) passwordResetLink(token string) string {
+	resetURL := s.Config.App.User.PasswordReset.URL
+	if resetURL == "" {
+		resetURL = strings.TrimRight(s.Config.App.URL, "/") + "/reset-password"
+	}
+
+	return resetURL + "?token=" + url.QueryEscape(token)
+}
+
+func (s *UserServiceImpl) passwordResetExpiration() time.Duration {
+	if s.Config.App.User.PasswordReset.ExpirySeconds <= 0 {
+		return DefaultPasswordResetExpiration
+	}
+
+	return time.Duration(s.Config.App.User.PasswordReset.ExpirySeconds) * time.Second
+}
+
+func (s *UserServiceImpl) passwordResetResendInterval() time.Duration {
+	if s.Config.App.User.PasswordReset.ResendIntervalSeconds <= 0 {
+		return DefaultPasswordResetResendInterval
+	}
+
+	return time.Duration(s.Config.App.User.PasswordReset.ResendIntervalSeconds) * time.Second
+}
+
+// revokeAllTokens signs a user out of every session by revoking all access
+// tokens issued so far and all refresh tokens, including the opaque OAuth
+// tokens issued on behalf of the user.
+func (s *UserServiceImpl) revokeAllTokens(userID uuid.UUID) (err error) {
+	err = s.TokenDenylist.RevokeAllForUser(userID)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	err = s.RefreshTokenRepository.RevokeRefreshTokensByUserID(userID)
+	if err != nil {
+		return
+	}
+
+	err = s.OAuthTokenStore.RevokeUserTokens(userID.String())
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return failure.InternalError(err)
+	}
+
+	return
+}
+
+func (s *UserServiceImpl) emailVerificationLink(token string) string {
+	verificationURL := s.Config.App.User.EmailVerification.URL
+	if verificationURL == "" {
+		verificationURL = strings.TrimRight(s.Config.App.URL, "/") + "/verify-email"
+	}
+
+	return verificationURL + "?token=" + url.QueryEscape(token)
+}
+
+func (s *UserServiceImpl) emailVerificationExpiration() time.Duration {
+	if s.Config.App.User.EmailVerification.ExpirySeconds <= 0 {
+		return DefaultEmailVerificationExpiration
+	}
+
+	return time.Duration(s.Config.App.User.EmailVerification.ExpirySeconds) * time.Second
+}
+
+func (s *UserServiceImpl) emailVerificationResendInterval() time.Duration {
+	if s.Config.App.User.EmailVerification.ResendIntervalSeconds <= 0 {
+		return DefaultEmailVerificationResendInterval
+	}
+
+	return time.Duration(s.Config.App.User.EmailVerification.ResendIntervalSeconds) * time.Second
+}
+
+// accessTokenScope limits the JWTs of users whose email address is not
+// verified yet, when App.User.EmailVerification.RestrictUnverified is set.
+// The OAuth grants refuse to issue tokens to such users instead.
+func (s *UserServiceImpl) accessTokenScope(emailVerified bool) string {
+	if emailVerified || !s.Config.App.User.EmailVerification.RestrictUnverified {
+		return ""
+	}
+
+	return shared.UnverifiedScope(s.Config)
+}
+
+func (s *UserServiceImpl) createTokenPair(ID uuid.UUID, username string, email string, emailVerified bool) (tokens TokenPair, err error) {
+	tokens, err = s.createAccessToken(ID, username, email, emailVerified)
+	if err != nil {
+		return
+	}
+
+	familyID, err := uuid.NewV4()
+	if err != nil {
+		return tokens, failure.InternalError(err)
+	}
+
+	refreshToken, plain, err := NewRefreshToken(ID, familyID, s.refreshTokenExpiration())
+	if err != nil {
+		return tokens, failure.InternalError(err)
+	}
+
+	err = s.RefreshTokenRepository.CreateRefreshToken(refreshToken)
+	if err != nil {
+		return
+	}
+
+	tokens.RefreshToken = plain
+	tokens.RefreshTokenExpiresAt = refreshToken.ExpiresAt
+
+	return
+}
+
+// createAccessToken signs a JWT carrying the current roles and permissions of
+// the user.
+func (s *UserServiceImpl) createAccessToken(ID uuid.UUID, username string, email string, emailVerified bool) (tokens TokenPair, err error) {
+	authorities, err := s.RBACService.ResolveAuthorities(ID)
+	if err != nil {
+		return
+	}
+
+	tokens.AccessToken, tokens.AccessTokenExpiresAt, err = s.JWTService.GenerateScopedJWT(ID, username, email, s.accessTokenScope(emailVerified), authorities)
+
 	return
 }
 
+func (s *UserServiceImpl) refreshTokenExpiration() time.Duration {
+	if s.Config.App.JWT.RefreshTokenExpirySeconds <= 0 {
+		return DefaultRefreshTokenExpiration
+	}
+
+	return time.Duration(s.Config.App.JWT.RefreshTokenExpirySeconds) * time.Second
+}
+
+func (s *UserServiceImpl) revokeReusedFamily(token RefreshToken) {
+	log.Warn().
+		Str("userId", token.UserID.String()).
+		Str("familyId", token.FamilyID.String()).
+		Msg("Refresh token reuse detected, revoking token family.")
+
+	if err := s.RefreshTokenRepository.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		logger.ErrorWithStack(err)
+	}
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
 func checkPasswordHash(password, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
@@ -0,0 +1,183 @@
+package user_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/internal/domain/rbac"
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRefreshTokenRepository keeps refresh tokens in memory. When
+// loseRotation is set, rotations behave as if a concurrent refresh had
+// rotated the token first.
+type fakeRefreshTokenRepository struct {
+	tokens          map[string]user.RefreshToken
+	loseRotation    bool
+	revokedFamilies []uuid.UUID
+}
+
+func (r *fakeRefreshTokenRepository) CreateRefreshToken(token user.RefreshToken) (err error) {
+	r.tokens[token.TokenHash] = token
+	return
+}
+
+func (r *fakeRefreshTokenRepository) ResolveRefreshTokenByHash(tokenHash string) (token user.RefreshToken, err error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		err = failure.NotFound("refresh token")
+	}
+	return
+}
+
+func (r *fakeRefreshTokenRepository) RotateRefreshToken(current user.RefreshToken, next user.RefreshToken) (rotated bool, err error) {
+	stored := r.tokens[current.TokenHash]
+	if r.loseRotation || stored.IsRevoked() {
+		return false, nil
+	}
+
+	current.RevokedAt = null.TimeFrom(next.CreatedAt)
+	r.tokens[current.TokenHash] = current
+	r.tokens[next.TokenHash] = next
+
+	return true, nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeRefreshTokenFamily(familyID uuid.UUID) (err error) {
+	r.revokedFamilies = append(r.revokedFamilies, familyID)
+	for hash, token := range r.tokens {
+		if token.FamilyID == familyID && !token.IsRevoked() {
+			token.RevokedAt = null.TimeFrom(time.Now())
+			r.tokens[hash] = token
+		}
+	}
+	return
+}
+
+func (r *fakeRefreshTokenRepository) RevokeRefreshTokensByUserID(userID uuid.UUID) (err error) {
+	for hash, token := range r.tokens {
+		if token.UserID == userID && !token.IsRevoked() {
+			token.RevokedAt = null.TimeFrom(time.Now())
+			r.tokens[hash] = token
+		}
+	}
+	return
+}
+
+type fakeUserRepository struct {
+	user.UserRepository
+	users map[uuid.UUID]user.User
+}
+
+func (r *fakeUserRepository) ResolveByID(id uuid.UUID) (u user.User, err error) {
+	u, ok := r.users[id]
+	if !ok {
+		err = failure.NotFound("user")
+	}
+	return
+}
+
+type fakeRBACService struct {
+	rbac.RBACService
+}
+
+func (s *fakeRBACService) ResolveAuthorities(userID uuid.UUID) (authorities shared.Authorities, err error) {
+	return
+}
+
+func TestUserServiceRefreshToken(t *testing.T) {
+	userID, _ := uuid.NewV4()
+
+	newService := func() (*user.UserServiceImpl, *fakeRefreshTokenRepository) {
+		refreshTokens := &fakeRefreshTokenRepository{tokens: map[string]user.RefreshToken{}}
+		s := &user.UserServiceImpl{
+			UserRepository: &fakeUserRepository{users: map[uuid.UUID]user.User{
+				userID: {ID: userID, Name: "John", Username: "john", Email: "john@example.com"},
+			}},
+			RefreshTokenRepository: refreshTokens,
+			RBACService:            &fakeRBACService{},
+			JWTService:             shared.NewJWTService("secret", time.Minute),
+			Config:                 &configs.Config{},
+		}
+		return s, refreshTokens
+	}
+
+	issue := func(refreshTokens *fakeRefreshTokenRepository, ttl time.Duration) (user.RefreshToken, string) {
+		familyID, _ := uuid.NewV4()
+		token, plain, err := user.NewRefreshToken(userID, familyID, ttl)
+		assert.NoError(t, err)
+		assert.NoError(t, refreshTokens.CreateRefreshToken(token))
+		return token, plain
+	}
+
+	t.Run("Rotate", func(t *testing.T) {
+		s, refreshTokens := newService()
+		current, plain := issue(refreshTokens, time.Hour)
+
+		tokens, err := s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: plain})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEqual(t, plain, tokens.RefreshToken)
+
+		rotated := refreshTokens.tokens[current.TokenHash]
+		assert.True(t, rotated.IsRevoked())
+
+		next := refreshTokens.tokens[user.HashRefreshToken(tokens.RefreshToken)]
+		assert.Equal(t, current.FamilyID, next.FamilyID)
+		assert.False(t, next.IsRevoked())
+		assert.Empty(t, refreshTokens.revokedFamilies)
+	})
+
+	t.Run("Replay revokes family", func(t *testing.T) {
+		s, refreshTokens := newService()
+		current, plain := issue(refreshTokens, time.Hour)
+
+		tokens, err := s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: plain})
+		assert.NoError(t, err)
+
+		_, err = s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: plain})
+		assert.Equal(t, failure.Unauthorized("invalid refresh token"), err)
+		assert.Equal(t, []uuid.UUID{current.FamilyID}, refreshTokens.revokedFamilies)
+
+		_, err = s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: tokens.RefreshToken})
+		assert.Equal(t, failure.Unauthorized("invalid refresh token"), err)
+	})
+
+	t.Run("Lost rotation race revokes family", func(t *testing.T) {
+		s, refreshTokens := newService()
+		current, plain := issue(refreshTokens, time.Hour)
+		refreshTokens.loseRotation = true
+
+		_, err := s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: plain})
+		assert.Equal(t, failure.Unauthorized("invalid refresh token"), err)
+		assert.Equal(t, []uuid.UUID{current.FamilyID}, refreshTokens.revokedFamilies)
+
+		revoked := refreshTokens.tokens[current.TokenHash]
+		assert.True(t, revoked.IsRevoked())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		s, refreshTokens := newService()
+		current, plain := issue(refreshTokens, -time.Minute)
+
+		_, err := s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: plain})
+		assert.Equal(t, failure.Unauthorized("refresh token expired"), err)
+		assert.Len(t, refreshTokens.tokens, 1)
+
+		expired := refreshTokens.tokens[current.TokenHash]
+		assert.False(t, expired.IsRevoked())
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		s, _ := newService()
+
+		_, err := s.RefreshToken(user.RefreshTokenRequestFormat{RefreshToken: "unknown"})
+		assert.Equal(t, failure.Unauthorized("invalid refresh token"), err)
+	})
+}
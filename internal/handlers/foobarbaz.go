@@ -7,6 +7,7 @@ import (
 
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/authctx"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/response"
@@ -55,6 +56,7 @@ func (h *FooBarBazHandler) Router(r chi.Router) {
 // @Produce json
 // @Success 201 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo [post]
@@ -73,9 +75,13 @@ func (h *FooBarBazHandler) CreateFoo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
+	principal, ok := authctx.PrincipalFromContext(r.Context())
+	if !ok || !principal.IsUser() {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
 
-	foo, err := h.FooService.Create(requestFormat, userID)
+	foo, err := h.FooService.Create(requestFormat, principal.UserID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -126,6 +132,7 @@ func (h *FooBarBazHandler) ResolveFooByID(w http.ResponseWriter, r *http.Request
 // @Produce json
 // @Success 200 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo/{id} [delete]
@@ -137,9 +144,13 @@ func (h *FooBarBazHandler) SoftDeleteFoo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
+	principal, ok := authctx.PrincipalFromContext(r.Context())
+	if !ok || !principal.IsUser() {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
 
-	foo, err := h.FooService.SoftDelete(id, userID)
+	foo, err := h.FooService.SoftDelete(id, principal.UserID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -158,6 +169,7 @@ func (h *FooBarBazHandler) SoftDeleteFoo(w http.ResponseWriter, r *http.Request)
 // @Produce json
 // @Success 200 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo/{id} [put]
@@ -183,9 +195,13 @@ func (h *FooBarBazHandler) UpdateFoo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
+	principal, ok := authctx.PrincipalFromContext(r.Context())
+	if !ok || !principal.IsUser() {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
 
-	foo, err := h.FooService.Update(id, requestFormat, userID)
+	foo, err := h.FooService.Update(id, requestFormat, principal.UserID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/evermos/boilerplate-go/shared/authctx"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+)
+
+// OAuthHandler is the HTTP handler for the OAuth 2.0 and OpenID Connect endpoints.
+type OAuthHandler struct {
+	Token          *oauth.Token
+	UserService    user.UserService
+	AuthMiddleware *middleware.Authentication
+}
+
+// ProvideOAuthHandler is the provider for this handler.
+func ProvideOAuthHandler(token *oauth.Token, userService user.UserService, authMiddleware *middleware.Authentication) OAuthHandler {
+	return OAuthHandler{
+		Token:          token,
+		UserService:    userService,
+		AuthMiddleware: authMiddleware,
+	}
+}
+
+// Router sets up the router for this handler.
+func (h *OAuthHandler) Router(r chi.Router) {
+	r.Route("/oauth", func(r chi.Router) {
+		r.Post("/token", h.IssueToken)
+		r.Post("/introspect", h.Introspect)
+		r.Post("/revoke", h.Revoke)
+		r.Post("/device_authorization", h.AuthorizeDevice)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.JWTOrUserScopes(oauth.ScopeOpenID))
+			r.Get("/userinfo", h.UserInfo)
+			r.Post("/userinfo", h.UserInfo)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Get("/authorize", h.Authorize)
+			r.Get("/device", h.ResolveDeviceAuthorization)
+			r.Post("/device", h.DecideDeviceAuthorization)
+		})
+	})
+}
+
+// Authorize issues an authorization code to the client for the logged in user.
+// @Summary OAuth 2.0 authorization endpoint
+// @Description This endpoint issues an authorization code as described in RFC 6749. It is an API step rather
+// @Description than a page: a browser can't send the JWT returned from /v1/users/login on a redirect, so the
+// @Description client's authorization request is first sent to a login page of the first-party frontend. Once
+// @Description the user has logged in and consented, that page calls this endpoint with the query of the
+// @Description authorization request and the user's JWT, and sends the browser to the returned location,
+// @Description which carries either the code or the error back to the client. PKCE with the S256 method is
+// @Description mandatory, and the redirect URI must exactly match one registered for the client.
+// @Tags oauth
+// @Security EVMOauthToken
+// @Produce json
+// @Param response_type query string true "Must be code."
+// @Param client_id query string true "The client ID."
+// @Param redirect_uri query string true "One of the redirect URIs registered for the client."
+// @Param scope query string false "The space-delimited scopes. Include openid to receive an ID token."
+// @Param state query string false "An opaque value that is passed back to the client."
+// @Param nonce query string false "A value that is passed back in the ID token."
+// @Param code_challenge query string true "The PKCE code challenge."
+// @Param code_challenge_method query string true "Must be S256."
+// @Success 200 {object} response.Base{data=oauth.AuthorizationRedirect}
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} response.Base
+// @Router /oauth/authorize [get]
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	query := r.URL.Query()
+	authorization, err := h.Token.Authorize(oauth.AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		UserID:              claims.UserID.String(),
+		AuthTime:            time.Unix(claims.IssuedAt, 0),
+	})
+
+	// Without a valid redirect URI, the error is shown to the user instead of
+	// being sent to a URI that may not belong to the client.
+	if authorization.RedirectURI == "" {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	if err != nil && oauth.ToError(err).Code == oauth.ErrorCodeServerError {
+		logger.ErrorWithStack(err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WithJSON(w, http.StatusOK, oauth.AuthorizationRedirect{Location: authorization.Location(err)})
+}
+
+// AuthorizeDevice starts a device authorization.
+// @Summary OAuth 2.0 device authorization endpoint
+// @Description This endpoint issues a device code and a user code as described in RFC 8628. The device shows
+// @Description the user code and verification URI to the user, and polls the token endpoint with the
+// @Description urn:ietf:params:oauth:grant-type:device_code grant until the user has decided. The calling
+// @Description client authenticates like on the token endpoint. The verification URI is the page of the
+// @Description first-party frontend configured as App.OAuth.DeviceVerificationURI, which calls /oauth/device
+// @Description with the user's JWT. The grant is disabled when no verification URI is configured.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param client_id formData string false "The client ID, when not using HTTP Basic authentication."
+// @Param client_secret formData string false "The client secret, when not using HTTP Basic authentication."
+// @Param scope formData string false "The space-delimited scopes requested."
+// @Success 200 {object} oauth.DeviceAuthorizationResponse
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/device_authorization [post]
+func (h *OAuthHandler) AuthorizeDevice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	credential, err := h.parseClientCredential(r)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+	credential.Scope = r.PostForm.Get("scope")
+
+	authorization, err := h.Token.AuthorizeDevice(credential)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	response.WithRawJSON(w, http.StatusOK, authorization)
+}
+
+// ResolveDeviceAuthorization shows the pending device authorization of a user code.
+// @Summary Resolve a device authorization
+// @Description This endpoint returns the client and scopes of the device authorization a user code was issued
+// @Description for, so that the logged in user can check it before approving it.
+// @Tags oauth
+// @Security EVMOauthToken
+// @Produce json
+// @Param user_code query string true "The user code shown on the device."
+// @Success 200 {object} oauth.DeviceAuthorization
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} response.Base
+// @Router /oauth/device [get]
+func (h *OAuthHandler) ResolveDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	authorization, err := h.Token.ResolveDeviceAuthorization(r.URL.Query().Get("user_code"))
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	response.WithRawJSON(w, http.StatusOK, authorization)
+}
+
+// DecideDeviceAuthorization approves or denies a device authorization.
+// @Summary Approve or deny a device authorization
+// @Description This endpoint records the decision of the logged in user on the device authorization a user
+// @Description code was issued for. Once approved, the device receives an access token for the user on its
+// @Description next poll.
+// @Tags oauth
+// @Security EVMOauthToken
+// @Accept x-www-form-urlencoded
+// @Param user_code formData string true "The user code shown on the device."
+// @Param approve formData bool true "Whether the user approves the device."
+// @Success 204
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} response.Base
+// @Router /oauth/device [post]
+func (h *OAuthHandler) DecideDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	approve, err := strconv.ParseBool(r.PostForm.Get("approve"))
+	if err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, "approve must be true or false"))
+		return
+	}
+
+	err = h.Token.DecideDeviceAuthorization(r.PostForm.Get("user_code"), claims.UserID.String(), approve)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserInfo returns the claims about the authenticated user.
+// @Summary OpenID Connect UserInfo
+// @Description This endpoint returns the profile of the user the access token was issued to. It accepts either
+// @Description the JWT returned from /v1/users/login, or an OAuth access token issued on behalf of the user
+// @Description with the openid scope.
+// @Tags oauth
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} user.UserInfoResponseFormat
+// @Failure 401 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Router /oauth/userinfo [get]
+func (h *OAuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authctx.PrincipalFromContext(r.Context())
+	if !ok || !principal.IsUser() {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	u, err := h.UserService.ResolveByID(principal.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithRawJSON(w, http.StatusOK, u.ToUserInfoFormat())
+}
+
+// IssueToken issues an access token for one of the supported grants.
+// @Summary OAuth 2.0 token endpoint
+// @Description This endpoint issues an access token as described in RFC 6749. The client authenticates either
+// @Description with HTTP Basic authentication or with the client_id and client_secret form parameters.
+// @Description The token-exchange grant of RFC 8693 lets a confidential client swap a token of a user for a
+// @Description down-scoped JWT for one audience, whose act claim names the client.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "The grant type, client_credentials, password, authorization_code, refresh_token, urn:ietf:params:oauth:grant-type:device_code or urn:ietf:params:oauth:grant-type:token-exchange."
+// @Param client_id formData string false "The client ID, when not using HTTP Basic authentication."
+// @Param client_secret formData string false "The client secret, when not using HTTP Basic authentication."
+// @Param username formData string false "The username or email of the user, for the password grant."
+// @Param password formData string false "The password, for the password grant."
+// @Param code formData string false "The authorization code, for the authorization_code grant."
+// @Param redirect_uri formData string false "The redirect URI the code was issued for, for the authorization_code grant."
+// @Param code_verifier formData string false "The PKCE code verifier, for the authorization_code grant."
+// @Param refresh_token formData string false "The refresh token, for the refresh_token grant."
+// @Param device_code formData string false "The device code, for the device_code grant."
+// @Param subject_token formData string false "The token of the user, for the token-exchange grant."
+// @Param subject_token_type formData string false "urn:ietf:params:oauth:token-type:jwt or urn:ietf:params:oauth:token-type:access_token, for the token-exchange grant."
+// @Param requested_token_type formData string false "Must be urn:ietf:params:oauth:token-type:jwt when set, for the token-exchange grant."
+// @Param audience formData string false "The service the exchanged token is for, for the token-exchange grant."
+// @Param scope formData string false "The space-delimited scopes requested."
+// @Success 200 {object} oauth.TokenResponse
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/token [post]
+func (h *OAuthHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	credential, err := h.parseCredential(r)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	token, err := h.Token.Create(credential)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	response.WithRawJSON(w, http.StatusOK, token)
+}
+
+// Introspect reports whether a token is active.
+// @Summary OAuth 2.0 token introspection
+// @Description This endpoint returns the state of an opaque access token, refresh token or JWT as described
+// @Description in RFC 7662. The calling client authenticates like on the token endpoint, and must be a
+// @Description confidential client.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "The token to introspect."
+// @Param token_type_hint formData string false "access_token or refresh_token."
+// @Success 200 {object} oauth.IntrospectionResponse
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	credential, err := h.parseClientCredential(r)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	introspection, err := h.Token.Introspect(credential, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	response.WithRawJSON(w, http.StatusOK, introspection)
+}
+
+// Revoke revokes a token.
+// @Summary OAuth 2.0 token revocation
+// @Description This endpoint revokes an opaque access token, refresh token or JWT as described in RFC 7009.
+// @Description Revoking a refresh token revokes every refresh token rotated from the same grant. Unknown
+// @Description tokens, and tokens issued to other clients, are ignored. A JWT can only be revoked by the
+// @Description client it was delegated to; users revoke their own JWTs by logging out. The calling client
+// @Description authenticates like on the token endpoint.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "The token to revoke."
+// @Param token_type_hint formData string false "access_token or refresh_token."
+// @Success 200
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	credential, err := h.parseClientCredential(r)
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	err = h.Token.Revoke(credential, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		h.respondWithOAuthError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseCredential reads the grant parameters from the request body, and the
+// client credentials from either the Authorization header or the body.
+func (h *OAuthHandler) parseCredential(r *http.Request) (credential oauth.Credential, err error) {
+	credential, err = h.parseClientCredential(r)
+	if err != nil {
+		return
+	}
+
+	credential.GrantType = oauth.GrantType(r.PostForm.Get("grant_type"))
+	credential.Username = r.PostForm.Get("username")
+	credential.Password = r.PostForm.Get("password")
+	credential.Code = r.PostForm.Get("code")
+	credential.RedirectURI = r.PostForm.Get("redirect_uri")
+	credential.CodeVerifier = r.PostForm.Get("code_verifier")
+	credential.RefreshToken = r.PostForm.Get("refresh_token")
+	credential.DeviceCode = r.PostForm.Get("device_code")
+	credential.SubjectToken = r.PostForm.Get("subject_token")
+	credential.SubjectTokenType = r.PostForm.Get("subject_token_type")
+	credential.RequestedTokenType = r.PostForm.Get("requested_token_type")
+	credential.Audience = r.PostForm.Get("audience")
+	credential.Parameters = r.PostForm
+	credential.Scope = r.PostForm.Get("scope")
+
+	if credential.GrantType == "" {
+		err = errors.New(oauth.ErrorMissingGrantType)
+		return
+	}
+
+	return
+}
+
+// parseClientCredential reads the client credentials from either the
+// Authorization header or the request body.
+func (h *OAuthHandler) parseClientCredential(r *http.Request) (credential oauth.Credential, err error) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		if r.PostForm.Get("client_secret") != "" {
+			err = errors.New(oauth.ErrorMultipleClientAuthMethod)
+			return
+		}
+
+		// The client credentials are form-encoded before they are put into
+		// the Authorization header, see RFC 6749, section 2.3.1.
+		if credential.ClientID, err = url.QueryUnescape(clientID); err != nil {
+			err = errors.New(oauth.ErrorInvalidClient)
+			return
+		}
+		if credential.ClientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			err = errors.New(oauth.ErrorInvalidClient)
+			return
+		}
+	} else {
+		credential.ClientID = r.PostForm.Get("client_id")
+		credential.ClientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if credential.ClientID == "" {
+		err = errors.New(oauth.ErrorInvalidClient)
+		return
+	}
+
+	return
+}
+
+// respondWithOAuthError sends an error response in the format of RFC 6749, section 5.2.
+func (h *OAuthHandler) respondWithOAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	oauthErr := oauth.ToError(err)
+	if oauthErr.Code == oauth.ErrorCodeServerError {
+		logger.ErrorWithStack(err)
+	}
+
+	if oauthErr.Code == oauth.ErrorCodeInvalidClient {
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+
+	response.WithRawJSON(w, oauthErr.StatusCode(), oauthErr)
+}
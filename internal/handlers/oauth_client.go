@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/internal/domain/oauthclient"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+)
+
+// OAuthClientHandler is the HTTP handler for managing OAuth clients.
+type OAuthClientHandler struct {
+	ClientService  oauthclient.ClientService
+	AuthMiddleware *middleware.Authentication
+}
+
+// ProvideOAuthClientHandler is the provider for this handler.
+func ProvideOAuthClientHandler(clientService oauthclient.ClientService, authMiddleware *middleware.Authentication) OAuthClientHandler {
+	return OAuthClientHandler{
+		ClientService:  clientService,
+		AuthMiddleware: authMiddleware,
+	}
+}
+
+// Router sets up the router for this domain.
+func (h *OAuthClientHandler) Router(r chi.Router) {
+	r.Route("/oauth/clients", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Use(h.AuthMiddleware.RequireAdmin)
+			r.Get("/", h.ResolveClients)
+			r.Post("/", h.CreateClient)
+			r.Get("/{id}", h.ResolveClientByID)
+			r.Put("/{id}", h.UpdateClient)
+			r.Delete("/{id}", h.DeleteClient)
+			r.Post("/{id}/disable", h.DisableClient)
+			r.Post("/{id}/enable", h.EnableClient)
+			r.Post("/{id}/secret", h.RotateClientSecret)
+		})
+	})
+}
+
+// CreateClient registers a new OAuth client.
+// @Summary Register a new OAuth client.
+// @Description This endpoint registers a new OAuth client. The generated client secret is only returned
+// @Description in this response, and can't be retrieved later. Public clients get no secret.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param client body oauthclient.ClientRequestFormat true "The client to be registered."
+// @Produce json
+// @Success 201 {object} response.Base{data=oauthclient.ClientSecretResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients [post]
+func (h *OAuthClientHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	requestFormat, err := h.decodeRequestFormat(r)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	client, secret, err := h.ClientService.Create(requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WithJSON(w, http.StatusCreated, client.ToSecretResponseFormat(secret))
+}
+
+// ResolveClients lists the registered OAuth clients.
+// @Summary List OAuth clients.
+// @Description This endpoint lists all registered OAuth clients, without their secrets.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=[]oauthclient.ClientResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients [get]
+func (h *OAuthClientHandler) ResolveClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.ClientService.ResolveAll()
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, clients)
+}
+
+// ResolveClientByID resolves an OAuth client by its ID.
+// @Summary Resolve OAuth client by ID.
+// @Description This endpoint resolves an OAuth client by its ID, without its secret.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Produce json
+// @Success 200 {object} response.Base{data=oauthclient.ClientResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id} [get]
+func (h *OAuthClientHandler) ResolveClientByID(w http.ResponseWriter, r *http.Request) {
+	client, err := h.ClientService.ResolveByID(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, client)
+}
+
+// UpdateClient updates an OAuth client.
+// @Summary Update an OAuth client.
+// @Description This endpoint updates the name, redirect URIs, grant types and scopes of an OAuth client.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Param client body oauthclient.ClientRequestFormat true "The client to be updated."
+// @Produce json
+// @Success 200 {object} response.Base{data=oauthclient.ClientResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id} [put]
+func (h *OAuthClientHandler) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	requestFormat, err := h.decodeRequestFormat(r)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	client, err := h.ClientService.Update(chi.URLParam(r, "id"), requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, client)
+}
+
+// DeleteClient deletes an OAuth client.
+// @Summary Delete an OAuth client.
+// @Description This endpoint deletes an OAuth client, together with the tokens and authorization codes
+// @Description issued to it.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Success 204
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id} [delete]
+func (h *OAuthClientHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	err := h.ClientService.Delete(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// DisableClient disables an OAuth client.
+// @Summary Disable an OAuth client.
+// @Description This endpoint prevents an OAuth client from obtaining tokens, and revokes the tokens it holds.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Produce json
+// @Success 200 {object} response.Base{data=oauthclient.ClientResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id}/disable [post]
+func (h *OAuthClientHandler) DisableClient(w http.ResponseWriter, r *http.Request) {
+	client, err := h.ClientService.Disable(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, client)
+}
+
+// EnableClient enables a disabled OAuth client.
+// @Summary Enable an OAuth client.
+// @Description This endpoint allows a disabled OAuth client to obtain tokens again.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Produce json
+// @Success 200 {object} response.Base{data=oauthclient.ClientResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id}/enable [post]
+func (h *OAuthClientHandler) EnableClient(w http.ResponseWriter, r *http.Request) {
+	client, err := h.ClientService.Enable(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, client)
+}
+
+// RotateClientSecret replaces the secret of an OAuth client.
+// @Summary Rotate an OAuth client secret.
+// @Description This endpoint generates a new secret for a confidential OAuth client. The previous secret
+// @Description stops working immediately. The new secret is only returned in this response.
+// @Tags oauth/clients
+// @Security EVMOauthToken
+// @Param id path string true "The client ID."
+// @Produce json
+// @Success 200 {object} response.Base{data=oauthclient.ClientSecretResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/oauth/clients/{id}/secret [post]
+func (h *OAuthClientHandler) RotateClientSecret(w http.ResponseWriter, r *http.Request) {
+	client, secret, err := h.ClientService.RotateSecret(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WithJSON(w, http.StatusOK, client.ToSecretResponseFormat(secret))
+}
+
+func (h *OAuthClientHandler) decodeRequestFormat(r *http.Request) (requestFormat oauthclient.ClientRequestFormat, err error) {
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&requestFormat)
+	if err != nil {
+		err = failure.BadRequest(err)
+		return
+	}
+
+	err = shared.GetValidator().Struct(requestFormat)
+	if err != nil {
+		err = failure.BadRequest(err)
+	}
+
+	return
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/internal/domain/rbac"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/authctx"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+	"github.com/gofrs/uuid"
+)
+
+// RBACHandler is the HTTP handler for managing roles and permissions.
+type RBACHandler struct {
+	RBACService    rbac.RBACService
+	AuthMiddleware *middleware.Authentication
+}
+
+// ProvideRBACHandler is the provider for this handler.
+func ProvideRBACHandler(rbacService rbac.RBACService, authMiddleware *middleware.Authentication) RBACHandler {
+	return RBACHandler{
+		RBACService:    rbacService,
+		AuthMiddleware: authMiddleware,
+	}
+}
+
+// Router sets up the router for this domain.
+func (h *RBACHandler) Router(r chi.Router) {
+	r.Route("/roles", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Use(h.AuthMiddleware.RequirePermission(rbac.PermissionManageRBAC))
+			r.Get("/", h.ResolveRoles)
+			r.Post("/", h.CreateRole)
+			r.Get("/{name}", h.ResolveRoleByName)
+			r.Put("/{name}", h.UpdateRole)
+			r.Delete("/{name}", h.DeleteRole)
+			r.Get("/{name}/users", h.ResolveRoleAssignments)
+			r.Put("/{name}/users/{userId}", h.AssignRole)
+			r.Delete("/{name}/users/{userId}", h.UnassignRole)
+		})
+	})
+
+	r.Route("/permissions", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Use(h.AuthMiddleware.RequirePermission(rbac.PermissionManageRBAC))
+			r.Get("/", h.ResolvePermissions)
+			r.Post("/", h.CreatePermission)
+			r.Delete("/{name}", h.DeletePermission)
+		})
+	})
+}
+
+// CreatePermission creates a new permission.
+// @Summary Create a new permission.
+// @Description This endpoint creates a new permission, such as foo:write, that roles can grant.
+// @Tags rbac/permissions
+// @Security EVMOauthToken
+// @Param permission body rbac.PermissionRequestFormat true "The permission to be created."
+// @Produce json
+// @Success 201 {object} response.Base{data=rbac.PermissionResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/permissions [post]
+func (h *RBACHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	var requestFormat rbac.PermissionRequestFormat
+	err := h.decodeRequestFormat(r, &requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	permission, err := h.RBACService.CreatePermission(requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusCreated, permission)
+}
+
+// ResolvePermissions lists the permissions.
+// @Summary List permissions.
+// @Description This endpoint lists all permissions.
+// @Tags rbac/permissions
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=[]rbac.PermissionResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/permissions [get]
+func (h *RBACHandler) ResolvePermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.RBACService.ResolvePermissions()
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, permissions)
+}
+
+// DeletePermission deletes a permission.
+// @Summary Delete a permission.
+// @Description This endpoint deletes a permission. The roles that granted it no longer do.
+// @Tags rbac/permissions
+// @Security EVMOauthToken
+// @Param name path string true "The permission name."
+// @Success 204
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/permissions/{name} [delete]
+func (h *RBACHandler) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	err := h.RBACService.DeletePermission(chi.URLParam(r, "name"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// CreateRole creates a new role.
+// @Summary Create a new role.
+// @Description This endpoint creates a new role granting existing permissions.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param role body rbac.RoleRequestFormat true "The role to be created."
+// @Produce json
+// @Success 201 {object} response.Base{data=rbac.RoleResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles [post]
+func (h *RBACHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var requestFormat rbac.RoleRequestFormat
+	err := h.decodeRequestFormat(r, &requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	role, err := h.RBACService.CreateRole(requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusCreated, role)
+}
+
+// ResolveRoles lists the roles.
+// @Summary List roles.
+// @Description This endpoint lists all roles with the permissions they grant.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=[]rbac.RoleResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles [get]
+func (h *RBACHandler) ResolveRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.RBACService.ResolveRoles()
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, roles)
+}
+
+// ResolveRoleByName resolves a role by its name.
+// @Summary Resolve role by name.
+// @Description This endpoint resolves a role with the permissions it grants.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Produce json
+// @Success 200 {object} response.Base{data=rbac.RoleResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name} [get]
+func (h *RBACHandler) ResolveRoleByName(w http.ResponseWriter, r *http.Request) {
+	role, err := h.RBACService.ResolveRoleByName(chi.URLParam(r, "name"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, role)
+}
+
+// UpdateRole updates a role.
+// @Summary Update a role.
+// @Description This endpoint updates the description of a role and replaces the permissions it grants.
+// @Description A role can't be renamed. Users get the new permissions with their next access token.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Param role body rbac.RoleRequestFormat true "The role to be updated."
+// @Produce json
+// @Success 200 {object} response.Base{data=rbac.RoleResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name} [put]
+func (h *RBACHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var requestFormat rbac.RoleRequestFormat
+	err := h.decodeRequestFormat(r, &requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	role, err := h.RBACService.UpdateRole(chi.URLParam(r, "name"), requestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, role)
+}
+
+// DeleteRole deletes a role.
+// @Summary Delete a role.
+// @Description This endpoint deletes a role and takes it away from its users.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Success 204
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name} [delete]
+func (h *RBACHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	err := h.RBACService.DeleteRole(chi.URLParam(r, "name"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ResolveRoleAssignments lists the users a role is assigned to.
+// @Summary List the users of a role.
+// @Description This endpoint lists the users a role is assigned to.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Produce json
+// @Success 200 {object} response.Base{data=[]rbac.RoleAssignmentResponseFormat}
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name}/users [get]
+func (h *RBACHandler) ResolveRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	assignments, err := h.RBACService.ResolveRoleAssignments(chi.URLParam(r, "name"))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, assignments)
+}
+
+// AssignRole assigns a role to a user.
+// @Summary Assign a role to a user.
+// @Description This endpoint assigns a role to a user. Assigning a role the user already has does nothing.
+// @Description The user gets the permissions of the role with their next access token.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Param userId path string true "The user ID."
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name}/users/{userId} [put]
+func (h *RBACHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	userID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.RBACService.AssignRole(chi.URLParam(r, "name"), userID, claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// UnassignRole takes a role away from a user.
+// @Summary Take a role away from a user.
+// @Description This endpoint takes a role away from a user. The user keeps the permissions of the role
+// @Description until their current access token expires.
+// @Tags rbac/roles
+// @Security EVMOauthToken
+// @Param name path string true "The role name."
+// @Param userId path string true "The user ID."
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/roles/{name}/users/{userId} [delete]
+func (h *RBACHandler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.RBACService.UnassignRole(chi.URLParam(r, "name"), userID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *RBACHandler) decodeRequestFormat(r *http.Request, requestFormat interface{}) (err error) {
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(requestFormat)
+	if err != nil {
+		return failure.BadRequest(err)
+	}
+
+	err = shared.GetValidator().Struct(requestFormat)
+	if err != nil {
+		return failure.BadRequest(err)
+	}
+
+	return
+}
@@ -6,21 +6,25 @@ import (
 
 	"github.com/evermos/boilerplate-go/internal/domain/user"
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/authctx"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
+	"github.com/gofrs/uuid"
 )
 
 type UserHandler struct {
-	UserService    user.UserService
-	AuthMiddleware *middleware.Authentication
+	UserService       user.UserService
+	DataExportService user.DataExportService
+	AuthMiddleware    *middleware.Authentication
 }
 
-func ProvideUserHandler(userService user.UserService, authMiddleware *middleware.Authentication) UserHandler {
+func ProvideUserHandler(userService user.UserService, dataExportService user.DataExportService, authMiddleware *middleware.Authentication) UserHandler {
 	return UserHandler{
-		UserService:    userService,
-		AuthMiddleware: authMiddleware,
+		UserService:       userService,
+		DataExportService: dataExportService,
+		AuthMiddleware:    authMiddleware,
 	}
 }
 
@@ -29,6 +33,28 @@ func (h *UserHandler) Router(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Post("/register", h.RegisterUser)
 			r.Post("/login", h.LoginUser)
+			r.Post("/token/refresh", h.RefreshToken)
+			r.Post("/verify-email", h.VerifyEmail)
+			r.Post("/password/forgot", h.ForgotPassword)
+			r.Post("/password/reset", h.ResetPassword)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithUnverifiedJWT)
+			r.Post("/logout", h.Logout)
+			r.Post("/logout/all", h.LogoutAll)
+			r.Post("/verify-email/resend", h.ResendEmailVerification)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Get("/me", h.ResolveProfile)
+			r.Patch("/me", h.UpdateProfile)
+			r.Delete("/me", h.DeleteAccount)
+			r.Put("/me/password", h.ChangePassword)
+			r.Get("/me/export", h.RequestDataExport)
+			r.Get("/me/export/{id}", h.ResolveDataExport)
+			r.Get("/me/export/{id}/download", h.DownloadDataExport)
 		})
 	})
 
@@ -109,6 +135,522 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	response.WithJSON(w, http.StatusOK, userLogin)
 }
 
+// RefreshToken exchanges a refresh token for a new token pair.
+// @Summary Refresh an access token.
+// @Description This endpoint rotates the given refresh token and returns a new access and refresh token.
+// @Description Replaying a refresh token that has already been used revokes all tokens issued from the same login.
+// @Tags user
+// @Param token body user.RefreshTokenRequestFormat true "The refresh token."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.TokenResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/token/refresh [post]
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	var refreshTokenRequestFormat user.RefreshTokenRequestFormat
+	err := decoder.Decode(&refreshTokenRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(refreshTokenRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	tokens, err := h.UserService.RefreshToken(refreshTokenRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, tokens.ToResponseFormat())
+}
+
+// Logout revokes the caller's current access token.
+// @Summary Logout the current session.
+// @Description This endpoint revokes the access token used to call it. When a refresh token is given,
+// @Description the refresh tokens issued together with it are revoked as well.
+// @Tags user
+// @Security EVMOauthToken
+// @Param logout body user.LogoutRequestFormat false "The refresh token of the session."
+// @Produce json
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/logout [post]
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	var logoutRequestFormat user.LogoutRequestFormat
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&logoutRequestFormat)
+		if err != nil {
+			response.WithError(w, failure.BadRequest(err))
+			return
+		}
+	}
+
+	err := h.UserService.Logout(claims, logoutRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// LogoutAll revokes every session of the caller.
+// @Summary Logout all sessions.
+// @Description This endpoint revokes all access and refresh tokens issued to the caller.
+// @Tags user
+// @Security EVMOauthToken
+// @Produce json
+// @Success 204
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/logout/all [post]
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	err := h.UserService.LogoutAll(claims)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// VerifyEmail verifies the email address of a user.
+// @Summary Verify an email address.
+// @Description This endpoint verifies the email address the given token was sent to. A token can be used only once.
+// @Description Tokens issued before the verification stay limited until they are refreshed.
+// @Tags user
+// @Param token body user.VerifyEmailRequestFormat true "The verification token from the email."
+// @Produce json
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/verify-email [post]
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	var verifyEmailRequestFormat user.VerifyEmailRequestFormat
+	err := decoder.Decode(&verifyEmailRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(verifyEmailRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.UserService.VerifyEmail(verifyEmailRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ResendEmailVerification sends the verification email to the caller again.
+// @Summary Resend the verification email.
+// @Description This endpoint sends a new verification email to the caller, whose address must not be verified yet.
+// @Description Verification emails to the same user are throttled.
+// @Tags user
+// @Security EVMOauthToken
+// @Produce json
+// @Success 204
+// @Failure 401 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 429 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/verify-email/resend [post]
+func (h *UserHandler) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	err := h.UserService.ResendEmailVerification(claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ForgotPassword sends a password reset email.
+// @Summary Request a password reset.
+// @Description This endpoint emails a password reset link to the user with the given email address.
+// @Description It responds the same whether or not there is such a user, and reset emails to the same user are throttled.
+// @Tags user
+// @Param email body user.ForgotPasswordRequestFormat true "The email address of the account."
+// @Produce json
+// @Success 200 {object} response.Base
+// @Failure 400 {object} response.Base
+// @Router /v1/users/password/forgot [post]
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	var forgotPasswordRequestFormat user.ForgotPasswordRequestFormat
+	err := decoder.Decode(&forgotPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(forgotPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.UserService.ForgotPassword(forgotPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithMessage(w, http.StatusOK, "If an account with that email address exists, a password reset link has been sent to it.")
+}
+
+// ResetPassword sets a new password with a reset token.
+// @Summary Reset a password.
+// @Description This endpoint sets a new password with the token from a password reset email. A token can be used only once.
+// @Description All access and refresh tokens issued to the user so far are revoked.
+// @Tags user
+// @Param reset body user.ResetPasswordRequestFormat true "The reset token and the new password."
+// @Produce json
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/password/reset [post]
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	var resetPasswordRequestFormat user.ResetPasswordRequestFormat
+	err := decoder.Decode(&resetPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(resetPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.UserService.ResetPassword(resetPasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ResolveProfile resolves the caller's profile.
+// @Summary Get the profile.
+// @Description This endpoint returns the profile of the caller.
+// @Tags user
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=user.UserResponseFormat}
+// @Failure 401 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me [get]
+func (h *UserHandler) ResolveProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	profile, err := h.UserService.ResolveByID(claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, profile)
+}
+
+// UpdateProfile updates the caller's profile.
+// @Summary Update the profile.
+// @Description This endpoint updates the given fields of the caller's profile; omitted fields are left unchanged.
+// @Description Changing the email address requires the current password, marks the address as unverified and
+// @Description sends a verification email to the new address. Changing the username or email address revokes
+// @Description every token of the caller, as tokens carry both, and returns a new token pair for this session.
+// @Tags user
+// @Security EVMOauthToken
+// @Param user body user.UserRequestFormat true "The fields to update."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.UpdateProfileResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me [patch]
+func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var userRequestFormat user.UserRequestFormat
+	err := decoder.Decode(&userRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(userRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	profile, tokens, err := h.UserService.UpdateProfile(claims, userRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	resp := user.UpdateProfileResponseFormat{UserResponseFormat: profile.ToResponseFormat()}
+	if tokens != nil {
+		token := tokens.ToResponseFormat()
+		resp.Token = &token
+	}
+
+	response.WithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteAccount deletes the caller's account.
+// @Summary Delete the account.
+// @Description This endpoint deletes the caller's account after confirming their password, and revokes all tokens issued to them.
+// @Description The account can no longer be logged in to, and its personal data is anonymized after a retention period.
+// @Tags user
+// @Security EVMOauthToken
+// @Param account body user.DeleteAccountRequestFormat true "The password of the caller."
+// @Produce json
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me [delete]
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var deleteAccountRequestFormat user.DeleteAccountRequestFormat
+	err := decoder.Decode(&deleteAccountRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(deleteAccountRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.UserService.DeleteAccount(claims, deleteAccountRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ChangePassword changes the caller's password.
+// @Summary Change the password.
+// @Description This endpoint changes the caller's password. The current password is required.
+// @Description When signOutOtherSessions is set, all other sessions are signed out and a new token pair is returned
+// @Description for the current session; otherwise the response has no content.
+// @Tags user
+// @Security EVMOauthToken
+// @Param password body user.ChangePasswordRequestFormat true "The current and the new password."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.TokenResponseFormat}
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me/password [put]
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var changePasswordRequestFormat user.ChangePasswordRequestFormat
+	err := decoder.Decode(&changePasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(changePasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	tokens, err := h.UserService.ChangePassword(claims, changePasswordRequestFormat)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	if !changePasswordRequestFormat.SignOutOtherSessions {
+		response.NoContent(w)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, tokens.ToResponseFormat())
+}
+
+// RequestDataExport starts an export of the caller's personal data.
+// @Summary Export personal data.
+// @Description This endpoint starts generating an archive of everything the service holds about the caller:
+// @Description the profile, sessions, login history, OAuth tokens and clients, and audit entries.
+// @Description The archive is generated in the background; poll the export until it is ready, then download it.
+// @Description While an export in the same format is being generated, that export is returned instead.
+// @Tags user
+// @Security EVMOauthToken
+// @Param format query string false "The archive format, json (default) or zip."
+// @Produce json
+// @Success 202 {object} response.Base{data=user.DataExportResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me/export [get]
+func (h *UserHandler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	format := user.DataExportFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = user.DataExportFormatJSON
+	}
+
+	export, err := h.DataExportService.RequestDataExport(claims.UserID, format)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+export.ID.String())
+	response.WithJSON(w, http.StatusAccepted, export.ToResponseFormat())
+}
+
+// ResolveDataExport resolves the status of an export of the caller's personal data.
+// @Summary Get the status of a personal data export.
+// @Description This endpoint returns the status of an export started by the caller.
+// @Tags user
+// @Security EVMOauthToken
+// @Param id path string true "The export identifier."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.DataExportResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me/export/{id} [get]
+func (h *UserHandler) ResolveDataExport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	idString := chi.URLParam(r, "id")
+	id, err := uuid.FromString(idString)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	export, err := h.DataExportService.ResolveDataExport(claims.UserID, id)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, export.ToResponseFormat())
+}
+
+// DownloadDataExport downloads the archive of an export of the caller's personal data.
+// @Summary Download a personal data export.
+// @Description This endpoint downloads the archive of a finished export started by the caller.
+// @Tags user
+// @Security EVMOauthToken
+// @Param id path string true "The export identifier."
+// @Produce application/json,application/zip
+// @Success 200 {file} file
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/users/me/export/{id}/download [get]
+func (h *UserHandler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authctx.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	idString := chi.URLParam(r, "id")
+	id, err := uuid.FromString(idString)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	archive, err := h.DataExportService.ResolveDataExportArchive(claims.UserID, id)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithAttachment(w, archive.ContentType, archive.Filename, archive.Content)
+}
+
 // ValidateAuth validates the user's authentication token.
 // @Summary Validate user authentication token.
 // @Description This endpoint validates the user's authentication token and returns user claims.
@@ -120,7 +662,7 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} response.Base
 // @Router /v1/validate [get]
 func (h *UserHandler) ValidateAuth(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value("claims").(*shared.Claims)
+	claims, ok := authctx.ClaimsFromContext(r.Context())
 	if !ok {
 		response.WithError(w, failure.Unauthorized("Token not authorized"))
 		return
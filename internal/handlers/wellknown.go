@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+)
+
+// OpenIDConfiguration is the OpenID Connect discovery document.
+type OpenIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	RevocationEndpoint               string   `json:"revocation_endpoint"`
+	DeviceAuthorizationEndpoint      string   `json:"device_authorization_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// WellKnownHandler serves the public /.well-known documents of this service.
+type WellKnownHandler struct {
+	JWTService *shared.JWTService
+	Token      *oauth.Token
+	Config     *configs.Config
+}
+
+// ProvideWellKnownHandler is the provider for this handler.
+func ProvideWellKnownHandler(jwtService *shared.JWTService, token *oauth.Token, config *configs.Config) WellKnownHandler {
+	if !jwtService.SupportsOpenIDConnect() {
+		log.Warn().
+			Str("algorithm", jwtService.SigningAlgorithm()).
+			Str("issuer", jwtService.Issuer).
+			Msg("OpenID Connect discovery disabled, it requires an asymmetric JWT algorithm and an https issuer.")
+	}
+
+	return WellKnownHandler{
+		JWTService: jwtService,
+		Token:      token,
+		Config:     config,
+	}
+}
+
+// Router sets up the router for this handler.
+func (h *WellKnownHandler) Router(r chi.Router) {
+	r.Route("/.well-known", func(r chi.Router) {
+		r.Get("/jwks.json", h.JWKS)
+		r.Get("/openid-configuration", h.OpenIDConfiguration)
+	})
+}
+
+// JWKS publishes the public keys JWTs are verified with.
+// @Summary JSON Web Key Set
+// @Description This endpoint returns the public keys that verify the JWTs issued by this service.
+// @Tags well-known
+// @Produce json
+// @Success 200 {object} shared.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *WellKnownHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.WithRawJSON(w, http.StatusOK, h.JWTService.JWKS())
+}
+
+// OpenIDConfiguration publishes the OpenID Connect discovery document.
+// @Summary OpenID Connect discovery
+// @Description This endpoint describes the OpenID Connect provider. It is only served when tokens are signed
+// @Description with an asymmetric App.JWT.Algorithm, and App.JWT.Issuer is set to the https URL of this
+// @Description service, as OpenID Connect clients can't verify the tokens otherwise.
+// @Tags well-known
+// @Produce json
+// @Success 200 {object} handlers.OpenIDConfiguration
+// @Failure 404 {object} response.Base
+// @Router /.well-known/openid-configuration [get]
+func (h *WellKnownHandler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	if !h.JWTService.SupportsOpenIDConnect() {
+		response.WithMessage(w, http.StatusNotFound, "OpenID Connect is not configured")
+		return
+	}
+
+	baseURL := strings.TrimRight(h.Config.App.URL, "/")
+
+	grantTypes := []string{}
+	for _, grantType := range h.Token.GrantTypes() {
+		grantTypes = append(grantTypes, string(grantType))
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.WithRawJSON(w, http.StatusOK, OpenIDConfiguration{
+		Issuer:                           h.JWTService.Issuer,
+		AuthorizationEndpoint:            baseURL + "/oauth/authorize",
+		TokenEndpoint:                    baseURL + "/oauth/token",
+		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post"},
+		IntrospectionEndpoint:            baseURL + "/oauth/introspect",
+		RevocationEndpoint:               baseURL + "/oauth/revoke",
+		DeviceAuthorizationEndpoint:      baseURL + "/oauth/device_authorization",
+		UserinfoEndpoint:                 baseURL + "/oauth/userinfo",
+		JWKSURI:                          baseURL + "/.well-known/jwks.json",
+		ResponseTypesSupported:           []string{oauth.ResponseTypeCode},
+		GrantTypesSupported:              grantTypes,
+		CodeChallengeMethodsSupported:    []string{oauth.CodeChallengeMethodS256},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{h.JWTService.SigningAlgorithm()},
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "preferred_username", "email",
+		},
+	})
+}
@@ -4,8 +4,13 @@ package main
 //go:generate go run github.com/google/wire/cmd/wire
 
 import (
+	"os"
+
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/internal/domain/oauthclient"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
 )
 
 var config *configs.Config
@@ -23,9 +28,18 @@ func main() {
 	// Set desired log level
 	logger.SetLogLevel(config)
 
+	// Run an admin command instead of the server, if one is given
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Wire everything up
 	http := InitializeService()
 
+	// Anonymize deleted accounts in the background
+	InitializeAccountPurger().Start()
+
 	// consumers := InitializeEvent()
 
 	// Start consumers
@@ -34,3 +48,51 @@ func main() {
 	// Run server
 	http.SetupAndServe()
 }
+
+// runCommand runs a one-off admin command.
+func runCommand(command string, args []string) {
+	switch command {
+	case "rotate-signing-key":
+		// Rotates the JWT signing key right away, e.g. when it may have been
+		// compromised. Running replicas pick up the new key on their next
+		// key ring refresh.
+		if err := InitializeKeyRing().Rotate(); err != nil {
+			log.Fatal().Err(err).Msg("Failed rotating JWT signing key")
+		}
+		log.Info().Msg("JWT signing key rotated.")
+	case "purge-deleted-users":
+		// Anonymizes the deleted accounts whose retention period is over,
+		// for deployments that run the purge as a scheduled job.
+		anonymized, err := InitializeAccountPurger().Purge()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed purging deleted users")
+		}
+		log.Info().Int64("count", anonymized).Msg("Deleted users purged.")
+	case "bootstrap-admin":
+		// Assigns the admin role, which grants rbac:manage, to the user with
+		// the given ID, so that the first admin can manage roles through the
+		// API. The user gets the role with their next access token.
+		if len(args) != 1 {
+			log.Fatal().Msg("Usage: bootstrap-admin <user-id>")
+		}
+		userID, err := uuid.FromString(args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid user ID")
+		}
+		if err := InitializeRBACService().BootstrapAdmin(userID); err != nil {
+			log.Fatal().Err(err).Msg("Failed bootstrapping admin")
+		}
+		log.Info().Str("userId", userID.String()).Msg("Admin role assigned.")
+	case "stretch-client-secrets":
+		// Salts and stretches the SHA-256 client secret hashes left by the
+		// client management migration. It must run after that migration,
+		// as clients with such a hash can't authenticate until then.
+		stretched, err := oauthclient.StretchLegacySecrets(InitializeClientRepository())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed stretching client secrets")
+		}
+		log.Info().Int("count", stretched).Msg("Client secrets stretched.")
+	default:
+		log.Fatal().Str("command", command).Msg("Unknown command")
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `user_refresh_token` (
+  `id` CHAR(36) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `family_id` CHAR(36) NOT NULL,
+  `token_hash` CHAR(64) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+  `replaced_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE `idx_user_refresh_token_1` (`token_hash`),
+  INDEX `idx_user_refresh_token_2` (`user_id`),
+  INDEX `idx_user_refresh_token_3` (`family_id`),
+  INDEX `idx_user_refresh_token_4` (`expires_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `jwt_denylist` (
+  `jti` VARCHAR(64) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`jti`),
+  INDEX `idx_jwt_denylist_1` (`user_id`),
+  INDEX `idx_jwt_denylist_2` (`expires_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `jwt_user_revocation` (
+  `user_id` VARCHAR(55) NOT NULL,
+  `revoked_before` TIMESTAMP NOT NULL,
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
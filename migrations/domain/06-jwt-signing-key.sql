@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `jwt_signing_key` (
+  `kid` VARCHAR(64) NOT NULL,
+  `algorithm` VARCHAR(10) NOT NULL,
+  `private_key` TEXT NULL,
+  `public_key` TEXT NOT NULL,
+  `status` ENUM('pending', 'active', 'verify', 'retired') NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `activated_at` TIMESTAMP NULL DEFAULT NULL,
+  `deactivated_at` TIMESTAMP NULL DEFAULT NULL,
+  `retired_at` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`kid`),
+  INDEX `idx_jwt_signing_key_1` (`status`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
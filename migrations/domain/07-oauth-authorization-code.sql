@@ -0,0 +1,24 @@
+-- User IDs are UUIDs, which do not fit into the original column.
+ALTER TABLE `oauth_access_tokens`
+  MODIFY `user_id` VARCHAR(55) NULL;
+
+CREATE TABLE IF NOT EXISTS `oauth_authorization_codes` (
+  `code` CHAR(64) NOT NULL,
+  `client_id` VARCHAR(32) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `redirect_uri` VARCHAR(1000) NOT NULL,
+  `scope` VARCHAR(2000) NULL,
+  `nonce` VARCHAR(255) NULL,
+  `code_challenge` VARCHAR(128) NOT NULL,
+  `code_challenge_method` VARCHAR(10) NOT NULL,
+  `auth_time` TIMESTAMP NULL DEFAULT NULL,
+  `expires` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` TIMESTAMP NULL DEFAULT NULL,
+  `access_token` VARCHAR(40) NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`code`),
+  INDEX `idx_oauth_authorization_codes_1` (`client_id`),
+  INDEX `idx_oauth_authorization_codes_2` (`user_id`),
+  INDEX `idx_oauth_authorization_codes_3` (`expires`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `oauth_refresh_tokens` (
+  `refresh_token` CHAR(64) NOT NULL,
+  `family_id` CHAR(36) NOT NULL,
+  `client_id` VARCHAR(32) NOT NULL,
+  `user_id` VARCHAR(55) NULL,
+  `scope` VARCHAR(2000) NULL,
+  `expires` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+  `replaced_by` CHAR(64) NULL,
+  PRIMARY KEY (`refresh_token`),
+  INDEX `idx_oauth_refresh_tokens_1` (`family_id`),
+  INDEX `idx_oauth_refresh_tokens_2` (`client_id`),
+  INDEX `idx_oauth_refresh_tokens_3` (`user_id`),
+  INDEX `idx_oauth_refresh_tokens_4` (`expires`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
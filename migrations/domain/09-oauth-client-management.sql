@@ -0,0 +1,14 @@
+ALTER TABLE `oauth_clients`
+  MODIFY `client_secret` VARCHAR(100) NOT NULL,
+  ADD COLUMN `name` VARCHAR(255) NULL AFTER `client_id`,
+  ADD COLUMN `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD COLUMN `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  ADD COLUMN `disabled_at` TIMESTAMP NULL DEFAULT NULL;
+
+-- Client secrets are stored as bcrypt hashes, which can't be computed here. The
+-- existing secrets are hashed with SHA-256 for now, and must be stretched with
+-- the stretch-client-secrets command before their clients can authenticate.
+-- Public clients keep an empty secret.
+UPDATE `oauth_clients`
+SET `client_secret` = CONCAT('sha256$', SHA2(`client_secret`, 256))
+WHERE `client_secret` <> '' AND `client_secret` NOT LIKE 'sha256$%';
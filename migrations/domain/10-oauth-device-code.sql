@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `oauth_device_codes` (
+  `device_code` CHAR(64) NOT NULL,
+  `user_code` VARCHAR(16) NOT NULL,
+  `client_id` VARCHAR(32) NOT NULL,
+  `scope` VARCHAR(2000) NULL,
+  `status` ENUM('pending', 'approved', 'denied') NOT NULL DEFAULT 'pending',
+  `user_id` VARCHAR(55) NULL,
+  `interval_seconds` INT NOT NULL,
+  `expires` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_polled_at` TIMESTAMP NULL DEFAULT NULL,
+  `decided_at` TIMESTAMP NULL DEFAULT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`device_code`),
+  UNIQUE `idx_oauth_device_codes_1` (`user_code`),
+  INDEX `idx_oauth_device_codes_2` (`client_id`),
+  INDEX `idx_oauth_device_codes_3` (`expires`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
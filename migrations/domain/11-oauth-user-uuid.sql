@@ -0,0 +1,15 @@
+-- Users are identified by the UUIDs of the user table, see 03-user.sql.
+ALTER TABLE `oauth_clients`
+  MODIFY `user_id` VARCHAR(55) NULL;
+
+-- Tokens issued to the numeric IDs of the former user table can't be resolved
+-- to a user anymore.
+DELETE FROM `oauth_access_tokens`
+WHERE `user_id` NOT REGEXP '^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$';
+
+DELETE FROM `oauth_refresh_tokens`
+WHERE `user_id` NOT REGEXP '^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$';
+
+UPDATE `oauth_clients`
+SET `user_id` = NULL
+WHERE `user_id` NOT REGEXP '^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$';
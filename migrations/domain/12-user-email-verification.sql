@@ -0,0 +1,22 @@
+ALTER TABLE `user`
+  ADD `email_verified_at` TIMESTAMP NULL DEFAULT NULL AFTER `email`;
+
+-- Users registered before email verification was introduced are treated as
+-- verified, so that App.User.EmailVerification.RestrictUnverified doesn't lock
+-- them out. Only users registering from now on have to verify their address.
+UPDATE `user`
+SET `email_verified_at` = CURRENT_TIMESTAMP
+WHERE `email_verified_at` IS NULL;
+
+CREATE TABLE IF NOT EXISTS `user_email_verification` (
+  `id` CHAR(36) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `email` VARCHAR(255) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_email_verification_1` (`user_id`, `created_at`),
+  INDEX `idx_user_email_verification_2` (`expires_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
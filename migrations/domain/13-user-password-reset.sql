@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `user_password_reset` (
+  `id` CHAR(36) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `token_hash` CHAR(64) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_password_reset_1` (`token_hash`),
+  INDEX `idx_user_password_reset_2` (`user_id`, `created_at`),
+  INDEX `idx_user_password_reset_3` (`expires_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
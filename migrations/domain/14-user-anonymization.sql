@@ -0,0 +1,3 @@
+ALTER TABLE `user`
+  ADD `anonymized_at` TIMESTAMP NULL DEFAULT NULL AFTER `deleted_by`,
+  ADD INDEX `idx_user_1` (`deleted_at`, `anonymized_at`);
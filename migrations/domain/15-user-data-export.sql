@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `user_data_export` (
+  `id` CHAR(36) NOT NULL,
+  `user_id` VARCHAR(55) NOT NULL,
+  `format` VARCHAR(10) NOT NULL,
+  `status` VARCHAR(20) NOT NULL,
+  `filename` VARCHAR(255) NULL DEFAULT NULL,
+  `content_type` VARCHAR(100) NULL DEFAULT NULL,
+  `archive` LONGBLOB NULL DEFAULT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `completed_at` TIMESTAMP NULL DEFAULT NULL,
+  `expires_at` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_data_export_1` (`user_id`, `created_at`),
+  INDEX `idx_user_data_export_2` (`expires_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS `permission` (
+  `name` VARCHAR(100) NOT NULL,
+  `description` VARCHAR(255) NULL DEFAULT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `role` (
+  `name` VARCHAR(100) NOT NULL,
+  `description` VARCHAR(255) NULL DEFAULT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `role_permission` (
+  `role_name` VARCHAR(100) NOT NULL,
+  `permission_name` VARCHAR(100) NOT NULL,
+  PRIMARY KEY (`role_name`, `permission_name`),
+  INDEX `idx_role_permission_1` (`permission_name`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `user_role` (
+  `user_id` VARCHAR(55) NOT NULL,
+  `role_name` VARCHAR(100) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` VARCHAR(55) NOT NULL,
+  PRIMARY KEY (`user_id`, `role_name`),
+  INDEX `idx_user_role_1` (`role_name`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
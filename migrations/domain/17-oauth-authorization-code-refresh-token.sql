@@ -0,0 +1,4 @@
+-- The refresh token family issued for a code is revoked when the code is
+-- replayed, together with its access token.
+ALTER TABLE `oauth_authorization_codes`
+  ADD `refresh_token_family_id` CHAR(36) NULL AFTER `access_token`;
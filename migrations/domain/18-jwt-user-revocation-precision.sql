@@ -0,0 +1,4 @@
+-- User-wide revocations are compared with the issue time of tokens in
+-- milliseconds.
+ALTER TABLE `jwt_user_revocation`
+  MODIFY `revoked_before` TIMESTAMP(3) NOT NULL;
@@ -0,0 +1,166 @@
+// Package authctx carries the identity a request was authenticated with in
+// its context. The context keys are unexported, so the identity can only be
+// set by the authentication middleware and read through the typed accessors.
+package authctx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/gofrs/uuid"
+)
+
+type contextKey int
+
+const (
+	claimsKey contextKey = iota
+	accessTokenKey
+)
+
+// WithClaims returns a copy of ctx carrying the claims of the JWT a request
+// was authenticated with.
+func WithClaims(ctx context.Context, claims *shared.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims of the JWT the request was
+// authenticated with, if any.
+func ClaimsFromContext(ctx context.Context) (*shared.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*shared.Claims)
+	return claims, ok && claims != nil
+}
+
+// WithAccessToken returns a copy of ctx carrying the opaque OAuth access token
+// a request was authenticated with.
+func WithAccessToken(ctx context.Context, accessToken oauth.OauthAccessToken) context.Context {
+	return context.WithValue(ctx, accessTokenKey, accessToken)
+}
+
+// AccessTokenFromContext returns the opaque OAuth access token the request
+// was authenticated with, if any.
+func AccessTokenFromContext(ctx context.Context) (oauth.OauthAccessToken, bool) {
+	accessToken, ok := ctx.Value(accessTokenKey).(oauth.OauthAccessToken)
+	return accessToken, ok
+}
+
+// ClientIDFromContext returns the ID of the client the access token of the
+// request was issued to.
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	accessToken, ok := AccessTokenFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return accessToken.ClientID, true
+}
+
+// UserIDFromContext returns the ID of the user the access token of the
+// request was issued on behalf of. It is not set for tokens that clients
+// obtained on their own behalf.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	accessToken, ok := AccessTokenFromContext(ctx)
+	if !ok || !accessToken.UserID.Valid {
+		return "", false
+	}
+	return accessToken.UserID.String, true
+}
+
+// ScopesFromContext returns the scopes the access token of the request was
+// granted.
+func ScopesFromContext(ctx context.Context) []string {
+	accessToken, ok := AccessTokenFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return accessToken.Scopes()
+}
+
+// PrincipalType tells whether a principal is a user or a machine.
+type PrincipalType string
+
+const (
+	// PrincipalUser is a user, authenticated by a JWT or by an OAuth access
+	// token issued on their behalf, such as through the password grant.
+	PrincipalUser PrincipalType = "user"
+	// PrincipalMachine is an OAuth client acting on its own behalf, through
+	// the client credentials grant.
+	PrincipalMachine PrincipalType = "machine"
+)
+
+// Principal is the party a request was authenticated as, whichever way it
+// was authenticated.
+type Principal struct {
+	Type PrincipalType
+	// UserID is the ID of the user, and uuid.Nil for machines.
+	UserID uuid.UUID
+	// Username is only known for users authenticated by a JWT.
+	Username string
+	// ClientID is the client an OAuth access token was issued to. It is empty
+	// for JWTs.
+	ClientID string
+	Scopes   []string
+	// Roles and Permissions are only known for users authenticated by a JWT.
+	Roles       []string
+	Permissions []string
+}
+
+// IsUser checks whether the principal is a user.
+func (p Principal) IsUser() bool {
+	return p.Type == PrincipalUser
+}
+
+// IsMachine checks whether the principal is an OAuth client acting on its own
+// behalf.
+func (p Principal) IsMachine() bool {
+	return p.Type == PrincipalMachine
+}
+
+// Subject identifies the principal: the ID of a user, or the client ID of a
+// machine.
+func (p Principal) Subject() string {
+	if p.IsMachine() {
+		return p.ClientID
+	}
+	return p.UserID.String()
+}
+
+// PrincipalFromContext returns the principal the request was authenticated
+// as. JWT claims take precedence over an OAuth access token. It returns false
+// when the request was not authenticated, or when the access token refers to
+// a user by an invalid ID.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return Principal{
+			Type:        PrincipalUser,
+			UserID:      claims.UserID,
+			Username:    claims.Username,
+			Scopes:      strings.Fields(claims.Scope),
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+		}, true
+	}
+
+	accessToken, ok := AccessTokenFromContext(ctx)
+	if !ok {
+		return Principal{}, false
+	}
+
+	principal := Principal{
+		Type:     PrincipalMachine,
+		ClientID: accessToken.ClientID,
+		Scopes:   accessToken.Scopes(),
+	}
+
+	if accessToken.VerifyUserLoggedIn() {
+		userID, err := uuid.FromString(accessToken.UserID.String)
+		if err != nil {
+			return Principal{}, false
+		}
+
+		principal.Type = PrincipalUser
+		principal.UserID = userID
+	}
+
+	return principal, true
+}
@@ -0,0 +1,71 @@
+package authctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/authctx"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	userID, _ := uuid.NewV4()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, ok := authctx.PrincipalFromContext(context.Background())
+		assert.False(t, ok)
+	})
+
+	t.Run("JWT user", func(t *testing.T) {
+		ctx := authctx.WithClaims(context.Background(), &shared.Claims{
+			UserID:      userID,
+			Username:    "john",
+			Permissions: []string{"foo:write"},
+		})
+
+		principal, ok := authctx.PrincipalFromContext(ctx)
+		assert.True(t, ok)
+		assert.True(t, principal.IsUser())
+		assert.Equal(t, userID, principal.UserID)
+		assert.Equal(t, "john", principal.Username)
+		assert.Equal(t, []string{"foo:write"}, principal.Permissions)
+	})
+
+	t.Run("OAuth user", func(t *testing.T) {
+		ctx := authctx.WithAccessToken(context.Background(), oauth.OauthAccessToken{
+			ClientID: "client_web",
+			UserID:   null.StringFrom(userID.String()),
+			Scope:    null.StringFrom("foo:read foo:write"),
+		})
+
+		principal, ok := authctx.PrincipalFromContext(ctx)
+		assert.True(t, ok)
+		assert.True(t, principal.IsUser())
+		assert.Equal(t, userID, principal.UserID)
+		assert.Equal(t, "client_web", principal.ClientID)
+		assert.Equal(t, []string{"foo:read", "foo:write"}, principal.Scopes)
+		assert.Equal(t, userID.String(), principal.Subject())
+
+		clientID, _ := authctx.ClientIDFromContext(ctx)
+		tokenUserID, _ := authctx.UserIDFromContext(ctx)
+		assert.Equal(t, "client_web", clientID)
+		assert.Equal(t, userID.String(), tokenUserID)
+	})
+
+	t.Run("Machine", func(t *testing.T) {
+		ctx := authctx.WithAccessToken(context.Background(), oauth.OauthAccessToken{ClientID: "client_batch"})
+
+		principal, ok := authctx.PrincipalFromContext(ctx)
+		assert.True(t, ok)
+		assert.True(t, principal.IsMachine())
+		assert.Equal(t, uuid.Nil, principal.UserID)
+		assert.Equal(t, "client_batch", principal.Subject())
+
+		_, ok = authctx.UserIDFromContext(ctx)
+		assert.False(t, ok)
+	})
+}
@@ -78,6 +78,15 @@ func Conflict(operationName string, entityName string, message string) error {
 	}
 }
 
+// TooManyRequests returns a new Failure with code for requests that are
+// throttled.
+func TooManyRequests(msg string) error {
+	return &Failure{
+		Code:    http.StatusTooManyRequests,
+		Message: msg,
+	}
+}
+
 // GetCode returns the error code of an error interface.
 func GetCode(err error) int {
 	if f, ok := err.(*Failure); ok {
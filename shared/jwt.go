@@ -3,44 +3,360 @@ package shared
 import (
 	"errors"
 	"fmt"
-
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt"
+	"github.com/rs/zerolog/log"
 )
 
+// Claims are the claims carried by the JWTs issued by this service. The
+// embedded StandardClaims carry the token ID (jti) used for revocation.
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Scope       string    `json:"scope,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	Permissions []string  `json:"permissions,omitempty"`
+	Actor       *Actor    `json:"act,omitempty"`
+	// IssuedAtMilli is the issue time in milliseconds. The iat claim only has
+	// a precision of seconds, which can't tell whether a token was issued
+	// right before or right after a user-wide revocation.
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
+	jwt.StandardClaims
+}
+
+// Authorities are the roles of a user and the permissions granted by those
+// roles. They are embedded in the JWTs of the user, so that downstream
+// services can authorize requests without looking them up.
+type Authorities struct {
+	Roles       []string
+	Permissions []string
+}
+
+// Actor identifies the party that uses a delegated token on behalf of its
+// subject, see RFC 8693, section 4.1. When a delegated token is exchanged
+// again, the previous actor is nested in the new one.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
 	jwt.StandardClaims
 }
 
+// DefaultUnverifiedScope is the scope the JWTs of users whose email address is
+// not verified are limited to, when App.User.EmailVerification.UnverifiedScope
+// is not set.
+const DefaultUnverifiedScope = "email:verify"
+
+// UnverifiedScope returns the scope the JWTs of users whose email address is
+// not verified are limited to.
+func UnverifiedScope(config *configs.Config) string {
+	if config.App.User.EmailVerification.UnverifiedScope == "" {
+		return DefaultUnverifiedScope
+	}
+
+	return config.App.User.EmailVerification.UnverifiedScope
+}
+
+// HasScope checks whether the space-delimited scope of the token contains the
+// given scope.
+func (c *Claims) HasScope(scope string) bool {
+	return contains(strings.Fields(c.Scope), scope)
+}
+
+// IssuedBefore checks whether the token was issued at or before t. Tokens
+// without iat_ms are compared by their iat, and are therefore considered
+// issued before any time in the same second.
+func (c *Claims) IssuedBefore(t time.Time) bool {
+	if c.IssuedAtMilli > 0 {
+		return c.IssuedAtMilli <= unixMilli(t)
+	}
+	return c.IssuedAt <= t.Unix()
+}
+
+// HasRole checks whether the user was assigned the given role when the token
+// was issued.
+func (c *Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+// HasPermission checks whether the roles of the user granted the given
+// permission when the token was issued.
+func (c *Claims) HasPermission(permission string) bool {
+	return contains(c.Permissions, permission)
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// DefaultJWTExpiration is used when JWTService.Expiration is not set.
+	DefaultJWTExpiration = time.Hour
+	// DefaultJWTIssuer is used when JWTService.Issuer is not set.
+	DefaultJWTIssuer = "EverShop"
+)
+
+// JWTService signs and validates the JWTs issued by this service.
+//
+// Without a signing key, tokens are signed with HS256 using Secret. With a
+// signing key, tokens are signed asymmetrically and carry the key's kid in
+// their header, so that other services can verify them using the public
+// keys published through JWKS. HS256 tokens are still accepted while
+// AcceptHS256 is set, to allow migrating away from the shared secret.
 type JWTService struct {
-	Secret string
+	Secret      string
+	Issuer      string
+	Expiration  time.Duration
+	AcceptHS256 bool
+	mu          sync.RWMutex
+	signingKey  *SigningKey
+	keys        map[string]*SigningKey
 }
 
-func ProvideJWTService(secret string) *JWTService {
+// NewJWTService creates a JWTService that signs with HS256.
+func NewJWTService(secret string, expiration time.Duration) *JWTService {
 	return &JWTService{
-		Secret: secret,
+		Secret:      secret,
+		Issuer:      DefaultJWTIssuer,
+		Expiration:  expiration,
+		AcceptHS256: true,
+		keys:        make(map[string]*SigningKey),
+	}
+}
+
+// ProvideJWTService is the provider for JWTService. The signing key is
+// loaded from App.JWT.PrivateKeyPath, or generated at startup when no path is
+// configured for an asymmetric algorithm.
+func ProvideJWTService(config *configs.Config) *JWTService {
+	jwtConfig := config.App.JWT
+	j := NewJWTService(config.App.Secret, time.Duration(jwtConfig.AccessTokenExpirySeconds)*time.Second)
+	if jwtConfig.Issuer != "" {
+		j.Issuer = jwtConfig.Issuer
+	}
+
+	algorithm := jwtConfig.Algorithm
+	if algorithm == "" || algorithm == AlgorithmHS256 {
+		return j
+	}
+
+	if !IsAsymmetricAlgorithm(algorithm) {
+		log.Fatal().Str("algorithm", algorithm).Msg("Unsupported JWT signing algorithm")
+	}
+
+	var key *SigningKey
+	var err error
+	if jwtConfig.PrivateKeyPath != "" {
+		key, err = LoadSigningKey(algorithm, jwtConfig.PrivateKeyPath, jwtConfig.KeyID)
+	} else {
+		log.Warn().Str("algorithm", algorithm).Msg("No JWT private key configured, generating an ephemeral signing key.")
+		key, err = GenerateSigningKey(algorithm, jwtConfig.KeyID)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Str("algorithm", algorithm).Msg("Failed loading JWT signing key")
+	}
+
+	j.AcceptHS256 = jwtConfig.AcceptHS256
+	j.UseSigningKey(key)
+	log.Info().Str("algorithm", algorithm).Str("kid", key.ID).Msg("JWT signing key loaded.")
+
+	return j
+}
+
+// UseSigningKey makes key the key new tokens are signed with. The key is also
+// used to verify tokens.
+func (j *JWTService) UseSigningKey(key *SigningKey) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.signingKey = key
+	j.keys[key.ID] = key
+}
+
+// AddVerificationKey adds a key that tokens are verified against.
+func (j *JWTService) AddVerificationKey(key *SigningKey) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.keys[key.ID] = key
+}
+
+// SetKeys replaces the signing key and all verification keys at once. The
+// signing key is always accepted for verification.
+func (j *JWTService) SetKeys(signingKey *SigningKey, verificationKeys []*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verificationKeys)+1)
+	for _, key := range verificationKeys {
+		keys[key.ID] = key
+	}
+	keys[signingKey.ID] = signingKey
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.signingKey = signingKey
+	j.keys = keys
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func (j *JWTService) JWKS() JWKS {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range j.keys {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+
+	sort.Slice(jwks.Keys, func(a, b int) bool {
+		return jwks.Keys[a].KeyID < jwks.Keys[b].KeyID
+	})
+
+	return jwks
+}
+
+// SigningAlgorithm returns the algorithm new tokens are signed with.
+func (j *JWTService) SigningAlgorithm() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.signingKey == nil {
+		return AlgorithmHS256
+	}
+	return j.signingKey.Algorithm
+}
+
+// SupportsOpenIDConnect checks whether the tokens of this service can be
+// verified by OpenID Connect clients: they must be signed asymmetrically, so
+// that the keys can be published through JWKS, and the issuer must be an
+// https URL, see OpenID Connect Discovery 1.0, section 3.
+func (j *JWTService) SupportsOpenIDConnect() bool {
+	if !IsAsymmetricAlgorithm(j.SigningAlgorithm()) {
+		return false
+	}
+
+	issuer, err := url.Parse(j.Issuer)
+	if err != nil {
+		return false
 	}
+	return issuer.Scheme == "https" && issuer.Host != "" && issuer.RawQuery == "" && issuer.Fragment == ""
+}
+
+// GenerateJWT signs a new access token for the user, carrying their roles and
+// permissions, and returns it along with its expiry time.
+func (j *JWTService) GenerateJWT(userID uuid.UUID, username string, email string, authorities Authorities) (string, time.Time, error) {
+	return j.GenerateScopedJWT(userID, username, email, "", authorities)
 }
 
-func (j *JWTService) GenerateJWT(userID uuid.UUID, username string, email string) (string, error) {
+// GenerateScopedJWT signs a new access token for the user that is limited to
+// the space-delimited scope. An empty scope does not limit the token.
+func (j *JWTService) GenerateScopedJWT(userID uuid.UUID, username string, email string, scope string, authorities Authorities) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(j.expiration())
+
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return "", time.Time{}, failure.InternalError(err)
+	}
+
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:      userID,
+		Username:    username,
+		Email:       email,
+		Scope:       scope,
+		Roles:       authorities.Roles,
+		Permissions: authorities.Permissions,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 1).Unix(),
-			Issuer:    "EverShop",
+			Id:        jti.String(),
+			Subject:   userID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+			Issuer:    j.Issuer,
 		},
+		IssuedAtMilli: unixMilli(now),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(j.Secret))
+	tokenString, err := j.sign(claims)
+	if err != nil {
+		return "", time.Time{}, failure.InternalError(err)
+	}
+
+	return tokenString, time.Unix(expiresAt.Unix(), 0), nil
+}
+
+// GenerateDelegatedJWT signs a token that an actor uses on behalf of the
+// subject of claims. The token ID, issuer and issue time are set by the
+// service. The token expires with the service expiration, or earlier when
+// claims.ExpiresAt is set.
+func (j *JWTService) GenerateDelegatedJWT(claims Claims) (string, time.Time, error) {
+	if claims.Actor == nil || claims.Audience == "" {
+		return "", time.Time{}, failure.InternalError(errors.New("delegated token requires an actor and an audience"))
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(j.expiration()).Unix()
+	if claims.ExpiresAt > 0 && claims.ExpiresAt < expiresAt {
+		expiresAt = claims.ExpiresAt
+	}
+
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return "", time.Time{}, failure.InternalError(err)
+	}
+
+	claims.Id = jti.String()
+	claims.Subject = claims.UserID.String()
+	claims.Issuer = j.Issuer
+	claims.IssuedAt = now.Unix()
+	claims.IssuedAtMilli = unixMilli(now)
+	claims.ExpiresAt = expiresAt
+
+	tokenString, err := j.sign(claims)
+	if err != nil {
+		return "", time.Time{}, failure.InternalError(err)
+	}
+
+	return tokenString, time.Unix(expiresAt, 0), nil
+}
+
+// GenerateIDToken signs an OpenID Connect ID token. The issuer, issue and
+// expiry times are set by the service; the subject and audience must be set
+// by the caller.
+func (j *JWTService) GenerateIDToken(claims IDTokenClaims) (string, error) {
+	if claims.Subject == "" || claims.Audience == "" {
+		return "", failure.InternalError(errors.New("ID token requires a subject and an audience"))
+	}
+
+	now := time.Now()
+	claims.Issuer = j.Issuer
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(j.expiration()).Unix()
+
+	tokenString, err := j.sign(claims)
 	if err != nil {
 		return "", failure.InternalError(err)
 	}
@@ -53,12 +369,7 @@ func (j *JWTService) ValidateJWT(tokenString string) (*Claims, error) {
 		return nil, failure.BadRequest(errors.New("token is empty"))
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.verificationKey)
 	if err != nil {
 		return nil, fmt.Errorf("JWT parsing failed: %v", err)
 	}
@@ -69,3 +380,49 @@ func (j *JWTService) ValidateJWT(tokenString string) (*Claims, error) {
 
 	return nil, fmt.Errorf("JWT is not valid or claims are not of the right type")
 }
+
+func (j *JWTService) expiration() time.Duration {
+	if j.Expiration <= 0 {
+		return DefaultJWTExpiration
+	}
+	return j.Expiration
+}
+
+func (j *JWTService) sign(claims jwt.Claims) (string, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.signingKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(j.Secret))
+	}
+
+	token := jwt.NewWithClaims(j.signingKey.Method(), claims)
+	token.Header["kid"] = j.signingKey.ID
+	return token.SignedString(j.signingKey.Private)
+}
+
+// verificationKey picks the key a token is verified with, based on its alg
+// and kid headers.
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if j.signingKey != nil && !j.AcceptHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(j.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.Public, nil
+}
@@ -0,0 +1,192 @@
+package shared
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	denylistTokenKeyPrefix = "jwt:denylist:jti:"
+	denylistUserKeyPrefix  = "jwt:denylist:user:"
+)
+
+var denylistQueries = struct {
+	insertToken      string
+	selectToken      string
+	upsertUser       string
+	selectUserCutoff string
+}{
+	insertToken: `
+		INSERT IGNORE INTO jwt_denylist (
+			jti,
+			user_id,
+			expires_at
+		) VALUES (?, ?, ?)`,
+
+	selectToken: `
+		SELECT COUNT(jti) FROM jwt_denylist WHERE jti = ?`,
+
+	upsertUser: `
+		INSERT INTO jwt_user_revocation (
+			user_id,
+			revoked_before,
+			updated_at
+		) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			revoked_before = VALUES(revoked_before),
+			updated_at = VALUES(updated_at)`,
+
+	selectUserCutoff: `
+		SELECT revoked_before FROM jwt_user_revocation WHERE user_id = ?`,
+}
+
+// TokenDenylist keeps track of JWTs that were revoked before they expired.
+type TokenDenylist interface {
+	// Revoke revokes a single token by its jti until it expires.
+	Revoke(claims *Claims) error
+	// RevokeAllForUser revokes every token issued to a user up to now.
+	RevokeAllForUser(userID uuid.UUID) error
+	// IsRevoked checks whether a token was revoked, individually or as part
+	// of a user-wide revocation.
+	IsRevoked(claims *Claims) (bool, error)
+}
+
+// JWTDenylist is a TokenDenylist backed by Redis for fast lookups and by
+// MySQL as the source of truth. Revocations are written to both stores, and
+// fail when either write fails, so that a miss in Redis can be trusted.
+// Lookups go to Redis and fall back to MySQL when Redis is unavailable. The
+// denylist keys expire on their own, so Redis must not evict them early.
+type JWTDenylist struct {
+	redis *redis.Client
+	db    *infras.MySQLConn
+	// userRevocationTTL is how long a user-wide revocation needs to be kept,
+	// which is the maximum lifetime of an access token.
+	userRevocationTTL time.Duration
+}
+
+// ProvideJWTDenylist is the provider for JWTDenylist.
+func ProvideJWTDenylist(redis *redis.Client, db *infras.MySQLConn, config *configs.Config) *JWTDenylist {
+	ttl := time.Duration(config.App.JWT.AccessTokenExpirySeconds) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultJWTExpiration
+	}
+
+	return &JWTDenylist{
+		redis:             redis,
+		db:                db,
+		userRevocationTTL: ttl,
+	}
+}
+
+// Revoke revokes a single token by its jti until it expires. It can be
+// retried when it fails.
+func (d *JWTDenylist) Revoke(claims *Claims) error {
+	if claims.Id == "" {
+		return fmt.Errorf("token has no jti")
+	}
+
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	_, err := d.db.Write.Exec(denylistQueries.insertToken, claims.Id, claims.UserID.String(), expiresAt)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return err
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	err = d.redis.Set(denylistTokenKeyPrefix+claims.Id, 1, ttl).Err()
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return err
+}
+
+// RevokeAllForUser revokes every token issued to a user up to now. The
+// cutoff is kept in milliseconds, see Claims.IssuedBefore. It can be retried
+// when it fails.
+func (d *JWTDenylist) RevokeAllForUser(userID uuid.UUID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	_, err := d.db.Write.Exec(denylistQueries.upsertUser, userID.String(), now, now)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return err
+	}
+
+	cutoff := strconv.FormatInt(unixMilli(now), 10)
+	err = d.redis.Set(denylistUserKeyPrefix+userID.String(), cutoff, d.userRevocationTTL).Err()
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return err
+}
+
+// IsRevoked checks whether a token was revoked, individually or as part of a
+// user-wide revocation.
+func (d *JWTDenylist) IsRevoked(claims *Claims) (bool, error) {
+	revoked, err := d.isRevokedInRedis(claims)
+	if err == nil {
+		return revoked, nil
+	}
+
+	logger.ErrorWithStack(err)
+	return d.isRevokedInMySQL(claims)
+}
+
+func (d *JWTDenylist) isRevokedInRedis(claims *Claims) (bool, error) {
+	count, err := d.redis.Exists(denylistTokenKeyPrefix + claims.Id).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if count > 0 {
+		return true, nil
+	}
+
+	cutoff, err := d.redis.Get(denylistUserKeyPrefix + claims.UserID.String()).Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return claims.IssuedBefore(time.Unix(0, cutoff*int64(time.Millisecond))), nil
+}
+
+func (d *JWTDenylist) isRevokedInMySQL(claims *Claims) (bool, error) {
+	var revoked bool
+	err := d.db.Read.Get(&revoked, denylistQueries.selectToken, claims.Id)
+	if err != nil {
+		return false, err
+	}
+
+	if revoked {
+		return true, nil
+	}
+
+	var cutoff time.Time
+	err = d.db.Read.Get(&cutoff, denylistQueries.selectUserCutoff, claims.UserID.String())
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return claims.IssuedBefore(cutoff), nil
+}
@@ -0,0 +1,155 @@
+package shared_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTService(t *testing.T) {
+	userID, _ := uuid.NewV4()
+
+	t.Run("HS256", func(t *testing.T) {
+		j := shared.NewJWTService("secret", time.Minute)
+		token, expiresAt, err := j.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+
+		claims, err := j.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, claims.UserID)
+		assert.NotEmpty(t, claims.Id)
+	})
+
+	t.Run("Authorities", func(t *testing.T) {
+		j := shared.NewJWTService("secret", time.Minute)
+		token, _, err := j.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{
+			Roles:       []string{"editor"},
+			Permissions: []string{"foo:read", "foo:write"},
+		})
+		assert.NoError(t, err)
+
+		claims, err := j.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.True(t, claims.HasRole("editor"))
+		assert.True(t, claims.HasPermission("foo:write"))
+		assert.False(t, claims.HasPermission("foo:delete"))
+	})
+
+	t.Run("IssuedBefore", func(t *testing.T) {
+		j := shared.NewJWTService("secret", time.Minute)
+		before := time.Now()
+		token, _, err := j.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+		assert.NoError(t, err)
+
+		claims, err := j.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.True(t, claims.IssuedBefore(time.Now()))
+		assert.False(t, claims.IssuedBefore(before.Add(-time.Millisecond)))
+
+		// Without iat_ms, a token is issued before any time of its second.
+		claims.IssuedAtMilli = 0
+		assert.True(t, claims.IssuedBefore(time.Unix(claims.IssuedAt, 0)))
+		assert.False(t, claims.IssuedBefore(time.Unix(claims.IssuedAt-1, 0)))
+	})
+
+	for _, algorithm := range []string{shared.AlgorithmRS256, shared.AlgorithmES256, shared.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := shared.GenerateSigningKey(algorithm, "")
+			assert.NoError(t, err)
+
+			j := shared.NewJWTService("secret", time.Minute)
+			j.UseSigningKey(key)
+
+			token, _, err := j.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+			assert.NoError(t, err)
+
+			claims, err := j.ValidateJWT(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "john", claims.Username)
+
+			jwks := j.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, key.ID, jwks.Keys[0].KeyID)
+			assert.Equal(t, algorithm, jwks.Keys[0].Algorithm)
+		})
+	}
+
+	t.Run("Unknown kid", func(t *testing.T) {
+		signer, _ := shared.GenerateSigningKey(shared.AlgorithmES256, "signer")
+		other, _ := shared.GenerateSigningKey(shared.AlgorithmES256, "other")
+
+		issuing := shared.NewJWTService("secret", time.Minute)
+		issuing.UseSigningKey(signer)
+		token, _, _ := issuing.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+
+		verifying := shared.NewJWTService("secret", time.Minute)
+		verifying.UseSigningKey(other)
+		_, err := verifying.ValidateJWT(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Delegated", func(t *testing.T) {
+		j := shared.NewJWTService("secret", time.Hour)
+		subjectExpiry := time.Now().Add(time.Minute).Unix()
+
+		token, expiresAt, err := j.GenerateDelegatedJWT(shared.Claims{
+			UserID: userID,
+			Scope:  "orders:read",
+			Actor:  &shared.Actor{Subject: "cart", Actor: &shared.Actor{Subject: "web"}},
+			StandardClaims: jwt.StandardClaims{
+				Audience:  "payments",
+				ExpiresAt: subjectExpiry,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, subjectExpiry, expiresAt.Unix())
+
+		claims, err := j.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userID.String(), claims.Subject)
+		assert.Equal(t, "payments", claims.Audience)
+		assert.Equal(t, "orders:read", claims.Scope)
+		assert.Equal(t, "cart", claims.Actor.Subject)
+		assert.Equal(t, "web", claims.Actor.Actor.Subject)
+
+		_, _, err = j.GenerateDelegatedJWT(shared.Claims{UserID: userID})
+		assert.Error(t, err)
+	})
+
+	t.Run("HS256 migration window", func(t *testing.T) {
+		legacy := shared.NewJWTService("secret", time.Minute)
+		token, _, _ := legacy.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+
+		key, _ := shared.GenerateSigningKey(shared.AlgorithmRS256, "")
+		j := shared.NewJWTService("secret", time.Minute)
+		j.UseSigningKey(key)
+
+		_, err := j.ValidateJWT(token)
+		assert.NoError(t, err)
+
+		j.AcceptHS256 = false
+		_, err = j.ValidateJWT(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("SupportsOpenIDConnect", func(t *testing.T) {
+		key, _ := shared.GenerateSigningKey(shared.AlgorithmES256, "")
+
+		j := shared.NewJWTService("secret", time.Minute)
+		j.Issuer = "https://auth.evermos.com"
+		assert.False(t, j.SupportsOpenIDConnect())
+
+		j.UseSigningKey(key)
+		assert.True(t, j.SupportsOpenIDConnect())
+
+		for _, issuer := range []string{shared.DefaultJWTIssuer, "http://auth.evermos.com", "https://auth.evermos.com?tenant=1"} {
+			j.Issuer = issuer
+			assert.False(t, j.SupportsOpenIDConnect(), issuer)
+		}
+	})
+}
@@ -0,0 +1,373 @@
+// Package keyring manages the asymmetric keys JWTs are signed with.
+//
+// Keys are persisted in MySQL so that every replica signs with the same
+// active key and trusts the same set of verification keys. A key goes
+// through the following states:
+//
+//	pending -> active -> verify -> retired
+//
+// A pending key is published for verification ahead of its activation, so
+// that downstream services have picked it up from the JWKS endpoint by the
+// time tokens signed with it appear. After a rotation, the former active key
+// is kept for verification until every token it signed has expired, and is
+// then retired and its private part erased.
+package keyring
+
+import (
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultRotationPeriod is how long a key stays active by default.
+	DefaultRotationPeriod = 30 * 24 * time.Hour
+	// DefaultPrePublishPeriod is how long a new key is published before it
+	// becomes active by default.
+	DefaultPrePublishPeriod = 24 * time.Hour
+	// DefaultRefreshInterval is how often replicas resync their keys by default.
+	DefaultRefreshInterval = time.Minute
+
+	// clockSkew is added to the maximum token lifetime before a key is retired.
+	clockSkew = 5 * time.Minute
+)
+
+// KeyRing keeps the keys of a shared.JWTService in sync with the
+// jwt_signing_key table, and rotates them on schedule.
+type KeyRing struct {
+	db               *infras.MySQLConn
+	algorithm        string
+	encryptionSecret string
+	rotationPeriod   time.Duration
+	prePublishPeriod time.Duration
+	refreshInterval  time.Duration
+	tokenLifetime    time.Duration
+	jwtService       *shared.JWTService
+}
+
+// ProvideKeyRing is the provider for KeyRing.
+func ProvideKeyRing(config *configs.Config, db *infras.MySQLConn) *KeyRing {
+	keyRingConfig := config.App.JWT.KeyRing
+
+	k := &KeyRing{
+		db:               db,
+		algorithm:        config.App.JWT.Algorithm,
+		encryptionSecret: keyRingConfig.EncryptionSecret,
+		rotationPeriod:   time.Duration(keyRingConfig.RotationPeriodSeconds) * time.Second,
+		prePublishPeriod: time.Duration(keyRingConfig.PrePublishSeconds) * time.Second,
+		refreshInterval:  time.Duration(keyRingConfig.RefreshIntervalSeconds) * time.Second,
+		tokenLifetime:    time.Duration(config.App.JWT.AccessTokenExpirySeconds) * time.Second,
+	}
+
+	if k.encryptionSecret == "" {
+		k.encryptionSecret = config.App.Secret
+	}
+	if k.rotationPeriod <= 0 {
+		k.rotationPeriod = DefaultRotationPeriod
+	}
+	if k.prePublishPeriod <= 0 {
+		k.prePublishPeriod = DefaultPrePublishPeriod
+	}
+	if k.refreshInterval <= 0 {
+		k.refreshInterval = DefaultRefreshInterval
+	}
+	if k.tokenLifetime <= 0 {
+		k.tokenLifetime = shared.DefaultJWTExpiration
+	}
+
+	return k
+}
+
+// ProvideJWTService is the provider for a shared.JWTService whose keys are
+// managed by the key ring when App.JWT.KeyRing.Enabled is set. Otherwise it
+// falls back to shared.ProvideJWTService.
+func ProvideJWTService(config *configs.Config, keyRing *KeyRing) *shared.JWTService {
+	if !config.App.JWT.KeyRing.Enabled {
+		return shared.ProvideJWTService(config)
+	}
+
+	jwtService := shared.NewJWTService(config.App.Secret, time.Duration(config.App.JWT.AccessTokenExpirySeconds)*time.Second)
+	jwtService.AcceptHS256 = config.App.JWT.AcceptHS256
+	if config.App.JWT.Issuer != "" {
+		jwtService.Issuer = config.App.JWT.Issuer
+	}
+
+	if err := keyRing.Start(jwtService); err != nil {
+		log.Fatal().Err(err).Msg("Failed starting JWT key ring")
+	}
+
+	return jwtService
+}
+
+// Start loads the keys into the JWT service and keeps them in sync in the
+// background.
+func (k *KeyRing) Start(jwtService *shared.JWTService) error {
+	if !shared.IsAsymmetricAlgorithm(k.algorithm) {
+		return errors.New("key ring requires an asymmetric signing algorithm")
+	}
+
+	k.jwtService = jwtService
+	if err := k.Sync(); err != nil {
+		return err
+	}
+
+	go k.run()
+
+	log.Info().
+		Str("algorithm", k.algorithm).
+		Dur("rotationPeriod", k.rotationPeriod).
+		Dur("refreshInterval", k.refreshInterval).
+		Msg("JWT key ring started.")
+
+	return nil
+}
+
+// Sync applies any due rotation or retirement and reloads the keys into the
+// JWT service.
+func (k *KeyRing) Sync() error {
+	err := k.db.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		e <- k.txMaintain(tx, time.Now(), false)
+	})
+	if err != nil {
+		return err
+	}
+
+	return k.load()
+}
+
+// Rotate activates a new signing key right away. The previous key is kept for
+// verification, so tokens signed with it stay valid until they expire.
+func (k *KeyRing) Rotate() error {
+	if !shared.IsAsymmetricAlgorithm(k.algorithm) {
+		return errors.New("key ring requires an asymmetric signing algorithm")
+	}
+
+	err := k.db.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+		e <- k.txMaintain(tx, time.Now(), true)
+	})
+	if err != nil {
+		return err
+	}
+
+	if k.jwtService == nil {
+		return nil
+	}
+
+	return k.load()
+}
+
+func (k *KeyRing) run() {
+	ticker := time.NewTicker(k.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := k.Sync(); err != nil {
+			logger.ErrorWithStack(err)
+		}
+	}
+}
+
+// maintenance is what is due to the keys at a point in time.
+type maintenance struct {
+	// create tells whether a new pending key is created, which becomes
+	// active at activatesAt. activateCreated tells whether that is now.
+	create          bool
+	activatesAt     time.Time
+	activateCreated bool
+	// activate is the ID of the existing pending key that becomes active.
+	// deactivate is the ID of the active key that is replaced, if any.
+	activate   string
+	deactivate string
+	// retire lists the IDs of the keys that are no longer trusted.
+	retire []string
+}
+
+// plan works out how the keys move through their lifecycle at the given
+// time. A new key becomes active right away when there is no active key, or
+// when a rotation is forced.
+func (k *KeyRing) plan(keys []storedKey, now time.Time, forceRotation bool) (m maintenance) {
+	var active, pending *storedKey
+	for i := range keys {
+		switch keys[i].Status {
+		case KeyStatusActive:
+			active = &keys[i]
+		case KeyStatusPending:
+			pending = &keys[i]
+		}
+	}
+
+	rotationDue := active == nil || now.Sub(active.ActivatedAt.Time) >= k.rotationPeriod
+	if pending == nil && (rotationDue || forceRotation) {
+		m.create = true
+		m.activatesAt = now
+		if active != nil && !forceRotation {
+			m.activatesAt = now.Add(k.prePublishPeriod)
+		}
+		m.activateCreated = !m.activatesAt.After(now)
+	}
+
+	if pending != nil && (forceRotation || active == nil || !pending.ActivatedAt.Time.After(now)) {
+		m.activate = pending.KeyID
+	}
+
+	if (m.activate != "" || m.activateCreated) && active != nil {
+		m.deactivate = active.KeyID
+	}
+
+	// Keep a deactivated key for verification for the maximum lifetime of a
+	// token signed with it, plus some clock skew.
+	retention := k.tokenLifetime + clockSkew
+	for _, key := range keys {
+		if key.Status == KeyStatusVerify && now.Sub(key.DeactivatedAt.Time) >= retention {
+			m.retire = append(m.retire, key.KeyID)
+		}
+	}
+
+	return
+}
+
+// txMaintain applies the maintenance that is due. The key rows are locked for
+// the duration of the transaction, so only one replica acts at a time.
+func (k *KeyRing) txMaintain(tx *sqlx.Tx, now time.Time, forceRotation bool) (err error) {
+	keys, err := lockKeys(tx)
+	if err != nil {
+		return
+	}
+
+	m := k.plan(keys, now, forceRotation)
+
+	activate := m.activate
+	if m.create {
+		var created *storedKey
+		created, err = k.txCreateKey(tx, now, m.activatesAt)
+		if err != nil {
+			return
+		}
+
+		if m.activateCreated {
+			activate = created.KeyID
+		}
+	}
+
+	if activate != "" {
+		if m.deactivate != "" {
+			if err = deactivateKey(tx, m.deactivate, now); err != nil {
+				return
+			}
+		}
+
+		if err = activateKey(tx, activate, now); err != nil {
+			return
+		}
+
+		log.Info().Str("kid", activate).Msg("JWT signing key activated.")
+	}
+
+	for _, kid := range m.retire {
+		if err = retireKey(tx, kid, now); err != nil {
+			return
+		}
+
+		log.Info().Str("kid", kid).Msg("JWT signing key retired.")
+	}
+
+	return
+}
+
+func (k *KeyRing) txCreateKey(tx *sqlx.Tx, now time.Time, activatesAt time.Time) (*storedKey, error) {
+	sealer, err := newSealer(k.encryptionSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := shared.GenerateSigningKey(k.algorithm, "")
+	if err != nil {
+		return nil, err
+	}
+
+	privatePEM, err := key.MarshalPrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	publicPEM, err := key.MarshalPublicKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sealer.seal(key.ID, privatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := storedKey{
+		KeyID:       key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  null.StringFrom(sealed),
+		PublicKey:   string(publicPEM),
+		Status:      KeyStatusPending,
+		CreatedAt:   now,
+		ActivatedAt: null.TimeFrom(activatesAt),
+	}
+
+	if err := insertKey(tx, stored); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("kid", key.ID).Time("activatesAt", activatesAt).Msg("JWT signing key created.")
+
+	return &stored, nil
+}
+
+// load reads the unretired keys and hands them to the JWT service.
+func (k *KeyRing) load() error {
+	// Read from the write connection, so that a rotation that just happened
+	// is not missed because of replication lag.
+	keys, err := selectKeys(k.db.Write)
+	if err != nil {
+		return err
+	}
+
+	sealer, err := newSealer(k.encryptionSecret)
+	if err != nil {
+		return err
+	}
+
+	var signingKey *shared.SigningKey
+	verificationKeys := []*shared.SigningKey{}
+	for _, stored := range keys {
+		if stored.Status != KeyStatusActive {
+			key, err := shared.ParsePublicKeyPEM(stored.Algorithm, []byte(stored.PublicKey), stored.KeyID)
+			if err != nil {
+				return err
+			}
+			verificationKeys = append(verificationKeys, key)
+			continue
+		}
+
+		privatePEM, err := sealer.open(stored.KeyID, stored.PrivateKey.String)
+		if err != nil {
+			return err
+		}
+
+		signingKey, err = shared.ParsePrivateKeyPEM(stored.Algorithm, privatePEM, stored.KeyID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if signingKey == nil {
+		return errors.New("no active JWT signing key")
+	}
+
+	k.jwtService.SetKeys(signingKey, verificationKeys)
+
+	return nil
+}
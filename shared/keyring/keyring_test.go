@@ -0,0 +1,143 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealer(t *testing.T) {
+	s, err := newSealer("secret")
+	assert.NoError(t, err)
+
+	sealed, err := s.seal("kid-1", []byte("private key"))
+	assert.NoError(t, err)
+
+	t.Run("Open", func(t *testing.T) {
+		plaintext, err := s.open("kid-1", sealed)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("private key"), plaintext)
+	})
+
+	t.Run("Random nonce", func(t *testing.T) {
+		again, err := s.seal("kid-1", []byte("private key"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, sealed, again)
+	})
+
+	t.Run("Other key ID", func(t *testing.T) {
+		_, err := s.open("kid-2", sealed)
+		assert.Error(t, err)
+	})
+
+	t.Run("Other secret", func(t *testing.T) {
+		other, err := newSealer("other")
+		assert.NoError(t, err)
+
+		_, err = other.open("kid-1", sealed)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		tampered, _ := base64.StdEncoding.DecodeString(sealed)
+		tampered[len(tampered)-1] ^= 1
+
+		_, err := s.open("kid-1", base64.StdEncoding.EncodeToString(tampered))
+		assert.Error(t, err)
+	})
+
+	t.Run("Too short", func(t *testing.T) {
+		_, err := s.open("kid-1", "c2hvcnQ=")
+		assert.Error(t, err)
+	})
+
+	t.Run("Empty secret", func(t *testing.T) {
+		_, err := newSealer("")
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRingPlan(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	k := &KeyRing{
+		rotationPeriod:   30 * 24 * time.Hour,
+		prePublishPeriod: 24 * time.Hour,
+		tokenLifetime:    time.Hour,
+	}
+	retention := k.tokenLifetime + clockSkew
+
+	active := func(activatedAt time.Time) storedKey {
+		return storedKey{KeyID: "active", Status: KeyStatusActive, ActivatedAt: null.TimeFrom(activatedAt)}
+	}
+	pending := func(activatesAt time.Time) storedKey {
+		return storedKey{KeyID: "pending", Status: KeyStatusPending, ActivatedAt: null.TimeFrom(activatesAt)}
+	}
+	verify := func(kid string, deactivatedAt time.Time) storedKey {
+		return storedKey{KeyID: kid, Status: KeyStatusVerify, DeactivatedAt: null.TimeFrom(deactivatedAt)}
+	}
+
+	tests := []struct {
+		name  string
+		keys  []storedKey
+		force bool
+		want  maintenance
+	}{
+		{
+			name: "No keys",
+			want: maintenance{create: true, activatesAt: now, activateCreated: true},
+		},
+		{
+			name: "Active key",
+			keys: []storedKey{active(now.Add(-time.Hour))},
+		},
+		{
+			name: "Rotation due",
+			keys: []storedKey{active(now.Add(-k.rotationPeriod))},
+			want: maintenance{create: true, activatesAt: now.Add(k.prePublishPeriod)},
+		},
+		{
+			name: "Pending key published",
+			keys: []storedKey{active(now.Add(-k.rotationPeriod)), pending(now.Add(time.Hour))},
+		},
+		{
+			name: "Pending key due",
+			keys: []storedKey{active(now.Add(-k.rotationPeriod)), pending(now)},
+			want: maintenance{activate: "pending", deactivate: "active"},
+		},
+		{
+			name: "Pending key without active key",
+			keys: []storedKey{pending(now.Add(time.Hour))},
+			want: maintenance{activate: "pending"},
+		},
+		{
+			name:  "Forced rotation",
+			keys:  []storedKey{active(now.Add(-time.Hour))},
+			force: true,
+			want:  maintenance{create: true, activatesAt: now, activateCreated: true, deactivate: "active"},
+		},
+		{
+			name:  "Forced rotation with pending key",
+			keys:  []storedKey{active(now.Add(-time.Hour)), pending(now.Add(time.Hour))},
+			force: true,
+			want:  maintenance{activate: "pending", deactivate: "active"},
+		},
+		{
+			name: "Verification key expired",
+			keys: []storedKey{
+				active(now.Add(-time.Hour)),
+				verify("expired", now.Add(-retention)),
+				verify("kept", now.Add(-retention+time.Second)),
+			},
+			want: maintenance{retire: []string{"expired"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, k.plan(tt.keys, now, tt.force))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package keyring
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+// KeyStatus is the lifecycle state of a signing key.
+type KeyStatus string
+
+const (
+	// KeyStatusPending indicates a key that is published for verification
+	// but not yet used for signing.
+	KeyStatusPending KeyStatus = "pending"
+	// KeyStatusActive indicates the key new tokens are signed with.
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusVerify indicates a former active key that is kept until the
+	// tokens it signed have expired.
+	KeyStatusVerify KeyStatus = "verify"
+	// KeyStatusRetired indicates a key that is no longer trusted. Its
+	// private part has been erased.
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+var keyQueries = struct {
+	selectKeys    string
+	insertKey     string
+	activateKey   string
+	deactivateKey string
+	retireKey     string
+}{
+	selectKeys: `
+		SELECT
+			kid,
+			algorithm,
+			private_key,
+			public_key,
+			status,
+			created_at,
+			activated_at,
+			deactivated_at,
+			retired_at
+		FROM jwt_signing_key
+		WHERE status <> 'retired'`,
+
+	insertKey: `
+		INSERT INTO jwt_signing_key (
+			kid,
+			algorithm,
+			private_key,
+			public_key,
+			status,
+			created_at,
+			activated_at
+		) VALUES (
+			:kid,
+			:algorithm,
+			:private_key,
+			:public_key,
+			:status,
+			:created_at,
+			:activated_at
+		)`,
+
+	activateKey: `
+		UPDATE jwt_signing_key
+		SET
+			status = 'active',
+			activated_at = ?
+		WHERE kid = ?`,
+
+	deactivateKey: `
+		UPDATE jwt_signing_key
+		SET
+			status = 'verify',
+			deactivated_at = ?
+		WHERE kid = ?`,
+
+	retireKey: `
+		UPDATE jwt_signing_key
+		SET
+			status = 'retired',
+			private_key = NULL,
+			retired_at = ?
+		WHERE kid = ?`,
+}
+
+// storedKey is a signing key as persisted in the jwt_signing_key table. The
+// private key is stored encrypted.
+type storedKey struct {
+	KeyID         string      `db:"kid"`
+	Algorithm     string      `db:"algorithm"`
+	PrivateKey    null.String `db:"private_key"`
+	PublicKey     string      `db:"public_key"`
+	Status        KeyStatus   `db:"status"`
+	CreatedAt     time.Time   `db:"created_at"`
+	ActivatedAt   null.Time   `db:"activated_at"`
+	DeactivatedAt null.Time   `db:"deactivated_at"`
+	RetiredAt     null.Time   `db:"retired_at"`
+}
+
+func selectKeys(db sqlx.Queryer) (keys []storedKey, err error) {
+	err = sqlx.Select(db, &keys, keyQueries.selectKeys)
+	return
+}
+
+func lockKeys(tx *sqlx.Tx) (keys []storedKey, err error) {
+	err = tx.Select(&keys, keyQueries.selectKeys+" FOR UPDATE")
+	return
+}
+
+func insertKey(tx *sqlx.Tx, key storedKey) (err error) {
+	stmt, err := tx.PrepareNamed(keyQueries.insertKey)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(key)
+	return
+}
+
+func activateKey(tx *sqlx.Tx, kid string, now time.Time) (err error) {
+	_, err = tx.Exec(keyQueries.activateKey, now, kid)
+	return
+}
+
+func deactivateKey(tx *sqlx.Tx, kid string, now time.Time) (err error) {
+	_, err = tx.Exec(keyQueries.deactivateKey, now, kid)
+	return
+}
+
+func retireKey(tx *sqlx.Tx, kid string, now time.Time) (err error) {
+	_, err = tx.Exec(keyQueries.retireKey, now, kid)
+	return
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// sendTopic is the PubSub topic deliveries are published on.
+const sendTopic = "mailer.send"
+
+// messageBuffer is how many messages may wait for a free worker before Send
+// blocks.
+const messageBuffer = 100
+
+// AsyncMailer queues messages on a shared.PubSub worker pool, which delivers
+// them with another Mailer. A failed delivery is attempted again after a
+// delay, up to a maximum number of attempts.
+type AsyncMailer struct {
+	mailer Mailer
+	pubsub shared.PubSub
+}
+
+// NewAsyncMailer creates an AsyncMailer and starts its workers.
+func NewAsyncMailer(mailer Mailer, workers int, maxAttempts int, retryDelay time.Duration) *AsyncMailer {
+	m := &AsyncMailer{
+		mailer: mailer,
+		pubsub: shared.New(workers, shared.SetMessageBuffer(messageBuffer)),
+	}
+
+	m.pubsub.SubscriberRegistry(sendTopic, m.deliver, shared.SetMaxRetry(maxAttempts), shared.SetMaxDelayRetry(retryDelay))
+	m.pubsub.Start()
+
+	return m
+}
+
+// Send queues the message for delivery. It does not report delivery errors;
+// those are logged by the workers.
+func (m *AsyncMailer) Send(message Message) (err error) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	m.pubsub.Publish(sendTopic, payload)
+	return
+}
+
+func (m *AsyncMailer) deliver(payload []byte) (err error) {
+	var message Message
+	if err = json.Unmarshal(payload, &message); err != nil {
+		log.Error().Err(err).Msg("Discarding malformed mail message")
+		// Retrying will not fix the payload.
+		return nil
+	}
+
+	if err = m.mailer.Send(message); err != nil {
+		log.Warn().
+			Err(err).
+			Str("to", strings.Join(message.Recipients(), ", ")).
+			Str("subject", message.Subject).
+			Msg("Failed delivering mail message")
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// LogMailer logs messages instead of sending them. When a directory is set,
+// every message is also written to it as an .eml file that can be opened in
+// a mail client. It is meant for development.
+type LogMailer struct {
+	dir string
+}
+
+// NewLogMailer creates a LogMailer that writes messages to dir, if not empty.
+func NewLogMailer(dir string) *LogMailer {
+	return &LogMailer{dir: dir}
+}
+
+// Send logs the message and writes it to the directory, if set.
+func (m *LogMailer) Send(message Message) (err error) {
+	event := log.Info().
+		Str("from", message.From.Address).
+		Str("to", strings.Join(message.Recipients(), ", ")).
+		Str("subject", message.Subject)
+
+	if m.dir == "" {
+		event.Str("text", message.Text).Msg("Mail message.")
+		return
+	}
+
+	body, err := message.Bytes()
+	if err != nil {
+		return
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	path := filepath.Join(m.dir, fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), id))
+	if err = ioutil.WriteFile(path, body, 0644); err != nil {
+		return
+	}
+
+	event.Str("path", path).Msg("Mail message.")
+
+	return
+}
@@ -0,0 +1,140 @@
+// Package mailer delivers outbound email.
+//
+// A Mailer sends a single Message. SMTPMailer talks to an SMTP server, which
+// may be a local stand-in such as MailHog, LogMailer logs messages and
+// optionally writes them to a directory for development, and MemoryMailer
+// keeps them in memory for tests. AsyncMailer hands messages to another
+// Mailer through a shared.PubSub worker pool, retrying failed deliveries.
+//
+// TemplateMailer renders messages from per-locale template files before
+// sending them. See Templates for the layout of the template directory.
+package mailer
+
+import (
+	"net/mail"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/rs/zerolog/log"
+)
+
+// Supported mail drivers.
+const (
+	DriverLog  = "log"
+	DriverSMTP = "smtp"
+)
+
+const (
+	// DefaultFrom is the sender address used when Mail.From is not set.
+	DefaultFrom = "no-reply@localhost"
+	// DefaultLocale is the template locale used when Mail.DefaultLocale is
+	// not set.
+	DefaultLocale = "en"
+	// DefaultTemplateDir is the template directory used when
+	// Mail.TemplateDir is not set.
+	DefaultTemplateDir = "templates/mail"
+	// DefaultWorkers is the number of delivery workers used when
+	// Mail.Workers is not set.
+	DefaultWorkers = 2
+	// DefaultMaxAttempts is how many times a delivery is attempted when
+	// Mail.MaxAttempts is not set.
+	DefaultMaxAttempts = 3
+	// DefaultRetryDelay is the delay between delivery attempts when
+	// Mail.RetryDelaySeconds is not set.
+	DefaultRetryDelay = 5 * time.Second
+)
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(message Message) (err error)
+}
+
+// ProvideMailer is the provider for the Mailer selected by Mail.Driver. The
+// selected mailer is wrapped in an AsyncMailer, so that requests do not wait
+// for delivery.
+func ProvideMailer(config *configs.Config) Mailer {
+	mailConfig := config.Mail
+
+	var transport Mailer
+	switch mailConfig.Driver {
+	case DriverSMTP:
+		transport = NewSMTPMailer(mailConfig.SMTP.Host, mailConfig.SMTP.Port, mailConfig.SMTP.Username, mailConfig.SMTP.Password)
+	case DriverLog, "":
+		transport = NewLogMailer(mailConfig.Dir)
+	default:
+		log.Fatal().Str("driver", mailConfig.Driver).Msg("Unsupported mail driver")
+	}
+
+	workers := mailConfig.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	maxAttempts := mailConfig.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	retryDelay := time.Duration(mailConfig.RetryDelaySeconds) * time.Second
+	if retryDelay <= 0 {
+		retryDelay = DefaultRetryDelay
+	}
+
+	return NewAsyncMailer(transport, workers, maxAttempts, retryDelay)
+}
+
+// TemplateMailer renders messages from templates and sends them.
+type TemplateMailer struct {
+	mailer    Mailer
+	templates *Templates
+	from      mail.Address
+}
+
+// ProvideTemplateMailer is the provider for TemplateMailer. The templates are
+// loaded from Mail.TemplateDir.
+func ProvideTemplateMailer(config *configs.Config, mailer Mailer) *TemplateMailer {
+	templateDir := config.Mail.TemplateDir
+	if templateDir == "" {
+		templateDir = DefaultTemplateDir
+	}
+
+	defaultLocale := config.Mail.DefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = DefaultLocale
+	}
+
+	templates, err := LoadTemplates(templateDir, defaultLocale)
+	if err != nil {
+		log.Fatal().Err(err).Str("dir", templateDir).Msg("Failed loading mail templates")
+	}
+
+	from := mail.Address{Name: config.Mail.FromName, Address: config.Mail.From}
+	if from.Address == "" {
+		from.Address = DefaultFrom
+	}
+
+	return NewTemplateMailer(mailer, templates, from)
+}
+
+// NewTemplateMailer creates a TemplateMailer that sends from the given address.
+func NewTemplateMailer(mailer Mailer, templates *Templates, from mail.Address) *TemplateMailer {
+	return &TemplateMailer{
+		mailer:    mailer,
+		templates: templates,
+		from:      from,
+	}
+}
+
+// SendTemplate renders the named template in the locale closest to the given
+// one and sends it to the recipient.
+func (m *TemplateMailer) SendTemplate(to mail.Address, name string, locale string, data interface{}) (err error) {
+	message, err := m.templates.Render(name, locale, data)
+	if err != nil {
+		return
+	}
+
+	message.From = m.from
+	message.To = []mail.Address{to}
+
+	return m.mailer.Send(message)
+}
@@ -0,0 +1,119 @@
+package mailer_test
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyMailer struct {
+	mutex    sync.Mutex
+	failures int
+	attempts int
+	mailer.MemoryMailer
+}
+
+func (m *flakyMailer) Send(message mailer.Message) error {
+	m.mutex.Lock()
+	m.attempts++
+	failed := m.attempts <= m.failures
+	m.mutex.Unlock()
+
+	if failed {
+		return errors.New("connection refused")
+	}
+	return m.MemoryMailer.Send(message)
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := mailer.LoadTemplates("../../templates/mail", "en")
+	assert.NoError(t, err)
+
+	data := map[string]string{"AppName": "Evermos", "Name": "John", "Link": "https://example.com/verify-email?token=a&b"}
+
+	t.Run("Default locale", func(t *testing.T) {
+		message, err := templates.Render("email_verification", "", data)
+		assert.NoError(t, err)
+		assert.Equal(t, "Verify your email address for Evermos", message.Subject)
+		assert.Contains(t, message.Text, "https://example.com/verify-email?token=a&b")
+		assert.Contains(t, message.HTML, "https://example.com/verify-email?token=a&amp;b")
+	})
+
+	t.Run("Locale fallback", func(t *testing.T) {
+		message, err := templates.Render("email_verification", "id_ID", data)
+		assert.NoError(t, err)
+		assert.Equal(t, "Verifikasi alamat email Anda untuk Evermos", message.Subject)
+
+		message, err = templates.Render("email_verification", "fr", data)
+		assert.NoError(t, err)
+		assert.Equal(t, "Verify your email address for Evermos", message.Subject)
+	})
+
+	t.Run("Unknown template", func(t *testing.T) {
+		_, err := templates.Render("unknown", "en", data)
+		assert.Error(t, err)
+	})
+}
+
+func TestMessage(t *testing.T) {
+	message := mailer.Message{
+		From:    mail.Address{Name: "Evermos", Address: "no-reply@example.com"},
+		To:      []mail.Address{{Name: "John", Address: "john@example.com"}},
+		Subject: "Hello",
+		Text:    "Hello, John",
+		HTML:    "<p>Hello, John</p>",
+	}
+
+	t.Run("Multipart", func(t *testing.T) {
+		b, err := message.Bytes()
+		assert.NoError(t, err)
+
+		body := string(b)
+		assert.Contains(t, body, "To: \"John\" <john@example.com>\r\n")
+		assert.Contains(t, body, "Content-Type: multipart/alternative")
+		assert.Less(t, strings.Index(body, "text/plain"), strings.Index(body, "text/html"))
+	})
+
+	t.Run("No recipients", func(t *testing.T) {
+		noRecipients := message
+		noRecipients.To = nil
+
+		_, err := noRecipients.Bytes()
+		assert.Error(t, err)
+	})
+}
+
+func TestAsyncMailer(t *testing.T) {
+	message := mailer.Message{
+		To:      []mail.Address{{Address: "john@example.com"}},
+		Subject: "Hello",
+		Text:    "Hello, John",
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		transport := &flakyMailer{failures: 2}
+		async := mailer.NewAsyncMailer(transport, 1, 3, 10*time.Millisecond)
+
+		assert.NoError(t, async.Send(message))
+
+		time.Sleep(200 * time.Millisecond)
+		assert.Len(t, transport.Messages(), 1)
+		assert.Equal(t, message, transport.Messages()[0])
+	})
+
+	t.Run("Give up", func(t *testing.T) {
+		transport := &flakyMailer{failures: 5}
+		async := mailer.NewAsyncMailer(transport, 1, 3, 10*time.Millisecond)
+
+		assert.NoError(t, async.Send(message))
+
+		time.Sleep(200 * time.Millisecond)
+		assert.Empty(t, transport.Messages())
+	})
+}
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email message. At least one of HTML and Text must be set;
+// when both are, the message is sent as multipart/alternative.
+type Message struct {
+	From    mail.Address   `json:"from"`
+	To      []mail.Address `json:"to"`
+	Subject string         `json:"subject"`
+	HTML    string         `json:"html,omitempty"`
+	Text    string         `json:"text,omitempty"`
+}
+
+// Recipients returns the bare addresses of the recipients.
+func (m Message) Recipients() []string {
+	recipients := make([]string, len(m.To))
+	for i, to := range m.To {
+		recipients[i] = to.Address
+	}
+	return recipients
+}
+
+// Bytes encodes the message in the RFC 5322 format.
+func (m Message) Bytes() ([]byte, error) {
+	if len(m.To) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+	if m.HTML == "" && m.Text == "" {
+		return nil, errors.New("message has no body")
+	}
+
+	to := make([]string, len(m.To))
+	for i, address := range m.To {
+		to[i] = address.String()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.HTML == "" || m.Text == "" {
+		contentType, body := "text/plain", m.Text
+		if m.HTML != "" {
+			contentType, body = "text/html", m.HTML
+		}
+
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	// Clients display the last part they support, so the plain text
+	// alternative goes first.
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.Write(parts.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer sends messages through an SMTP server. Authentication is only
+// attempted when a username is set, which suits local stand-ins such as
+// MailHog.
+type SMTPMailer struct {
+	host     string
+	addr     string
+	username string
+	password string
+}
+
+// NewSMTPMailer creates an SMTPMailer for the server at host:port.
+func NewSMTPMailer(host string, port string, username string, password string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		addr:     net.JoinHostPort(host, port),
+		username: username,
+		password: password,
+	}
+}
+
+// Send delivers the message to the SMTP server.
+func (m *SMTPMailer) Send(message Message) (err error) {
+	body, err := message.Bytes()
+	if err != nil {
+		return
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(m.addr, auth, message.From.Address, message.Recipients(), body)
+}
@@ -0,0 +1,184 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template file suffixes. The subject and plain text body are rendered with
+// text/template, the HTML body with html/template.
+const (
+	subjectSuffix = ".subject.txt"
+	textSuffix    = ".txt"
+	htmlSuffix    = ".html"
+)
+
+// Templates renders messages from template files. The template directory has
+// a subdirectory per locale, holding up to three files per template:
+//
+//	<dir>/<locale>/<name>.subject.txt
+//	<dir>/<locale>/<name>.txt
+//	<dir>/<locale>/<name>.html
+//
+// The subject is required, and so is at least one of the bodies. Locales are
+// named in lower case, such as "en" or "id-id".
+type Templates struct {
+	defaultLocale string
+	templates     map[string]*template
+}
+
+type template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// LoadTemplates parses the templates in dir. Messages are rendered in
+// defaultLocale when no closer locale is available.
+func LoadTemplates(dir string, defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		templates:     make(map[string]*template),
+	}
+
+	locales, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(dir, locale.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+
+			if err := t.parse(filepath.Join(dir, locale.Name(), file.Name()), normalizeLocale(locale.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for key, tmpl := range t.templates {
+		if tmpl.subject == nil {
+			return nil, fmt.Errorf("mail template %s has no subject", key)
+		}
+		if tmpl.text == nil && tmpl.html == nil {
+			return nil, fmt.Errorf("mail template %s has no body", key)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Templates) parse(path string, locale string) (err error) {
+	filename := filepath.Base(path)
+
+	var name, suffix string
+	for _, s := range []string{subjectSuffix, textSuffix, htmlSuffix} {
+		if strings.HasSuffix(filename, s) {
+			name, suffix = strings.TrimSuffix(filename, s), s
+			break
+		}
+	}
+	if name == "" {
+		return
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	key := templateKey(name, locale)
+	tmpl, ok := t.templates[key]
+	if !ok {
+		tmpl = new(template)
+		t.templates[key] = tmpl
+	}
+
+	switch suffix {
+	case subjectSuffix:
+		tmpl.subject, err = texttemplate.New(filename).Parse(strings.TrimSpace(string(content)))
+	case textSuffix:
+		tmpl.text, err = texttemplate.New(filename).Parse(string(content))
+	case htmlSuffix:
+		tmpl.html, err = htmltemplate.New(filename).Parse(string(content))
+	}
+
+	return
+}
+
+// Render renders the named template with data. The template is looked up in
+// the given locale, then in its base language, and then in the default
+// locale, so "id-ID" falls back to "id" and then to, for example, "en".
+func (t *Templates) Render(name string, locale string, data interface{}) (message Message, err error) {
+	tmpl, ok := t.lookup(name, locale)
+	if !ok {
+		err = fmt.Errorf("mail template %s not found", name)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.subject.Execute(&buf, data); err != nil {
+		return
+	}
+	message.Subject = buf.String()
+
+	if tmpl.text != nil {
+		buf.Reset()
+		if err = tmpl.text.Execute(&buf, data); err != nil {
+			return
+		}
+		message.Text = buf.String()
+	}
+
+	if tmpl.html != nil {
+		buf.Reset()
+		if err = tmpl.html.Execute(&buf, data); err != nil {
+			return
+		}
+		message.HTML = buf.String()
+	}
+
+	return
+}
+
+func (t *Templates) lookup(name string, locale string) (*template, bool) {
+	locale = normalizeLocale(locale)
+
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, t.defaultLocale)
+
+	for _, candidate := range candidates {
+		if tmpl, ok := t.templates[templateKey(name, candidate)]; ok {
+			return tmpl, true
+		}
+	}
+
+	return nil, false
+}
+
+func templateKey(name string, locale string) string {
+	return locale + "/" + name
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
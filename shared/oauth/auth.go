@@ -1,41 +1,151 @@
 package oauth
 
 import (
+	"errors"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared"
 	"github.com/jmoiron/sqlx"
 )
 
+// DefaultExpiration is the lifetime in seconds of an access token when none is configured.
+const DefaultExpiration int64 = 3600
+
 type GrantType string
 
 const (
 	ClientCredentials GrantType = "client_credentials"
 	Password          GrantType = "password"
+	AuthorizationCode GrantType = "authorization_code"
+	RefreshToken      GrantType = "refresh_token"
+	DeviceCode        GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	TokenExchange     GrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
 )
 
 type Token struct {
 	config          Config
 	tokenRepository TokenStore
+	grants          *GrantRegistry
+	jwtService      *shared.JWTService
+	denylist        shared.TokenDenylist
 }
 
+// New creates a Token that can parse access tokens. No grant is registered,
+// so it can't issue any.
 func New(db *sqlx.DB, config Config) *Token {
 	return &Token{
 		config:          config,
 		tokenRepository: NewTokenStore(db),
+		grants:          NewGrantRegistry(),
+	}
+}
+
+// ProvideToken is the provider for Token. Access tokens are issued by the
+// grants of the registry. The JWT service and the denylist are used to
+// introspect and revoke JWTs.
+func ProvideToken(tokenStore TokenStore, config Config, grants *GrantRegistry, jwtService *shared.JWTService, denylist shared.TokenDenylist) *Token {
+	return &Token{
+		config:          config,
+		tokenRepository: tokenStore,
+		grants:          grants,
+		jwtService:      jwtService,
+		denylist:        denylist,
+	}
+}
+
+// ProvideConfig is the provider for Config. Unset expiries fall back to their
+// defaults.
+func ProvideConfig(config *configs.Config) Config {
+	expiration := config.App.OAuth.AccessTokenExpirySeconds
+	if expiration <= 0 {
+		expiration = DefaultExpiration
+	}
+
+	codeExpiration := config.App.OAuth.AuthorizationCodeExpirySeconds
+	if codeExpiration <= 0 {
+		codeExpiration = DefaultAuthorizationCodeExpiration
+	}
+
+	refreshExpiration := config.App.OAuth.RefreshTokenExpirySeconds
+	if refreshExpiration <= 0 {
+		refreshExpiration = DefaultRefreshTokenExpiration
+	}
+
+	deviceCodeExpiration := config.App.OAuth.DeviceCodeExpirySeconds
+	if deviceCodeExpiration <= 0 {
+		deviceCodeExpiration = DefaultDeviceCodeExpiration
+	}
+
+	devicePollInterval := config.App.OAuth.DevicePollIntervalSeconds
+	if devicePollInterval <= 0 {
+		devicePollInterval = DefaultDevicePollInterval
+	}
+
+	return Config{
+		Expiration:                  expiration,
+		AuthorizationCodeExpiration: codeExpiration,
+		RefreshTokenExpiration:      refreshExpiration,
+		DeviceCodeExpiration:        deviceCodeExpiration,
+		DevicePollInterval:          devicePollInterval,
+		DeviceVerificationURI:       config.App.OAuth.DeviceVerificationURI,
+		TokenExchangeAudiences:      config.App.OAuth.TokenExchangeAudiences,
+		ClientScope:                 config.App.OAuth.ClientScope,
+		RestrictUnverified:          config.App.User.EmailVerification.RestrictUnverified,
 	}
 }
 
 type Config struct {
-	Expiration  int64
-	ClientScope []string
+	Expiration                  int64
+	AuthorizationCodeExpiration int64
+	RefreshTokenExpiration      int64
+	DeviceCodeExpiration        int64
+	DevicePollInterval          int64
+	// DeviceVerificationURI is the page of the first-party frontend where
+	// users enter the user code of a device, and which then calls the
+	// /oauth/device endpoints. The device code grant is disabled without it.
+	DeviceVerificationURI  string
+	TokenExchangeAudiences []string
+	ClientScope            []string
+	// RestrictUnverified refuses tokens on behalf of users whose email
+	// address is not verified, see App.User.EmailVerification.
+	RestrictUnverified bool
 }
 
 // Create is function to store NewToken into database
 func (t *Token) Create(credential Credential) (*TokenResponse, error) {
-	grant, err := NewGrant(t.tokenRepository, t.config).Create(credential)
+	if !t.ClientScopeAllowed(credential.ClientID) {
+		return &TokenResponse{}, errors.New(ErrorClientNotAllowed)
+	}
+
+	authMethod, err := t.grants.Resolve(credential.GrantType)
+	if err != nil {
+		return &TokenResponse{}, err
+	}
+
+	client, err := t.tokenRepository.resolveClientByClientID(credential.ClientID)
+	if err != nil {
+		return &TokenResponse{}, err
+	}
+
+	if !client.VerifyClient(credential) {
+		return &TokenResponse{}, errors.New(ErrorInvalidClient)
+	}
+
+	if !client.GrantTypeAllowed(credential.GrantType) {
+		return &TokenResponse{}, errors.New(ErrorUnauthorizedGrantType)
+	}
+
+	accessToken, err := authMethod.Create(client, credential)
 	if err != nil {
 		return &TokenResponse{}, err
 	}
 
-	return grant.toCreateTokenResponse(), nil
+	return accessToken.toCreateTokenResponse(), nil
+}
+
+// GrantTypes returns the grant types the token endpoint accepts.
+func (t *Token) GrantTypes() []GrantType {
+	return t.grants.GrantTypes()
 }
 
 // ParseWithAccessToken is function to exchange valid token into token info
@@ -0,0 +1,338 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/golang-jwt/jwt"
+	"github.com/guregu/null"
+)
+
+const (
+	// CodeChallengeMethodS256 is the only PKCE code challenge method accepted.
+	CodeChallengeMethodS256 = "S256"
+
+	// ResponseTypeCode is the response type of the authorization code grant.
+	ResponseTypeCode = "code"
+
+	// DefaultAuthorizationCodeExpiration is the lifetime in seconds of an
+	// authorization code when none is configured.
+	DefaultAuthorizationCodeExpiration int64 = 60
+
+	// ScopeOpenID is the scope requesting OpenID Connect, which ID tokens and
+	// the UserInfo endpoint require.
+	ScopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+)
+
+// codeVerifierPattern matches a PKCE code verifier, see RFC 7636, section 4.1.
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// AuthorizationRequest is a request to the authorization endpoint, made on
+// behalf of an authenticated user.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	UserID              string
+	AuthTime            time.Time
+}
+
+// AuthorizationResponse is the result of an authorization request. Once the
+// redirect URI has been validated, errors are reported to the client through
+// it as well.
+type AuthorizationResponse struct {
+	RedirectURI string
+	Code        string
+	State       string
+}
+
+// AuthorizationRedirect is the URI the user agent is sent to after an
+// authorization request, carrying either the code or the error.
+type AuthorizationRedirect struct {
+	Location string `json:"location"`
+}
+
+// Location builds the URI the user agent is redirected to. When err is not
+// nil, the redirect carries the error instead of the code.
+func (a AuthorizationResponse) Location(err error) string {
+	location, parseErr := url.Parse(a.RedirectURI)
+	if parseErr != nil {
+		return a.RedirectURI
+	}
+
+	query := location.Query()
+	if err != nil {
+		oauthErr := ToError(err)
+		query.Set("error", oauthErr.Code)
+		if oauthErr.Description != "" {
+			query.Set("error_description", oauthErr.Description)
+		}
+	} else {
+		query.Set("code", a.Code)
+	}
+	if a.State != "" {
+		query.Set("state", a.State)
+	}
+	location.RawQuery = query.Encode()
+
+	return location.String()
+}
+
+// Authorize validates an authorization request and issues a single-use
+// authorization code bound to the PKCE code challenge. The response carries
+// no redirect URI when the client or redirect URI is invalid, in which case
+// the user agent must not be redirected.
+func (t *Token) Authorize(request AuthorizationRequest) (response AuthorizationResponse, err error) {
+	if !t.ClientScopeAllowed(request.ClientID) {
+		err = errors.New(ErrorClientNotAllowed)
+		return
+	}
+
+	client, err := t.tokenRepository.resolveClientByClientID(request.ClientID)
+	if err != nil {
+		return
+	}
+
+	if request.RedirectURI == "" || !client.RedirectURIAllowed(request.RedirectURI) {
+		err = errors.New(ErrorInvalidRedirectURI)
+		return
+	}
+
+	response.RedirectURI = request.RedirectURI
+	response.State = request.State
+
+	if request.ResponseType != ResponseTypeCode {
+		err = errors.New(ErrorUnsupportedResponseType)
+		return
+	}
+
+	if !t.grants.IsEnabled(AuthorizationCode) {
+		err = errors.New(ErrorUnsupportedResponseType)
+		return
+	}
+
+	if !client.GrantTypeAllowed(AuthorizationCode) {
+		err = errors.New(ErrorUnauthorizedGrantType)
+		return
+	}
+
+	scope, err := client.GrantScope(request.Scope)
+	if err != nil {
+		return
+	}
+
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != CodeChallengeMethodS256 {
+		err = errors.New(ErrorMissingCodeChallenge)
+		return
+	}
+
+	code, err := generateAuthorizationCode()
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+
+	now := time.Now()
+	authorizationCode := OauthAuthorizationCode{
+		Code:                hashAuthorizationCode(code),
+		ClientID:            client.ClientID,
+		UserID:              request.UserID,
+		RedirectURI:         request.RedirectURI,
+		Scope:               scope,
+		Nonce:               null.NewString(request.Nonce, request.Nonce != ""),
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: request.CodeChallengeMethod,
+		AuthTime:            null.NewTime(request.AuthTime, !request.AuthTime.IsZero()),
+		Expires:             now.Add(time.Second * time.Duration(t.config.AuthorizationCodeExpiration)),
+		CreatedAt:           now,
+	}
+
+	err = t.tokenRepository.createAuthorizationCode(authorizationCode)
+	if err != nil {
+		return
+	}
+
+	response.Code = code
+	return
+}
+
+// authorizationCodeStore is the part of TokenStore that AuthorizationCodeAuth
+// uses.
+type authorizationCodeStore interface {
+	resolveAuthorizationCode(codeHash string) (OauthAuthorizationCode, error)
+	consumeAuthorizationCode(codeHash string, usedAt time.Time, accessToken OauthAccessToken, refreshToken *OauthRefreshToken) (bool, error)
+	deleteAccessToken(accessToken string) error
+	revokeRefreshTokenFamily(familyID string, revokedAt time.Time) error
+	resolveUserProfileByID(id string) (UserProfile, error)
+}
+
+// AuthorizationCodeAuth exchanges an authorization code for an access token.
+type AuthorizationCodeAuth struct {
+	tokenStore authorizationCodeStore
+	config     Config
+	jwtService *shared.JWTService
+}
+
+func (c *AuthorizationCodeAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	codeHash := hashAuthorizationCode(credential.Code)
+	code, err := c.tokenStore.resolveAuthorizationCode(codeHash)
+	if err != nil {
+		return
+	}
+
+	if code.ClientID != credential.ClientID || code.RedirectURI != credential.RedirectURI || code.IsExpired() {
+		err = errors.New(ErrorInvalidAuthorizationCode)
+		return
+	}
+
+	if !verifyCodeChallenge(code.CodeChallenge, credential.CodeVerifier) {
+		err = errors.New(ErrorInvalidCodeVerifier)
+		return
+	}
+
+	err = verifyUserEmail(c.tokenStore, c.config, code.UserID)
+	if err != nil {
+		return
+	}
+
+	accessToken, err := generateAccessToken()
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, credential.ClientID, null.StringFrom(code.UserID), code.Scope, c.config)
+
+	var refreshToken *OauthRefreshToken
+	var plainRefreshToken string
+	if client.GrantTypeAllowed(RefreshToken) {
+		var next OauthRefreshToken
+		next, plainRefreshToken, err = newRefreshTokenFamily(oauthAccessToken, code.Scope, c.config)
+		if err != nil {
+			return
+		}
+		refreshToken = &next
+	}
+
+	consumed, err := c.tokenStore.consumeAuthorizationCode(codeHash, time.Now(), oauthAccessToken, refreshToken)
+	if err != nil {
+		return
+	}
+
+	if !consumed {
+		// A code that is presented twice may have been intercepted, so the
+		// tokens issued for it are revoked as well. See RFC 6749, section
+		// 4.1.2.
+		err = c.revokeCodeTokens(codeHash)
+		if err != nil {
+			return
+		}
+
+		err = errors.New(ErrorInvalidAuthorizationCode)
+		return
+	}
+
+	oauthAccessToken.RefreshToken = plainRefreshToken
+
+	if code.HasScope(ScopeOpenID) && c.jwtService != nil {
+		oauthAccessToken.IDToken, err = c.createIDToken(code)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// revokeCodeTokens revokes the access token and the refresh token family
+// issued for a code. The code is resolved again, as the tokens are only
+// recorded once the first exchange has been committed.
+func (c *AuthorizationCodeAuth) revokeCodeTokens(codeHash string) error {
+	code, err := c.tokenStore.resolveAuthorizationCode(codeHash)
+	if err != nil {
+		return err
+	}
+
+	if code.AccessToken.Valid {
+		err = c.tokenStore.deleteAccessToken(code.AccessToken.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	if code.RefreshTokenFamilyID.Valid {
+		return c.tokenStore.revokeRefreshTokenFamily(code.RefreshTokenFamilyID.String, time.Now())
+	}
+
+	return nil
+}
+
+// createIDToken issues the OpenID Connect ID token for the user the code was
+// issued to, disclosing the claims covered by the granted scope.
+func (c *AuthorizationCodeAuth) createIDToken(code OauthAuthorizationCode) (string, error) {
+	profile, err := c.tokenStore.resolveUserProfileByID(code.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	claims := shared.IDTokenClaims{
+		Nonce: code.Nonce.String,
+		StandardClaims: jwt.StandardClaims{
+			Subject:  profile.ID,
+			Audience: code.ClientID,
+		},
+	}
+	if code.AuthTime.Valid {
+		claims.AuthTime = code.AuthTime.Time.Unix()
+	}
+	if code.HasScope(scopeProfile) {
+		claims.Name = profile.Name
+		claims.PreferredUsername = profile.Username
+	}
+	if code.HasScope(scopeEmail) {
+		claims.Email = profile.Email
+	}
+
+	return c.jwtService.GenerateIDToken(claims)
+}
+
+func generateAuthorizationCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashAuthorizationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyCodeChallenge checks the code verifier against an S256 code challenge,
+// see RFC 7636, section 4.6.
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+// The example of RFC 7636, appendix B.
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type fakeAuthorizationCodeStore struct {
+	codes           map[string]OauthAuthorizationCode
+	accessTokens    map[string]OauthAccessToken
+	refreshTokens   map[string]OauthRefreshToken
+	revokedFamilies map[string]bool
+}
+
+func newFakeAuthorizationCodeStore(codes ...OauthAuthorizationCode) *fakeAuthorizationCodeStore {
+	s := &fakeAuthorizationCodeStore{
+		codes:           make(map[string]OauthAuthorizationCode),
+		accessTokens:    make(map[string]OauthAccessToken),
+		refreshTokens:   make(map[string]OauthRefreshToken),
+		revokedFamilies: make(map[string]bool),
+	}
+	for _, code := range codes {
+		s.codes[code.Code] = code
+	}
+	return s
+}
+
+func (s *fakeAuthorizationCodeStore) resolveAuthorizationCode(codeHash string) (OauthAuthorizationCode, error) {
+	code, ok := s.codes[codeHash]
+	if !ok {
+		return code, errors.New(ErrorInvalidAuthorizationCode)
+	}
+	return code, nil
+}
+
+func (s *fakeAuthorizationCodeStore) consumeAuthorizationCode(codeHash string, usedAt time.Time, accessToken OauthAccessToken, refreshToken *OauthRefreshToken) (bool, error) {
+	code := s.codes[codeHash]
+	if code.UsedAt.Valid {
+		return false, nil
+	}
+
+	code.UsedAt = null.TimeFrom(usedAt)
+	code.AccessToken = null.StringFrom(accessToken.AccessToken)
+	s.accessTokens[accessToken.AccessToken] = accessToken
+	if refreshToken != nil {
+		code.RefreshTokenFamilyID = null.StringFrom(refreshToken.FamilyID)
+		s.refreshTokens[refreshToken.RefreshToken] = *refreshToken
+	}
+	s.codes[codeHash] = code
+
+	return true, nil
+}
+
+func (s *fakeAuthorizationCodeStore) deleteAccessToken(accessToken string) error {
+	delete(s.accessTokens, accessToken)
+	return nil
+}
+
+func (s *fakeAuthorizationCodeStore) revokeRefreshTokenFamily(familyID string, revokedAt time.Time) error {
+	s.revokedFamilies[familyID] = true
+	return nil
+}
+
+func (s *fakeAuthorizationCodeStore) resolveUserProfileByID(id string) (UserProfile, error) {
+	return UserProfile{ID: id}, nil
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{name: "S256", challenge: testCodeChallenge, verifier: testCodeVerifier, want: true},
+		{name: "Wrong verifier", challenge: testCodeChallenge, verifier: "eBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"},
+		{name: "Plain", challenge: testCodeVerifier, verifier: testCodeVerifier},
+		{name: "Short verifier", challenge: testCodeChallenge, verifier: "dBjftJeZ4CVP"},
+		{name: "Invalid characters", challenge: testCodeChallenge, verifier: "dBjftJeZ4CVP+mB92K27uhbUJU1p1r/wW1gFWFOEjXk"},
+		{name: "Missing verifier", challenge: testCodeChallenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, verifyCodeChallenge(tt.challenge, tt.verifier))
+		})
+	}
+}
+
+func TestAuthorizationCodeAuthCreate(t *testing.T) {
+	client := OauthClient{ClientID: "client_web", GrantTypes: "authorization_code refresh_token"}
+	config := Config{Expiration: 3600, RefreshTokenExpiration: 86400}
+
+	newCode := func(plain string) OauthAuthorizationCode {
+		return OauthAuthorizationCode{
+			Code:                hashAuthorizationCode(plain),
+			ClientID:            "client_web",
+			UserID:              "550e8400-e29b-41d4-a716-446655440000",
+			RedirectURI:         "https://evermos.com/callback",
+			Scope:               null.StringFrom("user"),
+			CodeChallenge:       testCodeChallenge,
+			CodeChallengeMethod: CodeChallengeMethodS256,
+			Expires:             time.Now().Add(time.Minute),
+		}
+	}
+
+	newCredential := func(plain string) Credential {
+		return Credential{
+			ClientID:     "client_web",
+			Code:         plain,
+			RedirectURI:  "https://evermos.com/callback",
+			CodeVerifier: testCodeVerifier,
+		}
+	}
+
+	t.Run("Exchange", func(t *testing.T) {
+		store := newFakeAuthorizationCodeStore(newCode("code"))
+		c := &AuthorizationCodeAuth{tokenStore: store, config: config}
+
+		accessToken, err := c.Create(client, newCredential("code"))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, accessToken.AccessToken)
+		assert.NotEmpty(t, accessToken.RefreshToken)
+		assert.Contains(t, store.accessTokens, accessToken.AccessToken)
+		assert.Contains(t, store.refreshTokens, hashRefreshToken(accessToken.RefreshToken))
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		store := newFakeAuthorizationCodeStore(newCode("code"))
+		c := &AuthorizationCodeAuth{tokenStore: store, config: config}
+
+		accessToken, err := c.Create(client, newCredential("code"))
+		assert.NoError(t, err)
+		family := store.refreshTokens[hashRefreshToken(accessToken.RefreshToken)].FamilyID
+
+		_, err = c.Create(client, newCredential("code"))
+		assert.EqualError(t, err, ErrorInvalidAuthorizationCode)
+		assert.NotContains(t, store.accessTokens, accessToken.AccessToken)
+		assert.True(t, store.revokedFamilies[family])
+	})
+
+	t.Run("Unverified user", func(t *testing.T) {
+		store := newFakeAuthorizationCodeStore(newCode("code"))
+		restricted := config
+		restricted.RestrictUnverified = true
+		c := &AuthorizationCodeAuth{tokenStore: store, config: restricted}
+
+		_, err := c.Create(client, newCredential("code"))
+		assert.EqualError(t, err, ErrorUnverifiedUser)
+		assert.False(t, store.codes[hashAuthorizationCode("code")].UsedAt.Valid)
+	})
+
+	tests := []struct {
+		name       string
+		credential func(Credential) Credential
+		code       func(OauthAuthorizationCode) OauthAuthorizationCode
+		err        string
+	}{
+		{
+			name: "Wrong code verifier",
+			credential: func(c Credential) Credential {
+				c.CodeVerifier = "eBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+				return c
+			},
+			err: ErrorInvalidCodeVerifier,
+		},
+		{
+			name:       "Wrong redirect URI",
+			credential: func(c Credential) Credential { c.RedirectURI = "https://evermos.com/"; return c },
+			err:        ErrorInvalidAuthorizationCode,
+		},
+		{
+			name:       "Other client",
+			credential: func(c Credential) Credential { c.ClientID = "client_batch"; return c },
+			err:        ErrorInvalidAuthorizationCode,
+		},
+		{
+			name: "Expired",
+			code: func(c OauthAuthorizationCode) OauthAuthorizationCode {
+				c.Expires = time.Now().Add(-time.Second)
+				return c
+			},
+			err: ErrorInvalidAuthorizationCode,
+		},
+		{
+			name:       "Unknown code",
+			credential: func(c Credential) Credential { c.Code = "unknown"; return c },
+			err:        ErrorInvalidAuthorizationCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := newCode("code")
+			if tt.code != nil {
+				code = tt.code(code)
+			}
+			credential := newCredential("code")
+			if tt.credential != nil {
+				credential = tt.credential(credential)
+			}
+
+			store := newFakeAuthorizationCodeStore(code)
+			c := &AuthorizationCodeAuth{tokenStore: store, config: config}
+
+			_, err := c.Create(client, credential)
+			assert.EqualError(t, err, tt.err)
+			assert.False(t, store.codes[code.Code].UsedAt.Valid)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Error codes of requests to protected resources, RFC 6750, section 3.1.
+const (
+	ErrorCodeInvalidToken      string = "invalid_token"
+	ErrorCodeInsufficientScope string = "insufficient_scope"
+)
+
+const (
+	ErrorMissingAccessToken string = "Access token is missing"
+	ErrorExpiredAccessToken string = "Access token has expired"
+	ErrorNoUserAccessToken  string = "Access token was not issued on behalf of a user"
+	ErrorInsufficientScope  string = "Access token lacks the required scope"
+)
+
+// BearerError is the error of a request to a resource protected by an access
+// token. It is sent in the WWW-Authenticate header, see RFC 6750, section 3.
+type BearerError struct {
+	Code        string
+	Description string
+	// Scope is the space-delimited scope required to access the resource.
+	Scope string
+}
+
+// NewBearerError creates a BearerError with the given code and description.
+func NewBearerError(code string, description string) *BearerError {
+	return &BearerError{
+		Code:        code,
+		Description: description,
+	}
+}
+
+// Error returns the code and description in a formatted string.
+func (e *BearerError) Error() string {
+	if e.Code == "" {
+		return e.Description
+	}
+	return e.Code + ": " + e.Description
+}
+
+// StatusCode returns the HTTP status code the error is sent with. A request
+// without credentials has no error code and is unauthorized.
+func (e *BearerError) StatusCode() int {
+	switch e.Code {
+	case ErrorCodeInvalidRequest:
+		return http.StatusBadRequest
+	case ErrorCodeInsufficientScope:
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// Challenge returns the value of the WWW-Authenticate header. A request
+// without credentials is not told an error code, see RFC 6750, section 3.1.
+func (e *BearerError) Challenge() string {
+	var params []string
+	if e.Code != "" {
+		params = append(params, challengeParam("error", e.Code))
+		if e.Description != "" {
+			params = append(params, challengeParam("error_description", e.Description))
+		}
+	}
+	if e.Scope != "" {
+		params = append(params, challengeParam("scope", e.Scope))
+	}
+
+	if len(params) == 0 {
+		return string(Bearer)
+	}
+	return string(Bearer) + " " + strings.Join(params, ", ")
+}
+
+// challengeParam formats an auth-param. The characters of error codes,
+// descriptions and scopes are limited so that they don't need escaping, see
+// RFC 6750, section 3.
+func challengeParam(name string, value string) string {
+	value = strings.NewReplacer(`"`, "'", `\`, "/").Replace(value)
+	return name + `="` + value + `"`
+}
+
+// VerifyAccessToken resolves the access token of a request to a protected resource,
+// given as the value of its Authorization header. The token must be valid, and
+// must have been granted all of the given scopes. When userRequired is set,
+// the token must have been issued on behalf of a user. Errors that are not
+// BearerErrors, such as database errors, are returned as is.
+func (t *Token) VerifyAccessToken(authorization string, userRequired bool, scopes ...string) (OauthAccessToken, error) {
+	if authorization == "" {
+		return OauthAccessToken{}, NewBearerError("", ErrorMissingAccessToken)
+	}
+
+	accessToken, err := t.ParseWithAccessToken(authorization)
+	if err != nil {
+		switch err.Error() {
+		case ErrorEmptyCredential, ErrorTokenTypeMismatch:
+			return accessToken, NewBearerError(ErrorCodeInvalidRequest, err.Error())
+		case ErrorClientNotFound:
+			return accessToken, NewBearerError(ErrorCodeInvalidToken, ErrorInvalidToken)
+		}
+		return accessToken, err
+	}
+
+	if !accessToken.VerifyExpireIn() {
+		return accessToken, NewBearerError(ErrorCodeInvalidToken, ErrorExpiredAccessToken)
+	}
+
+	if userRequired && !accessToken.VerifyUserLoggedIn() {
+		return accessToken, NewBearerError(ErrorCodeInvalidToken, ErrorNoUserAccessToken)
+	}
+
+	if !accessToken.HasScopes(scopes...) {
+		e := NewBearerError(ErrorCodeInsufficientScope, ErrorInsufficientScope)
+		e.Scope = strings.Join(scopes, " ")
+		return accessToken, e
+	}
+
+	return accessToken, nil
+}
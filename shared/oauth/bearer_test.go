@@ -0,0 +1,51 @@
+package oauth_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerError(t *testing.T) {
+	t.Run("Missing credentials", func(t *testing.T) {
+		e := oauth.NewBearerError("", oauth.ErrorMissingAccessToken)
+		assert.Equal(t, http.StatusUnauthorized, e.StatusCode())
+		assert.Equal(t, "Bearer", e.Challenge())
+	})
+
+	t.Run("Insufficient scope", func(t *testing.T) {
+		e := oauth.NewBearerError(oauth.ErrorCodeInsufficientScope, oauth.ErrorInsufficientScope)
+		e.Scope = "foo:read foo:write"
+		assert.Equal(t, http.StatusForbidden, e.StatusCode())
+		assert.Equal(t, `Bearer error="insufficient_scope", error_description="Access token lacks the required scope", scope="foo:read foo:write"`, e.Challenge())
+	})
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	token := oauth.New(nil, oauth.Config{})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := token.VerifyAccessToken("", false)
+		e, ok := err.(*oauth.BearerError)
+		assert.True(t, ok)
+		assert.Equal(t, "", e.Code)
+	})
+
+	t.Run("Not a bearer token", func(t *testing.T) {
+		_, err := token.VerifyAccessToken("Basic Y2xpZW50OnNlY3JldA==", false)
+		e, ok := err.(*oauth.BearerError)
+		assert.True(t, ok)
+		assert.Equal(t, oauth.ErrorCodeInvalidRequest, e.Code)
+	})
+}
+
+func TestOauthAccessTokenScopes(t *testing.T) {
+	accessToken := oauth.OauthAccessToken{Scope: null.StringFrom("foo:read foo:write")}
+
+	assert.Equal(t, []string{"foo:read", "foo:write"}, accessToken.Scopes())
+	assert.True(t, accessToken.HasScopes("foo:write", "foo:read"))
+	assert.False(t, accessToken.HasScopes("foo:read", "foo:delete"))
+}
@@ -1,7 +1,7 @@
 package oauth
 
 import (
-	"errors"
+	"github.com/guregu/null"
 )
 
 type ClientCredentialsAuth struct {
@@ -9,28 +9,11 @@ type ClientCredentialsAuth struct {
 	config     Config
 }
 
-func (c *ClientCredentialsAuth) Create(credential Credential) (oauthAccessToken OauthAccessToken, err error) {
-	client, err := c.tokenStore.resolveClientByClientID(credential.ClientID)
+func (c *ClientCredentialsAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	scope, err := client.GrantScope(credential.Scope)
 	if err != nil {
 		return
 	}
 
-	if !client.VerifyClient(credential) {
-		err = errors.New(ErrorInvalidClient)
-		return
-	}
-
-	accessToken, err := generateAccessToken()
-	if err != nil {
-		err = errors.New(ErrorGenerateAccessToken)
-		return
-	}
-
-	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, credential.ClientID, nil, true, c.config)
-	err = c.tokenStore.createAccessToken(oauthAccessToken)
-	if err != nil {
-		return
-	}
-
-	return
+	return IssueAccessToken(c.tokenStore, c.config, credential.ClientID, null.String{}, scope)
 }
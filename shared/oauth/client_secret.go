@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// legacyClientSecretHashPrefix marks the unsalted SHA-256 hashes the
+	// secrets stored before hashing was introduced were migrated to. They are
+	// not accepted until stretched by StretchLegacyClientSecretHash.
+	legacyClientSecretHashPrefix = "sha256$"
+	// stretchedClientSecretHashPrefix marks a bcrypt hash of a legacy SHA-256
+	// hash.
+	stretchedClientSecretHashPrefix = "bcrypt-sha256$"
+)
+
+// GenerateClientSecret generates a random client secret. Only its hash, as
+// returned by HashClientSecret, is to be stored.
+func GenerateClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashClientSecret hashes a client secret for storage with bcrypt, the same
+// way user passwords are.
+func HashClientSecret(secret string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+// IsLegacyClientSecretHash checks whether a stored hash is an unsalted
+// SHA-256 hash that still needs stretching.
+func IsLegacyClientSecretHash(hashed string) bool {
+	return strings.HasPrefix(hashed, legacyClientSecretHashPrefix)
+}
+
+// StretchLegacyClientSecretHash salts and stretches a legacy SHA-256 hash by
+// hashing it with bcrypt. The plain text secret is not needed, so the stored
+// hashes can be upgraded in place.
+func StretchLegacyClientSecretHash(hashed string) (string, error) {
+	stretched, err := HashClientSecret(strings.TrimPrefix(hashed, legacyClientSecretHashPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	return stretchedClientSecretHashPrefix + stretched, nil
+}
+
+// compareClientSecret compares a secret against a stored hash.
+func compareClientSecret(hashed string, secret string) bool {
+	if IsLegacyClientSecretHash(hashed) {
+		return false
+	}
+
+	if strings.HasPrefix(hashed, stretchedClientSecretHashPrefix) {
+		hashed = strings.TrimPrefix(hashed, stretchedClientSecretHashPrefix)
+		sum := sha256.Sum256([]byte(secret))
+		secret = hex.EncodeToString(sum[:])
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(secret)) == nil
+}
@@ -0,0 +1,307 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+const (
+	// DefaultDeviceCodeExpiration is the lifetime in seconds of a device code
+	// when none is configured.
+	DefaultDeviceCodeExpiration int64 = 600
+	// DefaultDevicePollInterval is the minimum number of seconds between two
+	// token requests of a device when none is configured.
+	DefaultDevicePollInterval int64 = 5
+
+	// slowDownIncrement is added to the polling interval of a device that
+	// polls too frequently, see RFC 8628, section 3.5.
+	slowDownIncrement int64 = 5
+
+	// userCodeAlphabet avoids vowels, so that no words are spelled, and
+	// characters that are easily confused with each other.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// DeviceCodeStatus is the state of a device authorization.
+type DeviceCodeStatus string
+
+const (
+	// DeviceCodeStatusPending indicates that the user has not decided yet.
+	DeviceCodeStatusPending DeviceCodeStatus = "pending"
+	// DeviceCodeStatusApproved indicates that the user approved the device.
+	DeviceCodeStatusApproved DeviceCodeStatus = "approved"
+	// DeviceCodeStatusDenied indicates that the user denied the device.
+	DeviceCodeStatusDenied DeviceCodeStatus = "denied"
+)
+
+// OauthDeviceCode is a pending device authorization. Only the SHA-256 hash of
+// the device code is stored, while the user code is stored normalized.
+type OauthDeviceCode struct {
+	DeviceCode      string           `db:"device_code"`
+	UserCode        string           `db:"user_code"`
+	ClientID        string           `db:"client_id"`
+	Scope           null.String      `db:"scope"`
+	Status          DeviceCodeStatus `db:"status"`
+	UserID          null.String      `db:"user_id"`
+	IntervalSeconds int64            `db:"interval_seconds"`
+	Expires         time.Time        `db:"expires"`
+	LastPolledAt    null.Time        `db:"last_polled_at"`
+	DecidedAt       null.Time        `db:"decided_at"`
+	CreatedAt       time.Time        `db:"created_at"`
+}
+
+// IsExpired checks whether the device code can no longer be used.
+func (o *OauthDeviceCode) IsExpired() bool {
+	return time.Now().After(o.Expires)
+}
+
+// DeviceAuthorizationResponse is the response of the device authorization
+// endpoint, see RFC 8628, section 3.2.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorization describes a pending device authorization to the user
+// who is asked to approve it.
+type DeviceAuthorization struct {
+	UserCode  string    `json:"userCode"`
+	ClientID  string    `json:"clientId"`
+	Scope     string    `json:"scope"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// AuthorizeDevice starts a device authorization for an authenticated client.
+func (t *Token) AuthorizeDevice(credential Credential) (response DeviceAuthorizationResponse, err error) {
+	if !t.grants.IsEnabled(DeviceCode) {
+		err = errors.New(ErrorUnsupportedGrant)
+		return
+	}
+
+	client, err := t.authenticateClient(credential)
+	if err != nil {
+		return
+	}
+
+	if !client.GrantTypeAllowed(DeviceCode) {
+		err = errors.New(ErrorUnauthorizedGrantType)
+		return
+	}
+
+	scope, err := client.GrantScope(credential.Scope)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if err = t.tokenRepository.deleteExpiredDeviceCodes(now); err != nil {
+		return
+	}
+
+	deviceCode, err := generateAuthorizationCode()
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+
+	err = t.tokenRepository.createDeviceCode(OauthDeviceCode{
+		DeviceCode:      hashAuthorizationCode(deviceCode),
+		UserCode:        userCode,
+		ClientID:        client.ClientID,
+		Scope:           scope,
+		Status:          DeviceCodeStatusPending,
+		IntervalSeconds: t.config.DevicePollInterval,
+		Expires:         now.Add(time.Second * time.Duration(t.config.DeviceCodeExpiration)),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		return
+	}
+
+	formatted := formatUserCode(userCode)
+	response = DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatted,
+		VerificationURI:         t.config.DeviceVerificationURI,
+		VerificationURIComplete: t.config.DeviceVerificationURI + "?user_code=" + formatted,
+		ExpiresIn:               t.config.DeviceCodeExpiration,
+		Interval:                t.config.DevicePollInterval,
+	}
+
+	return
+}
+
+// ResolveDeviceAuthorization resolves the pending device authorization a user
+// code was issued for.
+func (t *Token) ResolveDeviceAuthorization(userCode string) (authorization DeviceAuthorization, err error) {
+	deviceCode, err := t.tokenRepository.resolveDeviceCodeByUserCode(normalizeUserCode(userCode))
+	if err != nil {
+		return
+	}
+
+	if deviceCode.Status != DeviceCodeStatusPending || deviceCode.IsExpired() {
+		err = errors.New(ErrorInvalidUserCode)
+		return
+	}
+
+	return DeviceAuthorization{
+		UserCode:  formatUserCode(deviceCode.UserCode),
+		ClientID:  deviceCode.ClientID,
+		Scope:     deviceCode.Scope.String,
+		ExpiresAt: deviceCode.Expires,
+	}, nil
+}
+
+// DecideDeviceAuthorization records whether the user approved or denied the
+// device authorization a user code was issued for.
+func (t *Token) DecideDeviceAuthorization(userCode string, userID string, approve bool) error {
+	status := DeviceCodeStatusDenied
+	if approve {
+		status = DeviceCodeStatusApproved
+	}
+
+	decided, err := t.tokenRepository.decideDeviceCode(normalizeUserCode(userCode), status, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !decided {
+		return errors.New(ErrorInvalidUserCode)
+	}
+
+	return nil
+}
+
+// deviceCodeStore is the part of TokenStore that DeviceCodeAuth uses.
+type deviceCodeStore interface {
+	resolveDeviceCode(deviceCodeHash string) (OauthDeviceCode, error)
+	updateDeviceCodePoll(deviceCodeHash string, intervalSeconds int64, polledAt time.Time) error
+	consumeDeviceCode(deviceCodeHash string) (bool, error)
+	resolveUserProfileByID(id string) (UserProfile, error)
+	createAccessToken(accessToken OauthAccessToken) error
+	createRefreshToken(refreshToken OauthRefreshToken) error
+}
+
+// DeviceCodeAuth exchanges an approved device code for an access token.
+type DeviceCodeAuth struct {
+	tokenStore deviceCodeStore
+	config     Config
+}
+
+func (c *DeviceCodeAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	deviceCodeHash := hashAuthorizationCode(credential.DeviceCode)
+	deviceCode, err := c.tokenStore.resolveDeviceCode(deviceCodeHash)
+	if err != nil {
+		return
+	}
+
+	if deviceCode.ClientID != client.ClientID {
+		err = errors.New(ErrorInvalidDeviceCode)
+		return
+	}
+
+	if deviceCode.IsExpired() {
+		err = errors.New(ErrorExpiredToken)
+		return
+	}
+
+	now := time.Now()
+	switch deviceCode.Status {
+	case DeviceCodeStatusDenied:
+		err = errors.New(ErrorAccessDenied)
+		return
+	case DeviceCodeStatusPending:
+		interval := deviceCode.IntervalSeconds
+		tooFast := deviceCode.LastPolledAt.Valid &&
+			now.Sub(deviceCode.LastPolledAt.Time) < time.Second*time.Duration(interval)
+		if tooFast {
+			interval += slowDownIncrement
+		}
+
+		err = c.tokenStore.updateDeviceCodePoll(deviceCodeHash, interval, now)
+		if err != nil {
+			return
+		}
+
+		if tooFast {
+			err = errors.New(ErrorSlowDown)
+			return
+		}
+
+		err = errors.New(ErrorAuthorizationPending)
+		return
+	}
+
+	err = verifyUserEmail(c.tokenStore, c.config, deviceCode.UserID.String)
+	if err != nil {
+		return
+	}
+
+	consumed, err := c.tokenStore.consumeDeviceCode(deviceCodeHash)
+	if err != nil {
+		return
+	}
+
+	if !consumed {
+		err = errors.New(ErrorInvalidDeviceCode)
+		return
+	}
+
+	oauthAccessToken, err = issueAccessToken(c.tokenStore, c.config, client.ClientID, deviceCode.UserID, deviceCode.Scope)
+	if err != nil {
+		return
+	}
+
+	err = issueRefreshToken(c.tokenStore, c.config, client, &oauthAccessToken, deviceCode.Scope)
+	return
+}
+
+func generateUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// formatUserCode splits a user code in two halves, so that it is easier to
+// read and type.
+func formatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts user codes typed in lower case, and with or
+// without separators.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
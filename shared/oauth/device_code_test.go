@@ -0,0 +1,216 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDeviceCodeStore struct {
+	deviceCodes   map[string]OauthDeviceCode
+	accessTokens  map[string]OauthAccessToken
+	refreshTokens map[string]OauthRefreshToken
+	verifiedUsers map[string]bool
+}
+
+func newFakeDeviceCodeStore(deviceCodes ...OauthDeviceCode) *fakeDeviceCodeStore {
+	s := &fakeDeviceCodeStore{
+		deviceCodes:   make(map[string]OauthDeviceCode),
+		accessTokens:  make(map[string]OauthAccessToken),
+		refreshTokens: make(map[string]OauthRefreshToken),
+		verifiedUsers: make(map[string]bool),
+	}
+	for _, deviceCode := range deviceCodes {
+		s.deviceCodes[deviceCode.DeviceCode] = deviceCode
+	}
+	return s
+}
+
+func (s *fakeDeviceCodeStore) resolveDeviceCode(deviceCodeHash string) (OauthDeviceCode, error) {
+	deviceCode, ok := s.deviceCodes[deviceCodeHash]
+	if !ok {
+		return deviceCode, errors.New(ErrorInvalidDeviceCode)
+	}
+	return deviceCode, nil
+}
+
+func (s *fakeDeviceCodeStore) updateDeviceCodePoll(deviceCodeHash string, intervalSeconds int64, polledAt time.Time) error {
+	deviceCode := s.deviceCodes[deviceCodeHash]
+	deviceCode.IntervalSeconds = intervalSeconds
+	deviceCode.LastPolledAt = null.TimeFrom(polledAt)
+	s.deviceCodes[deviceCodeHash] = deviceCode
+	return nil
+}
+
+func (s *fakeDeviceCodeStore) consumeDeviceCode(deviceCodeHash string) (bool, error) {
+	deviceCode, ok := s.deviceCodes[deviceCodeHash]
+	if !ok || deviceCode.Status != DeviceCodeStatusApproved {
+		return false, nil
+	}
+
+	delete(s.deviceCodes, deviceCodeHash)
+	return true, nil
+}
+
+func (s *fakeDeviceCodeStore) resolveUserProfileByID(id string) (UserProfile, error) {
+	profile := UserProfile{ID: id}
+	if s.verifiedUsers[id] {
+		profile.EmailVerifiedAt = null.TimeFrom(time.Now())
+	}
+	return profile, nil
+}
+
+func (s *fakeDeviceCodeStore) createAccessToken(accessToken OauthAccessToken) error {
+	s.accessTokens[accessToken.AccessToken] = accessToken
+	return nil
+}
+
+func (s *fakeDeviceCodeStore) createRefreshToken(refreshToken OauthRefreshToken) error {
+	s.refreshTokens[refreshToken.RefreshToken] = refreshToken
+	return nil
+}
+
+func TestDeviceCodeAuthCreate(t *testing.T) {
+	client := OauthClient{ClientID: "client_tv", GrantTypes: string(DeviceCode) + " " + string(RefreshToken)}
+	config := Config{Expiration: 3600, RefreshTokenExpiration: 86400}
+	credential := Credential{ClientID: "client_tv", DeviceCode: "device"}
+	deviceCodeHash := hashAuthorizationCode("device")
+
+	newDeviceCode := func(status DeviceCodeStatus) OauthDeviceCode {
+		deviceCode := OauthDeviceCode{
+			DeviceCode:      deviceCodeHash,
+			UserCode:        "BCDFGHJK",
+			ClientID:        "client_tv",
+			Scope:           null.StringFrom("user"),
+			Status:          status,
+			IntervalSeconds: DefaultDevicePollInterval,
+			Expires:         time.Now().Add(time.Minute),
+		}
+		if status != DeviceCodeStatusPending {
+			deviceCode.UserID = null.StringFrom("550e8400-e29b-41d4-a716-446655440000")
+			deviceCode.DecidedAt = null.TimeFrom(time.Now())
+		}
+		return deviceCode
+	}
+
+	t.Run("Pending", func(t *testing.T) {
+		store := newFakeDeviceCodeStore(newDeviceCode(DeviceCodeStatusPending))
+		c := &DeviceCodeAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, credential)
+		assert.EqualError(t, err, ErrorAuthorizationPending)
+		assert.True(t, store.deviceCodes[deviceCodeHash].LastPolledAt.Valid)
+		assert.Equal(t, DefaultDevicePollInterval, store.deviceCodes[deviceCodeHash].IntervalSeconds)
+	})
+
+	t.Run("Polled after interval", func(t *testing.T) {
+		deviceCode := newDeviceCode(DeviceCodeStatusPending)
+		deviceCode.LastPolledAt = null.TimeFrom(time.Now().Add(-time.Duration(DefaultDevicePollInterval) * time.Second))
+		store := newFakeDeviceCodeStore(deviceCode)
+		c := &DeviceCodeAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, credential)
+		assert.EqualError(t, err, ErrorAuthorizationPending)
+		assert.Equal(t, DefaultDevicePollInterval, store.deviceCodes[deviceCodeHash].IntervalSeconds)
+	})
+
+	t.Run("Slow down", func(t *testing.T) {
+		store := newFakeDeviceCodeStore(newDeviceCode(DeviceCodeStatusPending))
+		c := &DeviceCodeAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, credential)
+		assert.EqualError(t, err, ErrorAuthorizationPending)
+
+		_, err = c.Create(client, credential)
+		assert.EqualError(t, err, ErrorSlowDown)
+		assert.Equal(t, DefaultDevicePollInterval+slowDownIncrement, store.deviceCodes[deviceCodeHash].IntervalSeconds)
+
+		_, err = c.Create(client, credential)
+		assert.EqualError(t, err, ErrorSlowDown)
+		assert.Equal(t, DefaultDevicePollInterval+2*slowDownIncrement, store.deviceCodes[deviceCodeHash].IntervalSeconds)
+	})
+
+	t.Run("Approved", func(t *testing.T) {
+		store := newFakeDeviceCodeStore(newDeviceCode(DeviceCodeStatusApproved))
+		c := &DeviceCodeAuth{tokenStore: store, config: config}
+
+		accessToken, err := c.Create(client, credential)
+		assert.NoError(t, err)
+		assert.Equal(t, null.StringFrom("550e8400-e29b-41d4-a716-446655440000"), accessToken.UserID)
+		assert.Equal(t, null.StringFrom("user"), accessToken.Scope)
+		assert.Contains(t, store.accessTokens, accessToken.AccessToken)
+		assert.Contains(t, store.refreshTokens, hashRefreshToken(accessToken.RefreshToken))
+		assert.NotContains(t, store.deviceCodes, deviceCodeHash)
+
+		_, err = c.Create(client, credential)
+		assert.EqualError(t, err, ErrorInvalidDeviceCode)
+		assert.Len(t, store.accessTokens, 1)
+	})
+
+	t.Run("Approved by unverified user", func(t *testing.T) {
+		store := newFakeDeviceCodeStore(newDeviceCode(DeviceCodeStatusApproved))
+		restricted := config
+		restricted.RestrictUnverified = true
+		c := &DeviceCodeAuth{tokenStore: store, config: restricted}
+
+		_, err := c.Create(client, credential)
+		assert.EqualError(t, err, ErrorUnverifiedUser)
+		assert.Contains(t, store.deviceCodes, deviceCodeHash)
+		assert.Empty(t, store.accessTokens)
+
+		store.verifiedUsers["550e8400-e29b-41d4-a716-446655440000"] = true
+		_, err = c.Create(client, credential)
+		assert.NoError(t, err)
+	})
+
+	tests := []struct {
+		name       string
+		deviceCode func() OauthDeviceCode
+		credential Credential
+		err        string
+	}{
+		{
+			name:       "Denied",
+			deviceCode: func() OauthDeviceCode { return newDeviceCode(DeviceCodeStatusDenied) },
+			credential: credential,
+			err:        ErrorAccessDenied,
+		},
+		{
+			name: "Expired",
+			deviceCode: func() OauthDeviceCode {
+				deviceCode := newDeviceCode(DeviceCodeStatusApproved)
+				deviceCode.Expires = time.Now().Add(-time.Second)
+				return deviceCode
+			},
+			credential: credential,
+			err:        ErrorExpiredToken,
+		},
+		{
+			name:       "Other client",
+			deviceCode: func() OauthDeviceCode { return newDeviceCode(DeviceCodeStatusApproved) },
+			credential: Credential{ClientID: "client_web", DeviceCode: "device"},
+			err:        ErrorInvalidDeviceCode,
+		},
+		{
+			name:       "Unknown device code",
+			deviceCode: func() OauthDeviceCode { return newDeviceCode(DeviceCodeStatusApproved) },
+			credential: Credential{ClientID: "client_tv", DeviceCode: "unknown"},
+			err:        ErrorInvalidDeviceCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeDeviceCodeStore(tt.deviceCode())
+			c := &DeviceCodeAuth{tokenStore: store, config: config}
+
+			_, err := c.Create(OauthClient{ClientID: tt.credential.ClientID, GrantTypes: client.GrantTypes}, tt.credential)
+			assert.EqualError(t, err, tt.err)
+			assert.Empty(t, store.accessTokens)
+			assert.Contains(t, store.deviceCodes, deviceCodeHash)
+		})
+	}
+}
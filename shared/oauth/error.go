@@ -1,11 +1,155 @@
 package oauth
 
+import (
+	"net/http"
+)
+
+const (
+	ErrorEmptyCredential          string = "Credential can't be empty"
+	ErrorClientNotFound           string = "Client does not exist"
+	ErrorUserNotFound             string = "User does not exist"
+	ErrorInvalidPassword          string = "Invalid password credential"
+	ErrorInvalidClient            string = "Invalid client credentials"
+	ErrorInvalidToken             string = "Invalid Token"
+	ErrorTokenTypeMismatch        string = "Token type mismatch"
+	ErrorGenerateAccessToken      string = "Error generating access token"
+	ErrorUnsupportedGrant         string = "Grant type is not supported"
+	ErrorMissingGrantType         string = "Missing grant_type parameter"
+	ErrorMultipleClientAuthMethod string = "Client authenticated with more than one method"
+	ErrorInvalidAuthorizationCode string = "Invalid authorization code"
+	ErrorInvalidCodeVerifier      string = "Invalid PKCE code verifier"
+	ErrorInvalidRedirectURI       string = "Redirect URI is not registered for the client"
+	ErrorMissingCodeChallenge     string = "PKCE with the S256 code challenge method is required"
+	ErrorUnsupportedResponseType  string = "Response type is not supported"
+	ErrorInvalidRefreshToken      string = "Invalid refresh token"
+	ErrorInvalidScope             string = "Requested scope exceeds the granted scope"
+	ErrorScopeNotAllowed          string = "Requested scope is not allowed for the client"
+	ErrorUnauthorizedGrantType    string = "Client is not allowed to use this grant type"
+	ErrorClientNotAllowed         string = "Client is not allowed to obtain tokens"
+	ErrorInvalidDeviceCode        string = "Invalid device code"
+	ErrorInvalidUserCode          string = "Invalid or expired user code"
+	ErrorAuthorizationPending     string = "The user has not yet decided on the authorization request"
+	ErrorSlowDown                 string = "Polling too frequently, the interval has been increased"
+	ErrorAccessDenied             string = "The user denied the authorization request"
+	ErrorExpiredToken             string = "The device code has expired"
+	ErrorPublicClientNotAllowed   string = "Public clients cannot use this grant type"
+	ErrorUnsupportedTokenType     string = "Unsupported token type"
+	ErrorInvalidSubjectToken      string = "Invalid subject token"
+	ErrorInvalidAudience          string = "Exactly one audience is required"
+	ErrorAudienceNotAllowed       string = "Audience is not allowed"
+	ErrorPublicClientIntrospect   string = "Public clients cannot introspect tokens"
+	ErrorUnverifiedUser           string = "The email address of the user is not verified"
+)
+
+// Error codes of RFC 6749, section 5.2.
+const (
+	ErrorCodeInvalidRequest       string = "invalid_request"
+	ErrorCodeInvalidClient        string = "invalid_client"
+	ErrorCodeInvalidGrant         string = "invalid_grant"
+	ErrorCodeUnauthorizedClient   string = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType string = "unsupported_grant_type"
+	ErrorCodeInvalidScope         string = "invalid_scope"
+	ErrorCodeServerError          string = "server_error"
+)
+
+// Additional error codes of the authorization endpoint, RFC 6749, section 4.1.2.1.
+const (
+	ErrorCodeAccessDenied            string = "access_denied"
+	ErrorCodeUnsupportedResponseType string = "unsupported_response_type"
+)
+
+// Additional error codes of the device authorization grant, RFC 8628, section 3.5.
 const (
-	ErrorEmptyCredential     string = "Credential can't be empty"
-	ErrorClientNotFound      string = "Client does not exist"
-	ErrorInvalidPassword     string = "Invalid password credential"
-	ErrorInvalidClient       string = "Invalid client credentials"
-	ErrorInvalidToken        string = "Invalid Token"
-	ErrorTokenTypeMismatch   string = "Token type mismatch"
-	ErrorGenerateAccessToken string = "Error generating access token"
+	ErrorCodeAuthorizationPending string = "authorization_pending"
+	ErrorCodeSlowDown             string = "slow_down"
+	ErrorCodeExpiredToken         string = "expired_token"
 )
+
+// Additional error code of the token exchange grant, RFC 8693, section 2.2.2.
+const (
+	ErrorCodeInvalidTarget string = "invalid_target"
+)
+
+// errorCodes maps the error messages of this package to their RFC 6749 code.
+var errorCodes = map[string]string{
+	ErrorEmptyCredential:          ErrorCodeInvalidRequest,
+	ErrorMissingGrantType:         ErrorCodeInvalidRequest,
+	ErrorMultipleClientAuthMethod: ErrorCodeInvalidRequest,
+	ErrorClientNotFound:           ErrorCodeInvalidClient,
+	ErrorInvalidClient:            ErrorCodeInvalidClient,
+	ErrorUserNotFound:             ErrorCodeInvalidGrant,
+	ErrorInvalidPassword:          ErrorCodeInvalidGrant,
+	ErrorUnsupportedGrant:         ErrorCodeUnsupportedGrantType,
+	ErrorInvalidAuthorizationCode: ErrorCodeInvalidGrant,
+	ErrorInvalidCodeVerifier:      ErrorCodeInvalidGrant,
+	ErrorInvalidRedirectURI:       ErrorCodeInvalidRequest,
+	ErrorMissingCodeChallenge:     ErrorCodeInvalidRequest,
+	ErrorUnsupportedResponseType:  ErrorCodeUnsupportedResponseType,
+	ErrorInvalidRefreshToken:      ErrorCodeInvalidGrant,
+	ErrorInvalidScope:             ErrorCodeInvalidScope,
+	ErrorScopeNotAllowed:          ErrorCodeInvalidScope,
+	ErrorUnauthorizedGrantType:    ErrorCodeUnauthorizedClient,
+	ErrorClientNotAllowed:         ErrorCodeUnauthorizedClient,
+	ErrorInvalidDeviceCode:        ErrorCodeInvalidGrant,
+	ErrorInvalidUserCode:          ErrorCodeInvalidRequest,
+	ErrorAuthorizationPending:     ErrorCodeAuthorizationPending,
+	ErrorSlowDown:                 ErrorCodeSlowDown,
+	ErrorAccessDenied:             ErrorCodeAccessDenied,
+	ErrorExpiredToken:             ErrorCodeExpiredToken,
+	ErrorPublicClientNotAllowed:   ErrorCodeUnauthorizedClient,
+	ErrorUnsupportedTokenType:     ErrorCodeInvalidRequest,
+	ErrorInvalidSubjectToken:      ErrorCodeInvalidGrant,
+	ErrorInvalidAudience:          ErrorCodeInvalidTarget,
+	ErrorAudienceNotAllowed:       ErrorCodeInvalidTarget,
+	ErrorPublicClientIntrospect:   ErrorCodeInvalidClient,
+	ErrorUnverifiedUser:           ErrorCodeInvalidGrant,
+}
+
+// Error is an OAuth 2.0 error response.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewError creates an Error with the given code and description.
+func NewError(code string, description string) *Error {
+	return &Error{
+		Code:        code,
+		Description: description,
+	}
+}
+
+// ToError converts an error returned by this package into an Error. Errors
+// that are not one of the messages of this package, such as database errors,
+// become a server_error whose description is not exposed.
+func ToError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	code, ok := errorCodes[err.Error()]
+	if !ok {
+		return NewError(ErrorCodeServerError, "")
+	}
+
+	return NewError(code, err.Error())
+}
+
+// Error returns the code and description in a formatted string.
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// StatusCode returns the HTTP status code the error is sent with.
+func (e *Error) StatusCode() int {
+	switch e.Code {
+	case ErrorCodeInvalidClient:
+		return http.StatusUnauthorized
+	case ErrorCodeServerError:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
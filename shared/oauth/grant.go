@@ -1,25 +1,130 @@
 package oauth
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// AuthorizationMethod issues an access token for one grant type. The client
+// has already been authenticated and checked against its registered grant
+// types when Create is called.
 type AuthorizationMethod interface {
-	Create(credential Credential) (OauthAccessToken, error)
+	Create(client OauthClient, credential Credential) (OauthAccessToken, error)
+}
+
+// GrantRegistry holds the grant types the token endpoint supports. Grants are
+// registered once at wiring time. A registered grant can be disabled through
+// App.OAuth.DisabledGrantTypes, after which it is treated like an unknown one.
+//
+// To add a custom grant, replace ProvideGrantRegistry in wire.go with a
+// provider that calls it, and then registers the custom AuthorizationMethod.
+// Custom grants read their parameters from Credential.Parameters, and issue
+// tokens with IssueAccessToken.
+type GrantRegistry struct {
+	methods  map[GrantType]AuthorizationMethod
+	disabled map[GrantType]bool
+}
+
+// NewGrantRegistry creates an empty GrantRegistry, in which the given grant
+// types are disabled.
+func NewGrantRegistry(disabled ...GrantType) *GrantRegistry {
+	r := &GrantRegistry{
+		methods:  make(map[GrantType]AuthorizationMethod),
+		disabled: make(map[GrantType]bool),
+	}
+
+	for _, grantType := range disabled {
+		r.disabled[grantType] = true
+	}
+
+	return r
+}
+
+// ProvideGrantRegistry is the provider for GrantRegistry. It registers the
+// grants implemented by this package. The password grant authenticates users
+// with the authenticator.
+func ProvideGrantRegistry(config *configs.Config, tokenStore TokenStore, oauthConfig Config, jwtService *shared.JWTService, denylist shared.TokenDenylist, authenticator UserAuthenticator) *GrantRegistry {
+	var disabled []GrantType
+	for _, grantType := range config.App.OAuth.DisabledGrantTypes {
+		disabled = append(disabled, GrantType(grantType))
+	}
+
+	// Users can't be sent anywhere to enter a user code without a
+	// verification page.
+	if oauthConfig.DeviceVerificationURI == "" {
+		log.Warn().Msg("OAuth device code grant disabled, App.OAuth.DeviceVerificationURI is not configured.")
+		disabled = append(disabled, DeviceCode)
+	}
+
+	r := NewGrantRegistry(disabled...)
+	r.Register(ClientCredentials, &ClientCredentialsAuth{tokenStore: tokenStore, config: oauthConfig})
+	r.Register(Password, &PasswordAuth{tokenStore: tokenStore, config: oauthConfig, authenticator: authenticator})
+	r.Register(AuthorizationCode, &AuthorizationCodeAuth{tokenStore: &tokenStore, config: oauthConfig, jwtService: jwtService})
+	r.Register(RefreshToken, &RefreshTokenAuth{tokenStore: &tokenStore, config: oauthConfig})
+	r.Register(DeviceCode, &DeviceCodeAuth{tokenStore: &tokenStore, config: oauthConfig})
+	r.Register(TokenExchange, &TokenExchangeAuth{tokenStore: tokenStore, config: oauthConfig, jwtService: jwtService, denylist: denylist})
+
+	return r
 }
 
-type Grant struct {
-	TokenStore TokenStore
-	Config     Config
+// Register adds the implementation of a grant type. Like http.Handle, it
+// panics when the grant type is empty or already registered, or when the
+// method is nil, as these are programming errors.
+func (r *GrantRegistry) Register(grantType GrantType, method AuthorizationMethod) {
+	if grantType == "" {
+		panic("oauth: empty grant type")
+	}
+
+	if method == nil {
+		panic(fmt.Sprintf("oauth: nil authorization method for grant type %s", grantType))
+	}
+
+	if _, exists := r.methods[grantType]; exists {
+		panic(fmt.Sprintf("oauth: grant type %s registered twice", grantType))
+	}
+
+	r.methods[grantType] = method
 }
 
-func NewGrant(tokenStore TokenStore, config Config) *Grant {
-	return &Grant{
-		TokenStore: tokenStore,
-		Config:     config,
+// IsRegistered checks whether a grant type has been registered, regardless of
+// whether it is enabled. Clients can be registered for such grant types.
+func (r *GrantRegistry) IsRegistered(grantType GrantType) bool {
+	_, ok := r.methods[grantType]
+	return ok
+}
+
+// IsEnabled checks whether a grant type has been registered and is not
+// disabled.
+func (r *GrantRegistry) IsEnabled(grantType GrantType) bool {
+	return r.IsRegistered(grantType) && !r.disabled[grantType]
+}
+
+// GrantTypes returns the enabled grant types in alphabetical order.
+func (r *GrantRegistry) GrantTypes() []GrantType {
+	grantTypes := []GrantType{}
+	for grantType := range r.methods {
+		if r.IsEnabled(grantType) {
+			grantTypes = append(grantTypes, grantType)
+		}
 	}
+
+	sort.Slice(grantTypes, func(a, b int) bool {
+		return grantTypes[a] < grantTypes[b]
+	})
+
+	return grantTypes
 }
 
-func (g *Grant) Create(credential Credential) (OauthAccessToken, error) {
-	authMap := make(map[GrantType]AuthorizationMethod)
-	authMap[ClientCredentials] = &ClientCredentialsAuth{tokenStore: g.TokenStore, config: g.Config}
-	authMap[Password] = &PasswordAuth{tokenStore: g.TokenStore, config: g.Config}
+// Resolve returns the implementation of an enabled grant type.
+func (r *GrantRegistry) Resolve(grantType GrantType) (AuthorizationMethod, error) {
+	if !r.IsEnabled(grantType) {
+		return nil, errors.New(ErrorUnsupportedGrant)
+	}
 
-	return authMap[credential.GrantType].Create(credential)
+	return r.methods[grantType], nil
 }
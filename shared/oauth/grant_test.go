@@ -0,0 +1,48 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubGrant struct{}
+
+func (stubGrant) Create(client oauth.OauthClient, credential oauth.Credential) (oauth.OauthAccessToken, error) {
+	return oauth.OauthAccessToken{ClientID: client.ClientID}, nil
+}
+
+func TestGrantRegistry(t *testing.T) {
+	registry := oauth.NewGrantRegistry(oauth.Password)
+	registry.Register(oauth.ClientCredentials, stubGrant{})
+	registry.Register(oauth.Password, stubGrant{})
+
+	t.Run("Resolve", func(t *testing.T) {
+		method, err := registry.Resolve(oauth.ClientCredentials)
+		assert.NoError(t, err)
+		assert.NotNil(t, method)
+	})
+
+	t.Run("Unknown grant", func(t *testing.T) {
+		_, err := registry.Resolve("urn:example:unknown")
+		assert.Equal(t, oauth.ErrorCodeUnsupportedGrantType, oauth.ToError(err).Code)
+	})
+
+	t.Run("Disabled grant", func(t *testing.T) {
+		_, err := registry.Resolve(oauth.Password)
+		assert.Equal(t, oauth.ErrorCodeUnsupportedGrantType, oauth.ToError(err).Code)
+		assert.True(t, registry.IsRegistered(oauth.Password))
+		assert.False(t, registry.IsEnabled(oauth.Password))
+	})
+
+	t.Run("Grant types", func(t *testing.T) {
+		assert.Equal(t, []oauth.GrantType{oauth.ClientCredentials}, registry.GrantTypes())
+	})
+
+	t.Run("Duplicate registration", func(t *testing.T) {
+		assert.Panics(t, func() {
+			registry.Register(oauth.ClientCredentials, stubGrant{})
+		})
+	})
+}
@@ -0,0 +1,244 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+)
+
+// Token type hints of RFC 7009, section 2.1.
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse is the response of the introspection endpoint, see
+// RFC 7662, section 2.2. An inactive token carries no other member.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	// Actor is the client that uses a delegated JWT, see RFC 8693, section
+	// 4.1.
+	Actor *shared.Actor `json:"act,omitempty"`
+}
+
+// Introspect reports whether an opaque access token, refresh token or JWT is
+// active, on behalf of an authenticated client. Public clients are refused, as
+// anyone can present their client ID.
+func (t *Token) Introspect(credential Credential, token string, tokenTypeHint string) (response IntrospectionResponse, err error) {
+	client, err := t.authenticateClient(credential)
+	if err != nil {
+		return
+	}
+
+	if client.ClientSecret == "" {
+		err = errors.New(ErrorPublicClientIntrospect)
+		return
+	}
+
+	if token == "" {
+		return
+	}
+
+	if isJWT(token) {
+		return t.introspectJWT(token)
+	}
+
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		response, err = t.introspectRefreshToken(token)
+		if err != nil || response.Active {
+			return
+		}
+		return t.introspectAccessToken(token)
+	}
+
+	response, err = t.introspectAccessToken(token)
+	if err != nil || response.Active {
+		return
+	}
+	return t.introspectRefreshToken(token)
+}
+
+// Revoke revokes an opaque access token, refresh token or JWT on behalf of an
+// authenticated client. Revoking a refresh token revokes its whole family.
+// Unknown tokens and tokens issued to other clients are ignored, as required
+// by RFC 7009, section 2.2, so that callers cannot probe for valid tokens.
+// Public clients may revoke tokens too, which is safe as they can only revoke
+// their own.
+func (t *Token) Revoke(credential Credential, token string, tokenTypeHint string) error {
+	client, err := t.authenticateClient(credential)
+	if err != nil {
+		return err
+	}
+
+	if token == "" {
+		return nil
+	}
+
+	if isJWT(token) {
+		return t.revokeJWT(client, token)
+	}
+
+	accessToken, err := NewParser(t.tokenRepository).Parse(string(Bearer) + " " + token)
+	switch {
+	case err == nil:
+		if accessToken.ClientID != client.ClientID {
+			return nil
+		}
+		return t.tokenRepository.deleteAccessToken(accessToken.AccessToken)
+	case err.Error() != ErrorClientNotFound:
+		return err
+	}
+
+	refreshToken, err := t.tokenRepository.resolveRefreshToken(hashRefreshToken(token))
+	switch {
+	case err == nil:
+		if refreshToken.ClientID != client.ClientID {
+			return nil
+		}
+		return t.tokenRepository.revokeRefreshTokenFamily(refreshToken.FamilyID, time.Now())
+	case err.Error() != ErrorInvalidRefreshToken:
+		return err
+	}
+
+	return nil
+}
+
+// authenticateClient resolves the client and verifies its credentials.
+func (t *Token) authenticateClient(credential Credential) (client OauthClient, err error) {
+	if !t.ClientScopeAllowed(credential.ClientID) {
+		err = errors.New(ErrorClientNotAllowed)
+		return
+	}
+
+	client, err = t.tokenRepository.resolveClientByClientID(credential.ClientID)
+	if err != nil {
+		return
+	}
+
+	if !client.VerifyClient(credential) {
+		err = errors.New(ErrorInvalidClient)
+		return
+	}
+
+	return
+}
+
+func (t *Token) introspectAccessToken(token string) (response IntrospectionResponse, err error) {
+	accessToken, err := NewParser(t.tokenRepository).Parse(string(Bearer) + " " + token)
+	if err != nil {
+		if err.Error() == ErrorClientNotFound {
+			err = nil
+		}
+		return
+	}
+
+	if !accessToken.VerifyExpireIn() {
+		return
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     accessToken.Scope.String,
+		ClientID:  accessToken.ClientID,
+		TokenType: string(Bearer),
+		Subject:   accessToken.UserID.String,
+		ExpiresAt: accessToken.Expires.Unix(),
+	}, nil
+}
+
+func (t *Token) introspectRefreshToken(token string) (response IntrospectionResponse, err error) {
+	refreshToken, err := t.tokenRepository.resolveRefreshToken(hashRefreshToken(token))
+	if err != nil {
+		if err.Error() == ErrorInvalidRefreshToken {
+			err = nil
+		}
+		return
+	}
+
+	if refreshToken.RevokedAt.Valid || refreshToken.IsExpired() {
+		return
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     refreshToken.Scope.String,
+		ClientID:  refreshToken.ClientID,
+		Subject:   refreshToken.UserID.String,
+		ExpiresAt: refreshToken.Expires.Unix(),
+		IssuedAt:  refreshToken.CreatedAt.Unix(),
+	}, nil
+}
+
+func (t *Token) introspectJWT(token string) (response IntrospectionResponse, err error) {
+	if t.jwtService == nil {
+		return
+	}
+
+	claims, err := t.jwtService.ValidateJWT(token)
+	if err != nil {
+		// An invalid or expired JWT is simply not active.
+		return IntrospectionResponse{}, nil
+	}
+
+	if t.denylist != nil {
+		revoked, err := t.denylist.IsRevoked(claims)
+		if err != nil || revoked {
+			return IntrospectionResponse{}, err
+		}
+	}
+
+	response = IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		Username:  claims.Username,
+		TokenType: string(Bearer),
+		Subject:   claims.UserID.String(),
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Issuer:    claims.Issuer,
+		Actor:     claims.Actor,
+	}
+
+	// A delegated JWT was issued to the client that acts on it.
+	if claims.Actor != nil {
+		response.ClientID = claims.Actor.Subject
+	}
+
+	return response, nil
+}
+
+// revokeJWT revokes a delegated JWT that was issued to the client, see RFC
+// 7009, section 2.1. JWTs issued to users directly are not bound to a client,
+// and are revoked by logging out instead.
+func (t *Token) revokeJWT(client OauthClient, token string) error {
+	if t.jwtService == nil || t.denylist == nil {
+		return nil
+	}
+
+	claims, err := t.jwtService.ValidateJWT(token)
+	if err != nil {
+		// There is nothing to revoke in a token that is not valid anyway.
+		return nil
+	}
+
+	if claims.Actor == nil || claims.Actor.Subject != client.ClientID {
+		return nil
+	}
+
+	return t.denylist.Revoke(claims)
+}
+
+// isJWT tells JWTs apart from the opaque tokens, which never contain dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -1,8 +1,9 @@
 package oauth
 
 import (
-	"golang.org/x/crypto/bcrypt"
-	"strconv"
+	"errors"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/guregu/null"
@@ -14,12 +15,6 @@ const (
 	Bearer TokenType = "Bearer"
 )
 
-var scope = struct {
-	User string
-}{
-	"user",
-}
-
 // Credential is
 type Credential struct {
 	GrantType    GrantType
@@ -27,25 +22,39 @@ type Credential struct {
 	ClientSecret string
 	Username     string
 	Password     string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	DeviceCode   string
+	Scope        string
+	// SubjectToken, SubjectTokenType, RequestedTokenType and Audience are
+	// the parameters of the token exchange grant.
+	SubjectToken       string
+	SubjectTokenType   string
+	RequestedTokenType string
+	Audience           string
+	// Parameters are all the parameters of the token request, for custom
+	// grants to read their own.
+	Parameters url.Values
 }
 
 type OauthAccessToken struct {
-	AccessToken string      `json:"accessToken" db:"access_token"`
-	ClientID    string      `json:"clientId" db:"client_id"`
-	UserID      null.String `json:"userId" db:"user_id"`
-	Expires     time.Time   `json:"expires" db:"expires"`
-	Scope       null.String `json:"scope" db:"scope"`
+	AccessToken  string      `json:"accessToken" db:"access_token"`
+	ClientID     string      `json:"clientId" db:"client_id"`
+	UserID       null.String `json:"userId" db:"user_id"`
+	Expires      time.Time   `json:"expires" db:"expires"`
+	Scope        null.String `json:"scope" db:"scope"`
+	IDToken      string      `json:"-" db:"-"`
+	RefreshToken string      `json:"-" db:"-"`
+	// IssuedTokenType is only set by the token exchange grant, whose tokens
+	// are JWTs that are not stored.
+	IssuedTokenType string `json:"-" db:"-"`
 }
 
-func (o *OauthAccessToken) Generate(accessToken string, clientID string, userID *int, withScope bool, config Config) OauthAccessToken {
-	if userID != nil {
-		o.UserID = null.StringFrom(strconv.Itoa(*userID))
-	}
-
-	if withScope {
-		o.Scope = null.StringFrom(scope.User)
-	}
-
+func (o *OauthAccessToken) Generate(accessToken string, clientID string, userID null.String, scope null.String, config Config) OauthAccessToken {
+	o.UserID = userID
+	o.Scope = scope
 	o.ClientID = clientID
 	o.AccessToken = accessToken
 	o.Expires = time.Now().Add(time.Second * time.Duration(config.Expiration))
@@ -62,59 +71,231 @@ func (o *OauthAccessToken) VerifyExpireIn() bool {
 	return true
 }
 
+// VerifyUserLoggedIn checks whether the token was issued on behalf of a user,
+// rather than to a client acting on its own behalf.
 func (o *OauthAccessToken) VerifyUserLoggedIn() bool {
-	if o.UserID.Valid && !o.Scope.Valid {
-		return true
+	return o.UserID.Valid
+}
+
+// Scopes returns the scopes the token was granted.
+func (o *OauthAccessToken) Scopes() []string {
+	return strings.Fields(o.Scope.String)
+}
+
+// HasScopes checks whether the token was granted all of the given scopes.
+func (o *OauthAccessToken) HasScopes(scopes ...string) bool {
+	granted := make(map[string]bool)
+	for _, s := range o.Scopes() {
+		granted[s] = true
 	}
-	return false
+
+	for _, s := range scopes {
+		if !granted[s] {
+			return false
+		}
+	}
+	return true
 }
 
 func (o *OauthAccessToken) toCreateTokenResponse() *TokenResponse {
 	return &TokenResponse{
-		AccessToken: o.AccessToken,
-		ExpiresIn:   o.Expires,
-		TokenType:   string(Bearer),
-		Scope:       scope.User,
+		AccessToken:     o.AccessToken,
+		ExpiresIn:       int64(time.Until(o.Expires).Seconds()),
+		TokenType:       string(Bearer),
+		Scope:           o.Scope.String,
+		RefreshToken:    o.RefreshToken,
+		IDToken:         o.IDToken,
+		IssuedTokenType: o.IssuedTokenType,
 	}
 }
 
+// OauthRefreshToken is a refresh token issued together with an access token.
+// Only the SHA-256 hash of the token is stored. Tokens rotated from the same
+// original grant share a family ID.
+type OauthRefreshToken struct {
+	RefreshToken string      `db:"refresh_token"`
+	FamilyID     string      `db:"family_id"`
+	ClientID     string      `db:"client_id"`
+	UserID       null.String `db:"user_id"`
+	Scope        null.String `db:"scope"`
+	Expires      time.Time   `db:"expires"`
+	CreatedAt    time.Time   `db:"created_at"`
+	RevokedAt    null.Time   `db:"revoked_at"`
+	ReplacedBy   null.String `db:"replaced_by"`
+}
+
+// IsExpired checks whether the refresh token can no longer be used.
+func (o *OauthRefreshToken) IsExpired() bool {
+	return time.Now().After(o.Expires)
+}
+
 type OauthClient struct {
-	ClientID     string `json:"clientId" db:"client_id"`
-	ClientSecret string `json:"clientSecret" db:"client_secret"`
-	RedirectURI  string `json:"redirectUri" db:"redirect_uri"`
-	GrantTypes   string `json:"grantTypes" db:"grant_types"`
+	ClientID     string      `json:"clientId" db:"client_id"`
+	ClientSecret string      `json:"clientSecret" db:"client_secret"`
+	RedirectURI  string      `json:"redirectUri" db:"redirect_uri"`
+	GrantTypes   string      `json:"grantTypes" db:"grant_types"`
+	Scope        null.String `json:"scope" db:"scope"`
+}
+
+// RedirectURIAllowed checks whether the URI exactly matches one of the
+// space-delimited redirect URIs registered for the client.
+func (o *OauthClient) RedirectURIAllowed(redirectURI string) bool {
+	for _, registered := range strings.Fields(o.RedirectURI) {
+		if registered == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantTypeAllowed checks whether the grant type is one of the
+// space-delimited grant types registered for the client.
+func (o *OauthClient) GrantTypeAllowed(grantType GrantType) bool {
+	for _, registered := range strings.Fields(o.GrantTypes) {
+		if registered == string(grantType) {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantScope intersects the requested space-delimited scopes with the ones
+// registered for the client. A client that requests no scope is granted all
+// of its registered scopes.
+func (o *OauthClient) GrantScope(requested string) (null.String, error) {
+	requestedScopes := strings.Fields(requested)
+	if len(requestedScopes) == 0 {
+		return o.RestrictScope(o.Scope), nil
+	}
+
+	granted := o.RestrictScope(null.StringFrom(requested))
+	if !granted.Valid {
+		return null.String{}, errors.New(ErrorScopeNotAllowed)
+	}
+
+	return granted, nil
 }
 
+// RestrictScope drops the scopes that are not registered for the client.
+func (o *OauthClient) RestrictScope(scope null.String) null.String {
+	allowed := make(map[string]bool)
+	for _, s := range strings.Fields(o.Scope.String) {
+		allowed[s] = true
+	}
+
+	var granted []string
+	for _, s := range strings.Fields(scope.String) {
+		if allowed[s] {
+			granted = append(granted, s)
+		}
+	}
+
+	if len(granted) == 0 {
+		return null.String{}
+	}
+
+	return null.StringFrom(strings.Join(granted, " "))
+}
+
+// AllowsOpenID checks whether the client is registered for the openid scope,
+// and may thus receive ID tokens.
+func (o *OauthClient) AllowsOpenID() bool {
+	return o.RestrictScope(null.StringFrom(ScopeOpenID)).Valid
+}
+
+// VerifyClient checks the credential against the client. A public client has
+// no secret, and must not present one.
 func (o *OauthClient) VerifyClient(credential Credential) bool {
 	if o.ClientID != credential.ClientID {
 		return false
 	}
 
-	if o.ClientSecret != credential.ClientSecret {
-		return false
+	if o.ClientSecret == "" {
+		return credential.ClientSecret == ""
 	}
 
-	return true
+	return compareClientSecret(o.ClientSecret, credential.ClientSecret)
+}
+
+// OauthAuthorizationCode is an authorization code issued by the authorization
+// endpoint. Only the SHA-256 hash of the code is stored.
+type OauthAuthorizationCode struct {
+	Code                string      `db:"code"`
+	ClientID            string      `db:"client_id"`
+	UserID              string      `db:"user_id"`
+	RedirectURI         string      `db:"redirect_uri"`
+	Scope               null.String `db:"scope"`
+	Nonce               null.String `db:"nonce"`
+	CodeChallenge       string      `db:"code_challenge"`
+	CodeChallengeMethod string      `db:"code_challenge_method"`
+	AuthTime            null.Time   `db:"auth_time"`
+	Expires             time.Time   `db:"expires"`
+	UsedAt              null.Time   `db:"used_at"`
+	AccessToken         null.String `db:"access_token"`
+	// RefreshTokenFamilyID is the family of the refresh token issued for the
+	// code, if any.
+	RefreshTokenFamilyID null.String `db:"refresh_token_family_id"`
+	CreatedAt            time.Time   `db:"created_at"`
+}
+
+// IsExpired checks whether the code can no longer be exchanged.
+func (o *OauthAuthorizationCode) IsExpired() bool {
+	return time.Now().After(o.Expires)
+}
+
+// HasScope checks whether the code was issued for the given scope.
+func (o *OauthAuthorizationCode) HasScope(s string) bool {
+	for _, granted := range strings.Fields(o.Scope.String) {
+		if granted == s {
+			return true
+		}
+	}
+	return false
 }
 
+// TokenResponse is the successful access token response of RFC 6749, section 5.1.
 type TokenResponse struct {
-	AccessToken string
-	ExpiresIn   time.Time
-	TokenType   string
-	Scope       string
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType is only returned by the token exchange grant.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
-type User struct {
-	ID       int    `json:"id" db:"id"`
-	Username string `json:"username" db:"username"`
-	Password string `json:"password" db:"password"`
+// UserProfile is the part of a user that is disclosed in ID tokens.
+type UserProfile struct {
+	ID              string    `db:"id"`
+	Name            string    `db:"name"`
+	Username        string    `db:"username"`
+	Email           string    `db:"email"`
+	EmailVerifiedAt null.Time `db:"email_verified_at"`
 }
 
-func (u *User) ValidCredential(credential Credential) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(credential.Password))
+// userProfileResolver is the part of TokenStore that resolves users.
+type userProfileResolver interface {
+	resolveUserProfileByID(id string) (UserProfile, error)
+}
+
+// verifyUserEmail checks that a token may be issued on behalf of the user.
+// When Config.RestrictUnverified is set, users must have verified their email
+// address: unlike the JWTs of the user domain, OAuth tokens are not limited
+// to a verification scope, so none are issued instead.
+func verifyUserEmail(store userProfileResolver, config Config, userID string) error {
+	if !config.RestrictUnverified {
+		return nil
+	}
+
+	profile, err := store.resolveUserProfileByID(userID)
 	if err != nil {
-		return false
+		return err
 	}
 
-	return true
+	if !profile.EmailVerifiedAt.Valid {
+		return errors.New(ErrorUnverifiedUser)
+	}
+
+	return nil
 }
@@ -0,0 +1,83 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOauthClient(t *testing.T) {
+	client := oauth.OauthClient{
+		ClientID:    "client_web",
+		RedirectURI: "https://evermos.com/ https://evermos.com/callback",
+		GrantTypes:  "client_credentials password refresh_token",
+		Scope:       null.StringFrom("user openid email"),
+	}
+
+	t.Run("GrantTypeAllowed", func(t *testing.T) {
+		assert.True(t, client.GrantTypeAllowed(oauth.Password))
+		assert.False(t, client.GrantTypeAllowed(oauth.AuthorizationCode))
+	})
+
+	t.Run("RedirectURIAllowed", func(t *testing.T) {
+		assert.True(t, client.RedirectURIAllowed("https://evermos.com/callback"))
+		assert.False(t, client.RedirectURIAllowed("https://evermos.com/callback/"))
+		assert.False(t, client.RedirectURIAllowed(""))
+	})
+
+	t.Run("GrantScope", func(t *testing.T) {
+		scope, err := client.GrantScope("")
+		assert.NoError(t, err)
+		assert.Equal(t, "user openid email", scope.String)
+
+		scope, err = client.GrantScope("openid admin email")
+		assert.NoError(t, err)
+		assert.Equal(t, "openid email", scope.String)
+
+		_, err = client.GrantScope("admin")
+		assert.EqualError(t, err, oauth.ErrorScopeNotAllowed)
+	})
+
+	t.Run("VerifyClient", func(t *testing.T) {
+		credential := oauth.Credential{ClientID: "client_web", ClientSecret: "secret"}
+		// The SHA-256 hash of "secret", as migrated by the client management
+		// migration.
+		legacy := "sha256$2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+		hashed, err := oauth.HashClientSecret("secret")
+		assert.NoError(t, err)
+		stretched, err := oauth.StretchLegacyClientSecretHash(legacy)
+		assert.NoError(t, err)
+
+		for _, hash := range []string{hashed, stretched} {
+			client := oauth.OauthClient{ClientID: "client_web", ClientSecret: hash}
+			assert.True(t, client.VerifyClient(credential))
+			assert.False(t, client.VerifyClient(oauth.Credential{ClientID: "client_web", ClientSecret: "other"}))
+			assert.False(t, client.VerifyClient(oauth.Credential{ClientID: "client_web"}))
+		}
+
+		assert.True(t, oauth.IsLegacyClientSecretHash(legacy))
+		assert.False(t, oauth.IsLegacyClientSecretHash(stretched))
+		assert.False(t, (&oauth.OauthClient{ClientID: "client_web", ClientSecret: legacy}).VerifyClient(credential))
+		assert.True(t, (&oauth.OauthClient{ClientID: "client_web"}).VerifyClient(oauth.Credential{ClientID: "client_web"}))
+	})
+}
+
+func TestOauthRefreshToken(t *testing.T) {
+	refreshToken := oauth.OauthRefreshToken{Scope: null.StringFrom("openid email")}
+
+	t.Run("NarrowScope", func(t *testing.T) {
+		scope, err := refreshToken.NarrowScope("")
+		assert.NoError(t, err)
+		assert.Equal(t, "openid email", scope.String)
+
+		scope, err = refreshToken.NarrowScope("email")
+		assert.NoError(t, err)
+		assert.Equal(t, "email", scope.String)
+
+		_, err = refreshToken.NarrowScope("email profile")
+		assert.EqualError(t, err, oauth.ErrorInvalidScope)
+	})
+}
@@ -2,43 +2,61 @@ package oauth
 
 import (
 	"errors"
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/guregu/null"
 )
 
+// UserAuthenticator authenticates the resource owner of the password grant.
+// It is implemented by the user domain.
+type UserAuthenticator interface {
+	// AuthenticateUser verifies the password of the user with the given
+	// username or email, and returns the UUID of the user. A user that does
+	// not exist or a wrong password is reported as a failure.NotFound or a
+	// failure.Unauthorized error.
+	AuthenticateUser(usernameOrEmail string, password string) (userID string, err error)
+}
+
 type PasswordAuth struct {
-	tokenStore TokenStore
-	config     Config
+	tokenStore    TokenStore
+	config        Config
+	authenticator UserAuthenticator
 }
 
-func (c *PasswordAuth) Create(credential Credential) (oauthAccessToken OauthAccessToken, err error) {
-	client, err := c.tokenStore.resolveClientByClientID(credential.ClientID)
+func (c *PasswordAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	scope, err := client.GrantScope(credential.Scope)
 	if err != nil {
 		return
 	}
 
-	if !client.VerifyClient(credential) {
-		err = errors.New(ErrorInvalidClient)
+	if credential.Username == "" || credential.Password == "" {
+		err = errors.New(ErrorEmptyCredential)
 		return
 	}
 
-	user, err := c.tokenStore.resolveByTelephoneOrEmail(credential.Username)
+	userID, err := c.authenticator.AuthenticateUser(credential.Username, credential.Password)
 	if err != nil {
+		// Unknown users and wrong passwords are reported alike, so that the
+		// grant can't be used to find out which users exist.
+		switch failure.GetCode(err) {
+		case http.StatusNotFound, http.StatusUnauthorized, http.StatusBadRequest:
+			err = errors.New(ErrorInvalidPassword)
+		}
 		return
 	}
 
-	if !user.ValidCredential(credential) {
-		err = errors.New(ErrorInvalidPassword)
+	err = verifyUserEmail(&c.tokenStore, c.config, userID)
+	if err != nil {
 		return
 	}
 
-	accessToken, err := generateAccessToken()
+	oauthAccessToken, err = IssueAccessToken(c.tokenStore, c.config, credential.ClientID, null.StringFrom(userID), scope)
 	if err != nil {
-		err = errors.New(ErrorGenerateAccessToken)
 		return
 	}
 
-	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, credential.ClientID, &user.ID, false, c.config)
-
-	err = c.tokenStore.createAccessToken(oauthAccessToken)
+	err = issueRefreshToken(&c.tokenStore, c.config, client, &oauthAccessToken, scope)
 	if err != nil {
 		return
 	}
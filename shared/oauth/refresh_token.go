@@ -0,0 +1,202 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRefreshTokenExpiration is the lifetime in seconds of a refresh token
+// when none is configured.
+const DefaultRefreshTokenExpiration int64 = 30 * 24 * 60 * 60
+
+// refreshTokenStore is the part of TokenStore that RefreshTokenAuth uses.
+type refreshTokenStore interface {
+	resolveRefreshToken(refreshTokenHash string) (OauthRefreshToken, error)
+	rotateRefreshToken(currentHash string, next OauthRefreshToken) (bool, error)
+	revokeRefreshTokenFamily(familyID string, revokedAt time.Time) error
+	createAccessToken(accessToken OauthAccessToken) error
+}
+
+// RefreshTokenAuth exchanges a refresh token for a new access token. Refresh
+// tokens are rotated on every use. Presenting a refresh token that has already
+// been rotated revokes its whole family, since either the legitimate client or
+// an attacker is holding a stolen copy.
+type RefreshTokenAuth struct {
+	tokenStore refreshTokenStore
+	config     Config
+}
+
+func (c *RefreshTokenAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	current, err := c.tokenStore.resolveRefreshToken(hashRefreshToken(credential.RefreshToken))
+	if err != nil {
+		return
+	}
+
+	if current.ClientID != credential.ClientID {
+		err = errors.New(ErrorInvalidRefreshToken)
+		return
+	}
+
+	if current.RevokedAt.Valid {
+		err = c.revokeReusedFamily(current)
+		if err != nil {
+			return
+		}
+
+		err = errors.New(ErrorInvalidRefreshToken)
+		return
+	}
+
+	if current.IsExpired() {
+		err = errors.New(ErrorInvalidRefreshToken)
+		return
+	}
+
+	scope, err := current.NarrowScope(credential.Scope)
+	if err != nil {
+		return
+	}
+
+	// The client may have been registered for fewer scopes since the refresh
+	// token was issued.
+	scope = client.RestrictScope(scope)
+
+	next, plain, err := NewOauthRefreshToken(current.ClientID, current.UserID, current.Scope, current.FamilyID, c.config)
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+
+	rotated, err := c.tokenStore.rotateRefreshToken(current.RefreshToken, next)
+	if err != nil {
+		return
+	}
+
+	// A concurrent request rotated the token first, so this is a reuse too.
+	if !rotated {
+		err = c.revokeReusedFamily(current)
+		if err != nil {
+			return
+		}
+
+		err = errors.New(ErrorInvalidRefreshToken)
+		return
+	}
+
+	oauthAccessToken, err = issueAccessToken(c.tokenStore, c.config, credential.ClientID, current.UserID, scope)
+	if err != nil {
+		return
+	}
+
+	oauthAccessToken.RefreshToken = plain
+	return
+}
+
+func (c *RefreshTokenAuth) revokeReusedFamily(token OauthRefreshToken) error {
+	log.Warn().
+		Str("clientId", token.ClientID).
+		Str("userId", token.UserID.String).
+		Str("familyId", token.FamilyID).
+		Msg("OAuth refresh token reuse detected, revoking token family.")
+
+	return c.tokenStore.revokeRefreshTokenFamily(token.FamilyID, time.Now())
+}
+
+// refreshTokenCreator is the part of TokenStore that stores refresh tokens.
+type refreshTokenCreator interface {
+	createRefreshToken(refreshToken OauthRefreshToken) error
+}
+
+// issueRefreshToken creates a refresh token in a new family for the access
+// token, when the client is allowed to use the refresh_token grant.
+func issueRefreshToken(tokenStore refreshTokenCreator, config Config, client OauthClient, accessToken *OauthAccessToken, scope null.String) error {
+	if !client.GrantTypeAllowed(RefreshToken) {
+		return nil
+	}
+
+	refreshToken, plain, err := newRefreshTokenFamily(*accessToken, scope, config)
+	if err != nil {
+		return err
+	}
+
+	err = tokenStore.createRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	accessToken.RefreshToken = plain
+	return nil
+}
+
+// newRefreshTokenFamily generates the first refresh token of a new family,
+// issued along with the access token.
+func newRefreshTokenFamily(accessToken OauthAccessToken, scope null.String, config Config) (refreshToken OauthRefreshToken, plain string, err error) {
+	familyID, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+
+	refreshToken, plain, err = NewOauthRefreshToken(accessToken.ClientID, accessToken.UserID, scope, familyID.String(), config)
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+	}
+
+	return
+}
+
+// NewOauthRefreshToken generates a refresh token. The returned plain token is
+// handed to the client, while only its hash is stored.
+func NewOauthRefreshToken(clientID string, userID null.String, scope null.String, familyID string, config Config) (refreshToken OauthRefreshToken, plain string, err error) {
+	plain, err = generateAuthorizationCode()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	refreshToken = OauthRefreshToken{
+		RefreshToken: hashRefreshToken(plain),
+		FamilyID:     familyID,
+		ClientID:     clientID,
+		UserID:       userID,
+		Scope:        scope,
+		Expires:      now.Add(time.Second * time.Duration(config.RefreshTokenExpiration)),
+		CreatedAt:    now,
+	}
+
+	return
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// NarrowScope returns the scope a refreshed access token is granted. An empty
+// request keeps the original scope, otherwise every requested scope must have
+// been granted originally. See RFC 6749, section 6.
+func (o *OauthRefreshToken) NarrowScope(requested string) (null.String, error) {
+	requestedScopes := strings.Fields(requested)
+	if len(requestedScopes) == 0 {
+		return o.Scope, nil
+	}
+
+	granted := make(map[string]bool)
+	for _, s := range strings.Fields(o.Scope.String) {
+		granted[s] = true
+	}
+
+	for _, s := range requestedScopes {
+		if !granted[s] {
+			return null.String{}, errors.New(ErrorInvalidScope)
+		}
+	}
+
+	return null.StringFrom(strings.Join(requestedScopes, " ")), nil
+}
@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRefreshTokenStore keeps refresh tokens in memory. When loseRotation is
+// set, rotations behave as if a concurrent request had rotated the token
+// first.
+type fakeRefreshTokenStore struct {
+	refreshTokens   map[string]OauthRefreshToken
+	accessTokens    map[string]OauthAccessToken
+	revokedFamilies map[string]bool
+	loseRotation    bool
+}
+
+func newFakeRefreshTokenStore(refreshTokens ...OauthRefreshToken) *fakeRefreshTokenStore {
+	s := &fakeRefreshTokenStore{
+		refreshTokens:   make(map[string]OauthRefreshToken),
+		accessTokens:    make(map[string]OauthAccessToken),
+		revokedFamilies: make(map[string]bool),
+	}
+	for _, refreshToken := range refreshTokens {
+		s.refreshTokens[refreshToken.RefreshToken] = refreshToken
+	}
+	return s
+}
+
+func (s *fakeRefreshTokenStore) resolveRefreshToken(refreshTokenHash string) (OauthRefreshToken, error) {
+	refreshToken, ok := s.refreshTokens[refreshTokenHash]
+	if !ok {
+		return refreshToken, errors.New(ErrorInvalidRefreshToken)
+	}
+	return refreshToken, nil
+}
+
+func (s *fakeRefreshTokenStore) rotateRefreshToken(currentHash string, next OauthRefreshToken) (bool, error) {
+	current := s.refreshTokens[currentHash]
+	if s.loseRotation || current.RevokedAt.Valid {
+		return false, nil
+	}
+
+	current.RevokedAt = null.TimeFrom(next.CreatedAt)
+	current.ReplacedBy = null.StringFrom(next.RefreshToken)
+	s.refreshTokens[currentHash] = current
+	s.refreshTokens[next.RefreshToken] = next
+
+	return true, nil
+}
+
+func (s *fakeRefreshTokenStore) revokeRefreshTokenFamily(familyID string, revokedAt time.Time) error {
+	s.revokedFamilies[familyID] = true
+	for hash, refreshToken := range s.refreshTokens {
+		if refreshToken.FamilyID == familyID && !refreshToken.RevokedAt.Valid {
+			refreshToken.RevokedAt = null.TimeFrom(revokedAt)
+			s.refreshTokens[hash] = refreshToken
+		}
+	}
+	return nil
+}
+
+func (s *fakeRefreshTokenStore) createAccessToken(accessToken OauthAccessToken) error {
+	s.accessTokens[accessToken.AccessToken] = accessToken
+	return nil
+}
+
+func TestRefreshTokenAuthCreate(t *testing.T) {
+	client := OauthClient{ClientID: "client_web", GrantTypes: "refresh_token", Scope: null.StringFrom("user orders")}
+	config := Config{Expiration: 3600, RefreshTokenExpiration: 86400}
+
+	newRefreshToken := func(expiration int64) (OauthRefreshToken, string) {
+		expiring := config
+		expiring.RefreshTokenExpiration = expiration
+		refreshToken, plain, err := NewOauthRefreshToken("client_web", null.StringFrom("550e8400-e29b-41d4-a716-446655440000"), null.StringFrom("user orders"), "family", expiring)
+		assert.NoError(t, err)
+		return refreshToken, plain
+	}
+
+	t.Run("Rotate", func(t *testing.T) {
+		current, plain := newRefreshToken(config.RefreshTokenExpiration)
+		store := newFakeRefreshTokenStore(current)
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		accessToken, err := c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain, Scope: "user"})
+		assert.NoError(t, err)
+		assert.Contains(t, store.accessTokens, accessToken.AccessToken)
+		assert.Equal(t, null.StringFrom("user"), accessToken.Scope)
+		assert.NotEqual(t, plain, accessToken.RefreshToken)
+
+		next := store.refreshTokens[hashRefreshToken(accessToken.RefreshToken)]
+		assert.Equal(t, "family", next.FamilyID)
+		assert.Equal(t, null.StringFrom("user orders"), next.Scope)
+		assert.False(t, next.RevokedAt.Valid)
+		assert.True(t, store.refreshTokens[current.RefreshToken].RevokedAt.Valid)
+		assert.Empty(t, store.revokedFamilies)
+	})
+
+	t.Run("Replay revokes family", func(t *testing.T) {
+		current, plain := newRefreshToken(config.RefreshTokenExpiration)
+		store := newFakeRefreshTokenStore(current)
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		accessToken, err := c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain})
+		assert.NoError(t, err)
+
+		_, err = c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain})
+		assert.EqualError(t, err, ErrorInvalidRefreshToken)
+		assert.True(t, store.revokedFamilies["family"])
+		assert.True(t, store.refreshTokens[hashRefreshToken(accessToken.RefreshToken)].RevokedAt.Valid)
+	})
+
+	t.Run("Lost rotation race revokes family", func(t *testing.T) {
+		current, plain := newRefreshToken(config.RefreshTokenExpiration)
+		store := newFakeRefreshTokenStore(current)
+		store.loseRotation = true
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain})
+		assert.EqualError(t, err, ErrorInvalidRefreshToken)
+		assert.True(t, store.revokedFamilies["family"])
+		assert.Empty(t, store.accessTokens)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		current, plain := newRefreshToken(-1)
+		store := newFakeRefreshTokenStore(current)
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain})
+		assert.EqualError(t, err, ErrorInvalidRefreshToken)
+		assert.Len(t, store.refreshTokens, 1)
+		assert.False(t, store.refreshTokens[current.RefreshToken].RevokedAt.Valid)
+		assert.Empty(t, store.revokedFamilies)
+	})
+
+	t.Run("Other client", func(t *testing.T) {
+		current, plain := newRefreshToken(config.RefreshTokenExpiration)
+		store := newFakeRefreshTokenStore(current)
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, Credential{ClientID: "client_batch", RefreshToken: plain})
+		assert.EqualError(t, err, ErrorInvalidRefreshToken)
+		assert.False(t, store.refreshTokens[current.RefreshToken].RevokedAt.Valid)
+	})
+
+	t.Run("Wider scope", func(t *testing.T) {
+		current, plain := newRefreshToken(config.RefreshTokenExpiration)
+		store := newFakeRefreshTokenStore(current)
+		c := &RefreshTokenAuth{tokenStore: store, config: config}
+
+		_, err := c.Create(client, Credential{ClientID: "client_web", RefreshToken: plain, Scope: "user admin"})
+		assert.EqualError(t, err, ErrorInvalidScope)
+	})
+}
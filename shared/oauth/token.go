@@ -3,10 +3,13 @@ package oauth
 import (
 	"crypto/rand"
 	"crypto/sha512"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/guregu/null"
 )
 
 // Create Access Token is method to generate unique access_token
@@ -32,3 +35,28 @@ func generateAccessToken() (string, error) {
 
 	return string(accessToken[0:40]), nil
 }
+
+// IssueAccessToken generates and stores an opaque access token for a client,
+// on behalf of a user when userID is set. It is meant for the
+// AuthorizationMethod implementations of custom grants.
+func IssueAccessToken(tokenStore TokenStore, config Config, clientID string, userID null.String, scope null.String) (oauthAccessToken OauthAccessToken, err error) {
+	return issueAccessToken(&tokenStore, config, clientID, userID, scope)
+}
+
+// accessTokenCreator is the part of TokenStore that stores access tokens.
+type accessTokenCreator interface {
+	createAccessToken(accessToken OauthAccessToken) error
+}
+
+func issueAccessToken(tokenStore accessTokenCreator, config Config, clientID string, userID null.String, scope null.String) (oauthAccessToken OauthAccessToken, err error) {
+	accessToken, err := generateAccessToken()
+	if err != nil {
+		err = errors.New(ErrorGenerateAccessToken)
+		return
+	}
+
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, clientID, userID, scope, config)
+
+	err = tokenStore.createAccessToken(oauthAccessToken)
+	return
+}
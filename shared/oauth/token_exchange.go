@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+	"github.com/guregu/null"
+)
+
+// Token type identifiers of RFC 8693, section 3.
+const (
+	TokenTypeAccessToken string = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         string = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeAuth swaps a token of a user for a down-scoped token that is
+// restricted to one audience and names the calling client as its actor, as
+// described in RFC 8693. Only confidential clients may exchange tokens.
+type TokenExchangeAuth struct {
+	tokenStore TokenStore
+	config     Config
+	jwtService *shared.JWTService
+	denylist   shared.TokenDenylist
+}
+
+func (c *TokenExchangeAuth) Create(client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	if client.ClientSecret == "" {
+		err = errors.New(ErrorPublicClientNotAllowed)
+		return
+	}
+
+	if c.jwtService == nil {
+		err = errors.New(ErrorUnsupportedGrant)
+		return
+	}
+
+	if credential.RequestedTokenType != "" && credential.RequestedTokenType != TokenTypeJWT {
+		err = errors.New(ErrorUnsupportedTokenType)
+		return
+	}
+
+	audience, err := c.resolveAudience(credential.Audience)
+	if err != nil {
+		return
+	}
+
+	subject, subjectScope, limited, err := c.resolveSubject(credential.SubjectToken, credential.SubjectTokenType)
+	if err != nil {
+		return
+	}
+
+	scope, err := client.GrantScope(credential.Scope)
+	if err != nil {
+		return
+	}
+
+	// The exchanged token never carries more than the subject token did. An
+	// empty scope would not limit the exchanged token at all, so it is
+	// refused, whether the client has no scope or the intersection is empty.
+	if limited {
+		scope = intersectScope(scope, subjectScope)
+	}
+	if !scope.Valid {
+		err = errors.New(ErrorScopeNotAllowed)
+		return
+	}
+
+	token, expiresAt, err := c.jwtService.GenerateDelegatedJWT(shared.Claims{
+		UserID:      subject.UserID,
+		Username:    subject.Username,
+		Email:       subject.Email,
+		Scope:       scope.String,
+		Roles:       subject.Roles,
+		Permissions: subject.Permissions,
+		Actor:       &shared.Actor{Subject: client.ClientID, Actor: subject.Actor},
+		StandardClaims: jwt.StandardClaims{
+			Audience:  audience,
+			ExpiresAt: subject.ExpiresAt,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return OauthAccessToken{
+		AccessToken:     token,
+		ClientID:        client.ClientID,
+		UserID:          null.StringFrom(subject.UserID.String()),
+		Expires:         expiresAt,
+		Scope:           scope,
+		IssuedTokenType: TokenTypeJWT,
+	}, nil
+}
+
+// resolveAudience checks that exactly one audience is requested, and that it
+// is one of App.OAuth.TokenExchangeAudiences. Tokens can't be exchanged when
+// none are configured. This service itself is never an audience, as exchanged
+// tokens would otherwise be accepted in place of the user's own.
+func (c *TokenExchangeAuth) resolveAudience(audience string) (string, error) {
+	audiences := strings.Fields(audience)
+	if len(audiences) != 1 {
+		return "", errors.New(ErrorInvalidAudience)
+	}
+
+	if audiences[0] == c.jwtService.Issuer {
+		return "", errors.New(ErrorAudienceNotAllowed)
+	}
+
+	for _, a := range c.config.TokenExchangeAudiences {
+		if a == audiences[0] {
+			return audiences[0], nil
+		}
+	}
+
+	return "", errors.New(ErrorAudienceNotAllowed)
+}
+
+// resolveSubject validates the subject token and returns the claims of the
+// user it was issued to. When the token is limited to a scope, that scope is
+// returned too. A JWT from the user login carries no scope, and is therefore
+// not limited.
+func (c *TokenExchangeAuth) resolveSubject(token string, tokenType string) (claims shared.Claims, scope null.String, limited bool, err error) {
+	if tokenType != TokenTypeAccessToken && tokenType != TokenTypeJWT {
+		err = errors.New(ErrorUnsupportedTokenType)
+		return
+	}
+
+	if token == "" {
+		err = errors.New(ErrorInvalidSubjectToken)
+		return
+	}
+
+	if isJWT(token) {
+		validated, validateErr := c.jwtService.ValidateJWT(token)
+		if validateErr != nil {
+			err = errors.New(ErrorInvalidSubjectToken)
+			return
+		}
+
+		if c.denylist != nil {
+			revoked, revokedErr := c.denylist.IsRevoked(validated)
+			if revokedErr != nil {
+				err = revokedErr
+				return
+			}
+			if revoked {
+				err = errors.New(ErrorInvalidSubjectToken)
+				return
+			}
+		}
+
+		claims = *validated
+		if claims.Scope != "" {
+			scope = null.StringFrom(claims.Scope)
+			limited = true
+		}
+		return
+	}
+
+	if tokenType != TokenTypeAccessToken {
+		err = errors.New(ErrorInvalidSubjectToken)
+		return
+	}
+
+	accessToken, err := NewParser(c.tokenStore).Parse(string(Bearer) + " " + token)
+	if err != nil {
+		if err.Error() == ErrorClientNotFound {
+			err = errors.New(ErrorInvalidSubjectToken)
+		}
+		return
+	}
+
+	if !accessToken.VerifyExpireIn() || !accessToken.VerifyUserLoggedIn() {
+		err = errors.New(ErrorInvalidSubjectToken)
+		return
+	}
+
+	userID, err := uuid.FromString(accessToken.UserID.String)
+	if err != nil {
+		err = errors.New(ErrorInvalidSubjectToken)
+		return
+	}
+
+	claims.UserID = userID
+	claims.ExpiresAt = accessToken.Expires.Unix()
+	return claims, accessToken.Scope, true, nil
+}
+
+// intersectScope keeps the scopes of granted that are also in limit.
+func intersectScope(granted null.String, limit null.String) null.String {
+	allowed := make(map[string]bool)
+	for _, s := range strings.Fields(limit.String) {
+		allowed[s] = true
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(granted.String) {
+		if allowed[s] {
+			scopes = append(scopes, s)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return null.String{}
+	}
+
+	return null.StringFrom(strings.Join(scopes, " "))
+}
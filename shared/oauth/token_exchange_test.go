@@ -0,0 +1,158 @@
+package oauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenExchangeResolveAudience(t *testing.T) {
+	jwtService := shared.NewJWTService("secret", time.Minute)
+
+	tests := []struct {
+		name      string
+		audiences []string
+		audience  string
+		want      string
+		err       string
+	}{
+		{name: "Allowed", audiences: []string{"orders", "payments"}, audience: "payments", want: "payments"},
+		{name: "Not allowed", audiences: []string{"orders"}, audience: "payments", err: ErrorAudienceNotAllowed},
+		{name: "None configured", audience: "payments", err: ErrorAudienceNotAllowed},
+		{name: "Issuer", audiences: []string{shared.DefaultJWTIssuer}, audience: shared.DefaultJWTIssuer, err: ErrorAudienceNotAllowed},
+		{name: "Missing", audiences: []string{"orders"}, err: ErrorInvalidAudience},
+		{name: "Several", audiences: []string{"orders", "payments"}, audience: "orders payments", err: ErrorInvalidAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &TokenExchangeAuth{config: Config{TokenExchangeAudiences: tt.audiences}, jwtService: jwtService}
+
+			audience, err := c.resolveAudience(tt.audience)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, audience)
+		})
+	}
+}
+
+func TestTokenExchangeResolveSubject(t *testing.T) {
+	jwtService := shared.NewJWTService("secret", time.Minute)
+	userID, _ := uuid.NewV4()
+
+	loginToken, _, err := jwtService.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+	assert.NoError(t, err)
+
+	scopedToken, _, err := jwtService.GenerateScopedJWT(userID, "john", "john@example.com", "foo:read", shared.Authorities{})
+	assert.NoError(t, err)
+
+	foreignToken, _, err := shared.NewJWTService("other", time.Minute).GenerateJWT(userID, "john", "john@example.com", shared.Authorities{})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+		scope     null.String
+		limited   bool
+		err       string
+	}{
+		{name: "Login JWT", token: loginToken, tokenType: TokenTypeJWT},
+		{name: "Login JWT as access token", token: loginToken, tokenType: TokenTypeAccessToken},
+		{name: "Scoped JWT", token: scopedToken, tokenType: TokenTypeJWT, scope: null.StringFrom("foo:read"), limited: true},
+		{name: "Foreign JWT", token: foreignToken, tokenType: TokenTypeJWT, err: ErrorInvalidSubjectToken},
+		{name: "Opaque token as JWT", token: "dGhpc2lzb3BhcXVl", tokenType: TokenTypeJWT, err: ErrorInvalidSubjectToken},
+		{name: "Missing", tokenType: TokenTypeJWT, err: ErrorInvalidSubjectToken},
+		{name: "Unsupported token type", token: loginToken, tokenType: "urn:ietf:params:oauth:token-type:saml2", err: ErrorUnsupportedTokenType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &TokenExchangeAuth{jwtService: jwtService}
+
+			claims, scope, limited, err := c.resolveSubject(tt.token, tt.tokenType)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+			assert.Equal(t, tt.scope, scope)
+			assert.Equal(t, tt.limited, limited)
+		})
+	}
+}
+
+func TestTokenExchangeCreate(t *testing.T) {
+	jwtService := shared.NewJWTService("secret", time.Minute)
+	userID, _ := uuid.NewV4()
+
+	subjectToken, _, err := jwtService.GenerateScopedJWT(userID, "john", "john@example.com", "foo:read", shared.Authorities{})
+	assert.NoError(t, err)
+
+	c := &TokenExchangeAuth{config: Config{TokenExchangeAudiences: []string{"orders"}}, jwtService: jwtService}
+	client := OauthClient{ClientID: "client_batch", ClientSecret: "secret", Scope: null.StringFrom("foo:read bar:read")}
+	credential := Credential{Audience: "orders", SubjectToken: subjectToken, SubjectTokenType: TokenTypeJWT}
+
+	t.Run("Limited by the subject token", func(t *testing.T) {
+		accessToken, err := c.Create(client, credential)
+		assert.NoError(t, err)
+		assert.Equal(t, null.StringFrom("foo:read"), accessToken.Scope)
+
+		claims, err := jwtService.ValidateJWT(accessToken.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "foo:read", claims.Scope)
+		assert.Equal(t, "client_batch", claims.Actor.Subject)
+	})
+
+	t.Run("Empty intersection", func(t *testing.T) {
+		client := client
+		client.Scope = null.StringFrom("bar:read")
+
+		_, err := c.Create(client, credential)
+		assert.EqualError(t, err, ErrorScopeNotAllowed)
+	})
+
+	t.Run("Unscoped subject and client", func(t *testing.T) {
+		loginToken, _, err := jwtService.GenerateJWT(userID, "john", "john@example.com", shared.Authorities{Roles: []string{"admin"}})
+		assert.NoError(t, err)
+
+		client := client
+		client.Scope = null.String{}
+		credential := credential
+		credential.SubjectToken = loginToken
+
+		_, err = c.Create(client, credential)
+		assert.EqualError(t, err, ErrorScopeNotAllowed)
+	})
+}
+
+func TestIntersectScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		granted null.String
+		limit   null.String
+		want    null.String
+	}{
+		{name: "Subset", granted: null.StringFrom("foo:read"), limit: null.StringFrom("foo:read foo:write"), want: null.StringFrom("foo:read")},
+		{name: "Overlap", granted: null.StringFrom("foo:read bar:read"), limit: null.StringFrom("foo:read foo:write"), want: null.StringFrom("foo:read")},
+		{name: "Disjoint", granted: null.StringFrom("bar:read"), limit: null.StringFrom("foo:read"), want: null.String{}},
+		{name: "Nothing granted", limit: null.StringFrom("foo:read"), want: null.String{}},
+		{name: "No limit", granted: null.StringFrom("foo:read"), want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, intersectScope(tt.granted, tt.limit))
+		})
+	}
+}
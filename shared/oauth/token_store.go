@@ -3,7 +3,10 @@ package oauth
 import (
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/guregu/null"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -39,15 +42,161 @@ const (
 			client_id,
 			client_secret,
 			redirect_uri,
-			grant_types
+			grant_types,
+			scope
 		FROM 
 			oauth_clients`
 
-	querySelectUser = `
+	queryInsertAuthorizationCode = `INSERT INTO oauth_authorization_codes (
+			code,
+			client_id,
+			user_id,
+			redirect_uri,
+			scope,
+			nonce,
+			code_challenge,
+			code_challenge_method,
+			auth_time,
+			expires,
+			created_at
+		) VALUES (
+			:code,
+			:client_id,
+			:user_id,
+			:redirect_uri,
+			:scope,
+			:nonce,
+			:code_challenge,
+			:code_challenge_method,
+			:auth_time,
+			:expires,
+			:created_at
+		)`
+
+	querySelectAuthorizationCode = `SELECT
+			code,
+			client_id,
+			user_id,
+			redirect_uri,
+			scope,
+			nonce,
+			code_challenge,
+			code_challenge_method,
+			auth_time,
+			expires,
+			used_at,
+			access_token,
+			refresh_token_family_id,
+			created_at
+		FROM
+			oauth_authorization_codes`
+
+	queryConsumeAuthorizationCode = `UPDATE oauth_authorization_codes
+		SET used_at = ?, access_token = ?, refresh_token_family_id = ?
+		WHERE code = ? AND used_at IS NULL`
+
+	queryDeleteAccessToken = `DELETE FROM oauth_access_tokens WHERE access_token = ?`
+
+	queryInsertRefreshToken = `INSERT INTO oauth_refresh_tokens (
+			refresh_token,
+			family_id,
+			client_id,
+			user_id,
+			scope,
+			expires,
+			created_at
+		) VALUES (
+			:refresh_token,
+			:family_id,
+			:client_id,
+			:user_id,
+			:scope,
+			:expires,
+			:created_at
+		)`
+
+	querySelectRefreshToken = `SELECT
+			refresh_token,
+			family_id,
+			client_id,
+			user_id,
+			scope,
+			expires,
+			created_at,
+			revoked_at,
+			replaced_by
+		FROM
+			oauth_refresh_tokens`
+
+	queryRotateRefreshToken = `UPDATE oauth_refresh_tokens
+		SET revoked_at = ?, replaced_by = ?
+		WHERE refresh_token = ? AND revoked_at IS NULL`
+
+	queryRevokeRefreshTokenFamily = `UPDATE oauth_refresh_tokens
+		SET revoked_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL`
+
+	queryDeleteAccessTokensByUserID = `DELETE FROM oauth_access_tokens WHERE user_id = ?`
+
+	queryRevokeRefreshTokensByUserID = `UPDATE oauth_refresh_tokens
+		SET revoked_at = ?
+		WHERE user_id = ? AND revoked_at IS NULL`
+
+	queryInsertDeviceCode = `INSERT INTO oauth_device_codes (
+			device_code,
+			user_code,
+			client_id,
+			scope,
+			status,
+			interval_seconds,
+			expires,
+			created_at
+		) VALUES (
+			:device_code,
+			:user_code,
+			:client_id,
+			:scope,
+			:status,
+			:interval_seconds,
+			:expires,
+			:created_at
+		)`
+
+	querySelectDeviceCode = `SELECT
+			device_code,
+			user_code,
+			client_id,
+			scope,
+			status,
+			user_id,
+			interval_seconds,
+			expires,
+			last_polled_at,
+			decided_at,
+			created_at
+		FROM
+			oauth_device_codes`
+
+	queryDecideDeviceCode = `UPDATE oauth_device_codes
+		SET status = ?, user_id = ?, decided_at = ?
+		WHERE user_code = ? AND status = 'pending' AND expires > ?`
+
+	queryUpdateDeviceCodePoll = `UPDATE oauth_device_codes
+		SET interval_seconds = ?, last_polled_at = ?
+		WHERE device_code = ?`
+
+	queryConsumeDeviceCode = `DELETE FROM oauth_device_codes
+		WHERE device_code = ? AND status = 'approved'`
+
+	queryDeleteExpiredDeviceCodes = `DELETE FROM oauth_device_codes WHERE expires < ?`
+
+	querySelectUserProfile = `
 			SELECT
 				id,
+				name,
 				username,
-				password
+				email,
+				email_verified_at
 			FROM
 				user`
 )
@@ -58,6 +207,12 @@ func NewTokenStore(db *sqlx.DB) TokenStore {
 	}
 }
 
+// ProvideTokenStore is the provider for TokenStore. Tokens are issued against
+// the write database, so that they can be used right after they are returned.
+func ProvideTokenStore(db *infras.MySQLConn) TokenStore {
+	return NewTokenStore(db.Write)
+}
+
 func (a *TokenStore) createAccessToken(accessToken OauthAccessToken) error {
 	stmt, err := a.db.PrepareNamed(queryInsertAccessToken)
 	if err != nil {
@@ -98,7 +253,7 @@ func (a *TokenStore) resolveAllClients(db *sqlx.DB) ([]OauthClient, error) {
 }
 
 func (a *TokenStore) resolveClientByClientID(clientID string) (client OauthClient, err error) {
-	err = a.db.Get(&client, querySelectClients+" WHERE client_id = ?", clientID)
+	err = a.db.Get(&client, querySelectClients+" WHERE client_id = ? AND disabled_at IS NULL", clientID)
 	switch {
 	case err == sql.ErrNoRows:
 		err = errors.New(ErrorClientNotFound)
@@ -110,16 +265,241 @@ func (a *TokenStore) resolveClientByClientID(clientID string) (client OauthClien
 	return
 }
 
-func (a *TokenStore) resolveByTelephoneOrEmail(username string) (User, error) {
-	var user User
+func (a *TokenStore) createAuthorizationCode(code OauthAuthorizationCode) error {
+	stmt, err := a.db.PrepareNamed(queryInsertAuthorizationCode)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 
-	err := a.db.Get(&user, querySelectUser+" WHERE telephone = ? OR  email = ?", username, username)
-	switch {
-	case err == sql.ErrNoRows:
-		return User{}, errors.New(ErrorClientNotFound)
-	case err != nil:
-		return User{}, err
+	_, err = stmt.Exec(code)
+	return err
+}
+
+func (a *TokenStore) resolveAuthorizationCode(codeHash string) (code OauthAuthorizationCode, err error) {
+	err = a.db.Get(&code, querySelectAuthorizationCode+" WHERE code = ?", codeHash)
+	if err == sql.ErrNoRows {
+		err = errors.New(ErrorInvalidAuthorizationCode)
+	}
+
+	return
+}
+
+// consumeAuthorizationCode marks the code as used, and stores the tokens
+// issued for it in a single transaction, recording them on the code so that
+// they can be revoked when the code is replayed. The refresh token is
+// optional. It reports false when the code had already been used, in which
+// case nothing is stored, so that a code is exchanged at most once even under
+// concurrent requests.
+func (a *TokenStore) consumeAuthorizationCode(codeHash string, usedAt time.Time, accessToken OauthAccessToken, refreshToken *OauthRefreshToken) (consumed bool, err error) {
+	tx, err := a.db.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil || !consumed {
+			tx.Rollback()
+		}
+	}()
+
+	var familyID null.String
+	if refreshToken != nil {
+		familyID = null.StringFrom(refreshToken.FamilyID)
+	}
+
+	result, err := tx.Exec(queryConsumeAuthorizationCode, usedAt, accessToken.AccessToken, familyID, codeHash)
+	if err != nil {
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		return
+	}
+
+	if _, err = tx.NamedExec(queryInsertAccessToken, accessToken); err != nil {
+		return
+	}
+
+	if refreshToken != nil {
+		if _, err = tx.NamedExec(queryInsertRefreshToken, *refreshToken); err != nil {
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	consumed = true
+	return
+}
+
+func (a *TokenStore) deleteAccessToken(accessToken string) error {
+	_, err := a.db.Exec(queryDeleteAccessToken, accessToken)
+	return err
+}
+
+func (a *TokenStore) resolveUserProfileByID(id string) (profile UserProfile, err error) {
+	err = a.db.Get(&profile, querySelectUserProfile+" WHERE id = ? AND deleted_at IS NULL", id)
+	if err == sql.ErrNoRows {
+		err = errors.New(ErrorUserNotFound)
+	}
+
+	return
+}
+
+func (a *TokenStore) createRefreshToken(refreshToken OauthRefreshToken) error {
+	stmt, err := a.db.PrepareNamed(queryInsertRefreshToken)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(refreshToken)
+	return err
+}
+
+func (a *TokenStore) resolveRefreshToken(refreshTokenHash string) (refreshToken OauthRefreshToken, err error) {
+	err = a.db.Get(&refreshToken, querySelectRefreshToken+" WHERE refresh_token = ?", refreshTokenHash)
+	if err == sql.ErrNoRows {
+		err = errors.New(ErrorInvalidRefreshToken)
+	}
+
+	return
+}
+
+// rotateRefreshToken revokes the current refresh token in favour of the next
+// one in a single transaction. It reports false when the current token had
+// already been revoked, in which case nothing is changed.
+func (a *TokenStore) rotateRefreshToken(currentHash string, next OauthRefreshToken) (rotated bool, err error) {
+	tx, err := a.db.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil || !rotated {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(queryRotateRefreshToken, next.CreatedAt, next.RefreshToken, currentHash)
+	if err != nil {
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		return
+	}
+
+	stmt, err := tx.PrepareNamed(queryInsertRefreshToken)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.Exec(next); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	rotated = true
+	return
+}
+
+func (a *TokenStore) revokeRefreshTokenFamily(familyID string, revokedAt time.Time) error {
+	_, err := a.db.Exec(queryRevokeRefreshTokenFamily, revokedAt, familyID)
+	return err
+}
+
+// RevokeUserTokens revokes every access and refresh token issued on behalf of
+// a user, e.g. when the account is deleted.
+func (a *TokenStore) RevokeUserTokens(userID string) error {
+	if _, err := a.db.Exec(queryDeleteAccessTokensByUserID, userID); err != nil {
+		return err
 	}
 
-	return user, nil
+	_, err := a.db.Exec(queryRevokeRefreshTokensByUserID, time.Now(), userID)
+	return err
+}
+
+// ResolveClient resolves an enabled client by its ID, e.g. to validate the
+// audience of an ID token.
+func (a *TokenStore) ResolveClient(clientID string) (OauthClient, error) {
+	return a.resolveClientByClientID(clientID)
+}
+
+func (a *TokenStore) createDeviceCode(deviceCode OauthDeviceCode) error {
+	stmt, err := a.db.PrepareNamed(queryInsertDeviceCode)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(deviceCode)
+	return err
+}
+
+func (a *TokenStore) resolveDeviceCode(deviceCodeHash string) (deviceCode OauthDeviceCode, err error) {
+	err = a.db.Get(&deviceCode, querySelectDeviceCode+" WHERE device_code = ?", deviceCodeHash)
+	if err == sql.ErrNoRows {
+		err = errors.New(ErrorInvalidDeviceCode)
+	}
+
+	return
+}
+
+func (a *TokenStore) resolveDeviceCodeByUserCode(userCode string) (deviceCode OauthDeviceCode, err error) {
+	err = a.db.Get(&deviceCode, querySelectDeviceCode+" WHERE user_code = ?", userCode)
+	if err == sql.ErrNoRows {
+		err = errors.New(ErrorInvalidUserCode)
+	}
+
+	return
+}
+
+// decideDeviceCode records the decision of the user on a pending device code.
+// It reports false when there is no pending, unexpired device code.
+func (a *TokenStore) decideDeviceCode(userCode string, status DeviceCodeStatus, userID string, decidedAt time.Time) (bool, error) {
+	result, err := a.db.Exec(queryDecideDeviceCode, status, userID, decidedAt, userCode, decidedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (a *TokenStore) updateDeviceCodePoll(deviceCodeHash string, intervalSeconds int64, polledAt time.Time) error {
+	_, err := a.db.Exec(queryUpdateDeviceCodePoll, intervalSeconds, polledAt, deviceCodeHash)
+	return err
+}
+
+// consumeDeviceCode deletes an approved device code. It reports false when the
+// code had already been exchanged, so that it is exchanged at most once.
+func (a *TokenStore) consumeDeviceCode(deviceCodeHash string) (bool, error) {
+	result, err := a.db.Exec(queryConsumeDeviceCode, deviceCodeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (a *TokenStore) deleteExpiredDeviceCodes(now time.Time) error {
+	_, err := a.db.Exec(queryDeleteExpiredDeviceCodes, now)
+	return err
 }
@@ -1,35 +1,53 @@
 package middleware
 
 import (
-	"context"
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/authctx"
+	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 )
 
 type Authentication struct {
-	db     *infras.MySQLConn
-	config *configs.Config
+	db         *infras.MySQLConn
+	config     *configs.Config
+	jwtService *shared.JWTService
+	denylist   shared.TokenDenylist
 }
 
 const (
 	HeaderAuthorization = "Authorization"
 )
 
-func ProvideAuthentication(db *infras.MySQLConn, config *configs.Config) *Authentication {
+func ProvideAuthentication(db *infras.MySQLConn, config *configs.Config, jwtService *shared.JWTService, denylist shared.TokenDenylist) *Authentication {
 	return &Authentication{
-		db:     db,
-		config: config,
+		db:         db,
+		config:     config,
+		jwtService: jwtService,
+		denylist:   denylist,
 	}
 }
 
+// ClientCredentialWithJWT authenticates the user by the JWT in the
+// Authorization header. The tokens of users whose email address is not
+// verified are rejected, see ClientCredentialWithUnverifiedJWT.
 func (a *Authentication) ClientCredentialWithJWT(next http.Handler) http.Handler {
+	return a.jwt(next, false)
+}
+
+// ClientCredentialWithUnverifiedJWT is like ClientCredentialWithJWT, but it
+// also accepts the limited tokens of users whose email address is not
+// verified yet.
+func (a *Authentication) ClientCredentialWithUnverifiedJWT(next http.Handler) http.Handler {
+	return a.jwt(next, true)
+}
+
+func (a *Authentication) jwt(next http.Handler, allowUnverified bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -50,84 +68,187 @@ func (a *Authentication) ClientCredentialWithJWT(next http.Handler) http.Handler
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "claims", claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
+		// A token exchanged for another service must not be accepted here.
+		if claims.Audience != "" && claims.Audience != a.jwtService.Issuer {
+			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: JWT token is for another audience")
+			return
+		}
 
-func (a *Authentication) ClientCredential(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessToken := r.Header.Get(HeaderAuthorization)
-		token := oauth.New(a.db.Read, oauth.Config{})
+		// Neither must a delegated token, whatever its audience, as it was
+		// issued to a client acting on behalf of the user.
+		if claims.Actor != nil {
+			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: JWT token is delegated")
+			return
+		}
 
-		parseToken, err := token.ParseWithAccessToken(accessToken)
+		if !allowUnverified && claims.HasScope(shared.UnverifiedScope(a.config)) {
+			response.WithMessage(w, http.StatusForbidden, "Forbidden: Email address is not verified")
+			return
+		}
+
+		revoked, err := a.denylist.IsRevoked(claims)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			logger.ErrorWithStack(err)
+			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: Unable to verify JWT token")
 			return
 		}
 
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+		if revoked {
+			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: JWT token has been revoked")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ctx := authctx.WithClaims(r.Context(), claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (a *Authentication) ClientCredentialWithQueryParameter(next http.Handler) http.Handler {
+// RequireAdmin only lets through users whose ID is listed in
+// App.Admin.UserIDs. Users are matched by ID rather than by username, as
+// usernames can be changed, and are released when an account is anonymized.
+// It must be used after ClientCredentialWithJWT.
+func (a *Authentication) RequireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		params := r.URL.Query()
-		token := params.Get("token")
-		tokenType := params.Get("token_type")
-		accessToken := tokenType + " " + token
-
-		auth := oauth.New(a.db.Read, oauth.Config{})
-		parseToken, err := auth.ParseWithAccessToken(accessToken)
-		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+		claims, ok := authctx.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: No JWT claims")
 			return
 		}
 
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
+		for _, userID := range a.config.App.Admin.UserIDs {
+			if strings.EqualFold(userID, claims.UserID.String()) {
+				next.ServeHTTP(w, r)
+				return
+			}
 		}
 
-		next.ServeHTTP(w, r)
+		response.WithMessage(w, http.StatusForbidden, "Forbidden: Admin access required")
 	})
 }
 
+// RequirePermission only lets through users whose JWT grants all of the given
+// permissions, such as foo:write. It must be used after
+// ClientCredentialWithJWT. The permissions are read from the token, so
+// changes to the roles of a user apply once the user gets a new token.
+func (a *Authentication) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := authctx.ClaimsFromContext(r.Context())
+			if !ok {
+				response.WithMessage(w, http.StatusUnauthorized, "Unauthorized: No JWT claims")
+				return
+			}
+
+			for _, permission := range permissions {
+				if !claims.HasPermission(permission) {
+					response.WithMessage(w, http.StatusForbidden, "Forbidden: Permission "+permission+" required")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientCredential authenticates the client by the OAuth access token in the
+// Authorization header. See RequireScopes.
+func (a *Authentication) ClientCredential(next http.Handler) http.Handler {
+	return a.RequireScopes()(next)
+}
+
+// ClientCredentialWithQueryParameter is like ClientCredential, but the access
+// token is read from the token and token_type query parameters.
+func (a *Authentication) ClientCredentialWithQueryParameter(next http.Handler) http.Handler {
+	return a.accessToken(next, queryParameterAuthorization, false, nil)
+}
+
+// Password authenticates the user by the OAuth access token in the
+// Authorization header. See RequireUserScopes.
 func (a *Authentication) Password(next http.Handler) http.Handler {
+	return a.RequireUserScopes()(next)
+}
+
+// RequireScopes authenticates the client by the OAuth access token in the
+// Authorization header, and only lets it through when the token was granted
+// all of the given scopes. The token is attached to the request context, see
+// authctx.AccessTokenFromContext. Failures are described in the
+// WWW-Authenticate header, as defined by RFC 6750.
+func (a *Authentication) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.accessToken(next, headerAuthorization, false, scopes)
+	}
+}
+
+// RequireUserScopes is like RequireScopes, but the token must have been
+// issued on behalf of a user, rather than to a client acting on its own
+// behalf.
+func (a *Authentication) RequireUserScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.accessToken(next, headerAuthorization, true, scopes)
+	}
+}
+
+// JWTOrUserScopes authenticates the user either by a JWT, like
+// ClientCredentialWithJWT, or by an OAuth access token issued on their behalf
+// and granted all of the given scopes, like RequireUserScopes. A JWT is told
+// apart from an opaque access token by its dot-separated segments. Handlers
+// read the user from authctx.PrincipalFromContext.
+func (a *Authentication) JWTOrUserScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		jwt := a.jwt(next, false)
+		accessToken := a.accessToken(next, headerAuthorization, true, scopes)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Count(headerAuthorization(r), ".") == 2 {
+				jwt.ServeHTTP(w, r)
+				return
+			}
+
+			accessToken.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a *Authentication) accessToken(next http.Handler, authorization func(r *http.Request) string, userRequired bool, scopes []string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessToken := r.Header.Get(HeaderAuthorization)
 		token := oauth.New(a.db.Read, oauth.Config{})
 
-		parseToken, err := token.ParseWithAccessToken(accessToken)
+		accessToken, err := token.VerifyAccessToken(authorization(r), userRequired, scopes...)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
+			bearerErr, ok := err.(*oauth.BearerError)
+			if !ok {
+				logger.ErrorWithStack(err)
+				response.WithMessage(w, http.StatusInternalServerError, "Unable to verify access token")
+				return
+			}
 
-		if !parseToken.VerifyUserLoggedIn() {
-			response.WithMessage(w, http.StatusUnauthorized, oauth.ErrorInvalidPassword)
+			w.Header().Set("WWW-Authenticate", bearerErr.Challenge())
+			response.WithMessage(w, bearerErr.StatusCode(), bearerErr.Description)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ctx := authctx.WithAccessToken(r.Context(), accessToken)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func headerAuthorization(r *http.Request) string {
+	return r.Header.Get(HeaderAuthorization)
+}
+
+func queryParameterAuthorization(r *http.Request) string {
+	params := r.URL.Query()
+	if params.Get("token") == "" {
+		return ""
+	}
+
+	return params.Get("token_type") + " " + params.Get("token")
+}
+
 // Internal Function
 func (a *Authentication) createClaims(tokenString string) (claims *shared.Claims, err error) {
-	fmt.Println(a.config.App.Secret)
-	jwtService := shared.ProvideJWTService(a.config.App.Secret)
-	claims, err = jwtService.ValidateJWT(tokenString)
+	claims, err = a.jwtService.ValidateJWT(tokenString)
 	if err != nil {
 		return
 	}
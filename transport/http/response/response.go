@@ -2,7 +2,9 @@ package response
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
@@ -30,6 +32,24 @@ func WithJSON(w http.ResponseWriter, code int, jsonPayload interface{}) {
 	respond(w, code, Base{Data: &jsonPayload})
 }
 
+// WithRawJSON sends a response containing a JSON object as-is, without the
+// Base envelope. Use it for responses whose format is fixed by a standard.
+func WithRawJSON(w http.ResponseWriter, code int, jsonPayload interface{}) {
+	respond(w, code, jsonPayload)
+}
+
+// WithAttachment sends a file to be downloaded under the given file name
+func WithAttachment(w http.ResponseWriter, contentType string, filename string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(content)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+}
+
 // WithError sends a response with an error message
 func WithError(w http.ResponseWriter, err error) {
 	code := failure.GetCode(err)
@@ -7,8 +7,12 @@ import (
 
 // DomainHandlers is a struct that contains all domain-specific handlers.
 type DomainHandlers struct {
-	FooBarBazHandler handlers.FooBarBazHandler
-	UserHandler      handlers.UserHandler
+	FooBarBazHandler   handlers.FooBarBazHandler
+	OAuthHandler       handlers.OAuthHandler
+	OAuthClientHandler handlers.OAuthClientHandler
+	RBACHandler        handlers.RBACHandler
+	UserHandler        handlers.UserHandler
+	WellKnownHandler   handlers.WellKnownHandler
 }
 
 // Router is the router struct containing handlers.
@@ -25,8 +29,13 @@ func ProvideRouter(domainHandlers DomainHandlers) Router {
 
 // SetupRoutes sets up all routing for this server.
 func (r *Router) SetupRoutes(mux *chi.Mux) {
+	r.DomainHandlers.WellKnownHandler.Router(mux)
+	r.DomainHandlers.OAuthHandler.Router(mux)
+
 	mux.Route("/v1", func(rc chi.Router) {
 		r.DomainHandlers.FooBarBazHandler.Router(rc)
+		r.DomainHandlers.OAuthClientHandler.Router(rc)
+		r.DomainHandlers.RBACHandler.Router(rc)
 		r.DomainHandlers.UserHandler.Router(rc)
 	})
 }
@@ -10,8 +10,14 @@ import (
 	"github.com/evermos/boilerplate-go/event/producer"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
+	"github.com/evermos/boilerplate-go/internal/domain/oauthclient"
+	"github.com/evermos/boilerplate-go/internal/domain/rbac"
 	"github.com/evermos/boilerplate-go/internal/domain/user"
 	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/keyring"
+	"github.com/evermos/boilerplate-go/shared/mailer"
+	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/router"
@@ -26,6 +32,31 @@ var configurations = wire.NewSet(
 // Wiring for persistences.
 var persistences = wire.NewSet(
 	infras.ProvideMySQLConn,
+	infras.ProvideRedisClient,
+)
+
+// Wiring for JWT issuance and revocation.
+var tokens = wire.NewSet(
+	keyring.ProvideKeyRing,
+	keyring.ProvideJWTService,
+	shared.ProvideJWTDenylist,
+	wire.Bind(new(shared.TokenDenylist), new(*shared.JWTDenylist)),
+)
+
+// Wiring for opaque OAuth access tokens. The grant registry holds the grant
+// types of the token endpoint; custom grants are registered by replacing
+// oauth.ProvideGrantRegistry with a provider that calls it.
+var oauthTokens = wire.NewSet(
+	oauth.ProvideConfig,
+	oauth.ProvideTokenStore,
+	oauth.ProvideGrantRegistry,
+	oauth.ProvideToken,
+)
+
+// Wiring for outbound email. The mail driver is selected by Mail.Driver.
+var mailing = wire.NewSet(
+	mailer.ProvideMailer,
+	mailer.ProvideTemplateMailer,
 )
 
 // Wiring for domain FooBarBaz.
@@ -45,13 +76,49 @@ var domainFooBarBaz = wire.NewSet(
 var domainUser = wire.NewSet(
 	user.ProvideUserServiceImpl,
 	wire.Bind(new(user.UserService), new(*user.UserServiceImpl)),
+	// The user service authenticates the users of the OAuth password grant.
+	wire.Bind(new(oauth.UserAuthenticator), new(*user.UserServiceImpl)),
 	user.ProvideUserRepositoryMySQL,
 	wire.Bind(new(user.UserRepository), new(*user.UserRepositoryMySQL)),
+	user.ProvideRefreshTokenRepositoryMySQL,
+	wire.Bind(new(user.RefreshTokenRepository), new(*user.RefreshTokenRepositoryMySQL)),
+	user.ProvideEmailVerificationRepositoryMySQL,
+	wire.Bind(new(user.EmailVerificationRepository), new(*user.EmailVerificationRepositoryMySQL)),
+	user.ProvideMailEmailVerificationSender,
+	wire.Bind(new(user.EmailVerificationSender), new(*user.MailEmailVerificationSender)),
+	user.ProvidePasswordResetRepositoryMySQL,
+	wire.Bind(new(user.PasswordResetRepository), new(*user.PasswordResetRepositoryMySQL)),
+	user.ProvideMailPasswordResetSender,
+	wire.Bind(new(user.PasswordResetSender), new(*user.MailPasswordResetSender)),
+	user.ProvideDataExportServiceImpl,
+	wire.Bind(new(user.DataExportService), new(*user.DataExportServiceImpl)),
+	user.ProvideDataExportRepositoryMySQL,
+	wire.Bind(new(user.DataExportRepository), new(*user.DataExportRepositoryMySQL)),
+)
+
+// Wiring for domain OAuthClient.
+var domainOAuthClient = wire.NewSet(
+	oauthclient.ProvideClientServiceImpl,
+	wire.Bind(new(oauthclient.ClientService), new(*oauthclient.ClientServiceImpl)),
+	oauthclient.ProvideClientRepositoryMySQL,
+	wire.Bind(new(oauthclient.ClientRepository), new(*oauthclient.ClientRepositoryMySQL)),
+)
+
+// Wiring for domain RBAC.
+var domainRBAC = wire.NewSet(
+	rbac.ProvideRBACServiceImpl,
+	wire.Bind(new(rbac.RBACService), new(*rbac.RBACServiceImpl)),
+	rbac.ProvidePermissionRepositoryMySQL,
+	wire.Bind(new(rbac.PermissionRepository), new(*rbac.PermissionRepositoryMySQL)),
+	rbac.ProvideRoleRepositoryMySQL,
+	wire.Bind(new(rbac.RoleRepository), new(*rbac.RoleRepositoryMySQL)),
 )
 
 // Wiring for all domains.
 var domains = wire.NewSet(
 	domainFooBarBaz,
+	domainOAuthClient,
+	domainRBAC,
 	domainUser,
 )
 
@@ -61,9 +128,13 @@ var authMiddleware = wire.NewSet(
 
 // Wiring for HTTP routing.
 var routing = wire.NewSet(
-	wire.Struct(new(router.DomainHandlers), "FooBarBazHandler", "UserHandler"),
+	wire.Struct(new(router.DomainHandlers), "FooBarBazHandler", "OAuthHandler", "OAuthClientHandler", "RBACHandler", "UserHandler", "WellKnownHandler"),
 	handlers.ProvideFooBarBazHandler,
+	handlers.ProvideOAuthHandler,
+	handlers.ProvideOAuthClientHandler,
+	handlers.ProvideRBACHandler,
 	handlers.ProvideUserHandler,
+	handlers.ProvideWellKnownHandler,
 	router.ProvideRouter,
 )
 
@@ -80,6 +151,11 @@ func InitializeService() *http.HTTP {
 		configurations,
 		// persistences
 		persistences,
+		// tokens
+		tokens,
+		oauthTokens,
+		// mailing
+		mailing,
 		// middleware
 		authMiddleware,
 		// domains
@@ -91,6 +167,60 @@ func InitializeService() *http.HTTP {
 	return &http.HTTP{}
 }
 
+// Wiring for the job that anonymizes deleted accounts.
+func InitializeAccountPurger() *user.AccountPurger {
+	wire.Build(
+		// configurations
+		configurations,
+		// persistences
+		infras.ProvideMySQLConn,
+		// account purger
+		user.ProvideUserRepositoryMySQL,
+		wire.Bind(new(user.UserRepository), new(*user.UserRepositoryMySQL)),
+		user.ProvideAccountPurger)
+	return &user.AccountPurger{}
+}
+
+// Wiring for the admin bootstrap command.
+func InitializeRBACService() *rbac.RBACServiceImpl {
+	wire.Build(
+		// configurations
+		configurations,
+		// persistences
+		infras.ProvideMySQLConn,
+		// role-based access control
+		rbac.ProvidePermissionRepositoryMySQL,
+		wire.Bind(new(rbac.PermissionRepository), new(*rbac.PermissionRepositoryMySQL)),
+		rbac.ProvideRoleRepositoryMySQL,
+		wire.Bind(new(rbac.RoleRepository), new(*rbac.RoleRepositoryMySQL)),
+		rbac.ProvideRBACServiceImpl)
+	return &rbac.RBACServiceImpl{}
+}
+
+// Wiring for the client secret stretching admin command.
+func InitializeClientRepository() *oauthclient.ClientRepositoryMySQL {
+	wire.Build(
+		// configurations
+		configurations,
+		// persistences
+		infras.ProvideMySQLConn,
+		// OAuth clients
+		oauthclient.ProvideClientRepositoryMySQL)
+	return &oauthclient.ClientRepositoryMySQL{}
+}
+
+// Wiring for the signing key ring admin command.
+func InitializeKeyRing() *keyring.KeyRing {
+	wire.Build(
+		// configurations
+		configurations,
+		// persistences
+		infras.ProvideMySQLConn,
+		// key ring
+		keyring.ProvideKeyRing)
+	return &keyring.KeyRing{}
+}
+
 // Wiring the event needs.
 // func InitializeEvent() event.Consumers {
 // 	wire.Build(